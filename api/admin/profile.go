@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+)
+
+// goroutineProfileDebug selects the human readable profile format, with one
+// full stack trace per goroutine
+const goroutineProfileDebug = 2
+
+// writeGoroutineProfile dumps the stacks of every goroutine to a new file in
+// [dir] and returns the path of the file
+func writeGoroutineProfile(dir string, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("goroutine-%s.profile", now.UTC().Format("20060102T150405.000")))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return "", err
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(file, goroutineProfileDebug); err != nil {
+		_ = file.Close()
+		return "", err
+	}
+	return path, file.Close()
+}
@@ -0,0 +1,228 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/utils/logging"
+
+	database "ticketsystem/main/shared/Database"
+)
+
+var (
+	errNoConsensus = errors.New("consensus state is not available")
+	errNoDatabase  = errors.New("database is not available")
+	errNoLogs      = errors.New("log factory is not available")
+	errNoLevel     = errors.New("either logLevel or displayLevel must be provided")
+)
+
+// BlockState is the snowball state of a block that is being decided
+type BlockState struct {
+	BlockID string `json:"blockID"`
+	Height  uint64 `json:"height"`
+	// Preference is the block currently preferred among the block and its
+	// conflicts
+	Preference string `json:"preference"`
+	// Confidence is the number of consecutive successful polls for the
+	// preference
+	Confidence int  `json:"confidence"`
+	Finalized  bool `json:"finalized"`
+}
+
+// ConsensusReporter is implemented by the consensus engine
+type ConsensusReporter interface {
+	// Processing returns the state of every block that is issued but not
+	// yet decided
+	Processing() []BlockState
+}
+
+// Database is the part of the node's database exposed to admins
+type Database interface {
+	Stats() (database.Stats, error)
+	Compact() error
+}
+
+// Config of the admin API. Any field may be left nil if the node runs without
+// that subsystem.
+type Config struct {
+	Consensus ConsensusReporter
+	DB        Database
+	Logs      logging.Factory
+	// ProfileDir is where profiles are written. Profiles are only ever
+	// written to this directory so callers can't choose arbitrary paths.
+	ProfileDir string
+}
+
+// Admin is the API service for node operations
+type Admin struct {
+	config Config
+	clock  func() time.Time
+}
+
+// NewService returns the admin API, served as the "admin" JSON-RPC service
+func NewService(config Config) (*common.HTTPHandler, error) {
+	server := rpc.NewServer()
+	codec := json2.NewCodec()
+	server.RegisterCodec(codec, "application/json")
+	server.RegisterCodec(codec, "application/json;charset=UTF-8")
+	if err := server.RegisterService(&Admin{config: config, clock: time.Now}, "admin"); err != nil {
+		return nil, err
+	}
+	return &common.HTTPHandler{LockOptions: common.NoLock, Handler: server}, nil
+}
+
+// ConsensusStateReply is the reply from ConsensusState
+type ConsensusStateReply struct {
+	Processing []BlockState `json:"processing"`
+}
+
+// ConsensusState returns the preference and confidence of every processing
+// block
+func (a *Admin) ConsensusState(_ *http.Request, _ *struct{}, reply *ConsensusStateReply) error {
+	if a.config.Consensus == nil {
+		return errNoConsensus
+	}
+	reply.Processing = a.config.Consensus.Processing()
+	return nil
+}
+
+// DBStats returns the size and cache hit rates of the database
+func (a *Admin) DBStats(_ *http.Request, _ *struct{}, reply *database.Stats) error {
+	if a.config.DB == nil {
+		return errNoDatabase
+	}
+	stats, err := a.config.DB.Stats()
+	if err != nil {
+		return err
+	}
+	*reply = stats
+	return nil
+}
+
+// SuccessReply is the reply from calls that only report success
+type SuccessReply struct {
+	Success bool `json:"success"`
+}
+
+// CompactDB compacts the database. It blocks until the compaction is done.
+func (a *Admin) CompactDB(_ *http.Request, _ *struct{}, reply *SuccessReply) error {
+	if a.config.DB == nil {
+		return errNoDatabase
+	}
+	if err := a.config.DB.Compact(); err != nil {
+		return err
+	}
+	reply.Success = true
+	return nil
+}
+
+// ProfileReply is the reply from GoroutineProfile
+type ProfileReply struct {
+	Path string `json:"path"`
+}
+
+// GoroutineProfile writes the stacks of every goroutine to a file in the
+// profile directory
+func (a *Admin) GoroutineProfile(_ *http.Request, _ *struct{}, reply *ProfileReply) error {
+	path, err := writeGoroutineProfile(a.config.ProfileDir, a.clock())
+	if err != nil {
+		return err
+	}
+	reply.Path = path
+	return nil
+}
+
+// SetLoggerLevelArgs are the arguments to SetLoggerLevel. An empty
+// LoggerName changes every logger. Levels left empty are not changed.
+type SetLoggerLevelArgs struct {
+	LoggerName   string `json:"loggerName"`
+	LogLevel     string `json:"logLevel"`
+	DisplayLevel string `json:"displayLevel"`
+}
+
+// SetLoggerLevel changes the file and display levels of loggers at runtime
+func (a *Admin) SetLoggerLevel(_ *http.Request, args *SetLoggerLevelArgs, reply *SuccessReply) error {
+	if a.config.Logs == nil {
+		return errNoLogs
+	}
+	if args.LogLevel == "" && args.DisplayLevel == "" {
+		return errNoLevel
+	}
+
+	names := []string{args.LoggerName}
+	if args.LoggerName == "" {
+		names = a.config.Logs.GetLoggerNames()
+	}
+	for _, name := range names {
+		if args.LogLevel != "" {
+			level, err := logging.ToLevel(args.LogLevel)
+			if err != nil {
+				return err
+			}
+			if err := a.config.Logs.SetLogLevel(name, level); err != nil {
+				return err
+			}
+		}
+		if args.DisplayLevel != "" {
+			level, err := logging.ToLevel(args.DisplayLevel)
+			if err != nil {
+				return err
+			}
+			if err := a.config.Logs.SetDisplayLevel(name, level); err != nil {
+				return err
+			}
+		}
+	}
+	reply.Success = true
+	return nil
+}
+
+// LoggerLevels are the levels of one logger
+type LoggerLevels struct {
+	LogLevel     logging.Level `json:"logLevel"`
+	DisplayLevel logging.Level `json:"displayLevel"`
+}
+
+// GetLoggerLevelArgs are the arguments to GetLoggerLevel. An empty
+// LoggerName returns every logger.
+type GetLoggerLevelArgs struct {
+	LoggerName string `json:"loggerName"`
+}
+
+// GetLoggerLevelReply is the reply from GetLoggerLevel
+type GetLoggerLevelReply struct {
+	LoggerLevels map[string]LoggerLevels `json:"loggerLevels"`
+}
+
+// GetLoggerLevel returns the file and display levels of loggers
+func (a *Admin) GetLoggerLevel(_ *http.Request, args *GetLoggerLevelArgs, reply *GetLoggerLevelReply) error {
+	if a.config.Logs == nil {
+		return errNoLogs
+	}
+
+	names := []string{args.LoggerName}
+	if args.LoggerName == "" {
+		names = a.config.Logs.GetLoggerNames()
+	}
+	reply.LoggerLevels = make(map[string]LoggerLevels, len(names))
+	for _, name := range names {
+		logLevel, err := a.config.Logs.GetLogLevel(name)
+		if err != nil {
+			return err
+		}
+		displayLevel, err := a.config.Logs.GetDisplayLevel(name)
+		if err != nil {
+			return err
+		}
+		reply.LoggerLevels[name] = LoggerLevels{
+			LogLevel:     logLevel,
+			DisplayLevel: displayLevel,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxSkew is how far a signed request's timestamp may be from the
+	// server's clock
+	DefaultMaxSkew = 30 * time.Second
+
+	maxBodySize = 1 << 20 // 1 MiB
+)
+
+var (
+	errUnknownRole      = errors.New("unknown role")
+	errEmptyKey         = errors.New("empty key")
+	errMissingPrincipal = errors.New("missing principal")
+	errNoCredentials    = errors.New("no credentials provided")
+	errUnknownKey       = errors.New("unknown key")
+	errBadTimestamp     = errors.New("bad signature timestamp")
+	errStaleSignature   = errors.New("signature outside of the allowed time window")
+	errBadSignature     = errors.New("invalid signature")
+	errBodyTooLarge     = errors.New("request body too large")
+	errForbidden        = errors.New("principal is not authorized to call this method")
+	errMissingEvent     = errors.New("method requires an eventID parameter")
+	errNotOrganizer     = errors.New("principal is not the organizer of this event")
+)
+
+type contextKey struct{}
+
+// Auth authenticates API calls with an API key or a signed request and
+// authorizes the JSON-RPC method being called against a policy
+type Auth struct {
+	keys    *KeyStore
+	policy  *Policy
+	events  EventOwners
+	maxSkew time.Duration
+	clock   func() time.Time
+}
+
+// New returns an Auth that authenticates against [keys] and authorizes against
+// [policy]. [events] resolves event ownership for event scoped methods.
+func New(keys *KeyStore, policy *Policy, events EventOwners) *Auth {
+	return &Auth{
+		keys:    keys,
+		policy:  policy,
+		events:  events,
+		maxSkew: DefaultMaxSkew,
+		clock:   time.Now,
+	}
+}
+
+// SetEventOwners sets how event ownership is resolved. The ticket chain
+// registers itself here once it has been initialized.
+func (a *Auth) SetEventOwners(events EventOwners) {
+	a.events = events
+}
+
+// PrincipalFromContext returns the principal that authenticated the request
+// carrying [ctx]. It returns false for calls to public methods made without
+// credentials.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(*Principal)
+	return principal, ok
+}
+
+// rpcRequest is the part of a JSON-RPC request needed to authorize it
+type rpcRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     json.RawMessage `json:"id"`
+}
+
+type eventParams struct {
+	EventID string `json:"eventID"`
+}
+
+// eventID returns the eventID argument of the call. Params may be passed
+// either as an object or as an array holding a single object.
+func (r *rpcRequest) eventID() string {
+	var params eventParams
+	if err := json.Unmarshal(r.Params, &params); err == nil {
+		return params.EventID
+	}
+	var paramsList []eventParams
+	if err := json.Unmarshal(r.Params, &paramsList); err == nil && len(paramsList) > 0 {
+		return paramsList[0].EventID
+	}
+	return ""
+}
+
+// Authenticate returns the principal identified by the credentials on [r].
+// [body] must be the full request body.
+func (a *Auth) Authenticate(r *http.Request, body []byte) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		principal, ok := a.keys.lookupAPIKey(key)
+		if !ok {
+			return nil, errUnknownKey
+		}
+		return principal, nil
+	}
+	if r.Header.Get(SignatureHeader) != "" {
+		return verifySignature(a.keys, r, body, a.clock(), a.maxSkew)
+	}
+	return nil, errNoCredentials
+}
+
+// WrapHandler wraps [h] so that it is only called for requests that are
+// authenticated and authorized to call the JSON-RPC method in the body. The
+// principal is attached to the request context.
+func (a *Auth) WrapHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.policy.IsPublicPath(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, nil, err)
+			return
+		}
+		if len(body) > maxBodySize {
+			writeError(w, http.StatusRequestEntityTooLarge, nil, errBodyTooLarge)
+			return
+		}
+		// Let the wrapped handler read the body again
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var call rpcRequest
+		if err := json.Unmarshal(body, &call); err != nil {
+			writeError(w, http.StatusBadRequest, nil, err)
+			return
+		}
+
+		principal, err := a.Authenticate(r, body)
+		if err != nil {
+			if errors.Is(err, errNoCredentials) && a.policy.IsPublic(call.Method) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			writeError(w, http.StatusUnauthorized, call.ID, err)
+			return
+		}
+		if err := a.policy.Authorize(principal, call.Method, call.eventID(), a.events); err != nil && !a.policy.IsPublic(call.Method) {
+			writeError(w, http.StatusForbidden, call.ID, fmt.Errorf("%s: %w", call.Method, err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, principal)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Error   rpcError        `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// writeError replies with a JSON-RPC error so clients can handle
+// authentication failures the same way as any other failed call
+func writeError(w http.ResponseWriter, status int, id json.RawMessage, err error) {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(rpcResponse{
+		Version: "2.0",
+		Error: rpcError{
+			Code:    -32600,
+			Message: err.Error(),
+		},
+		ID: id,
+	})
+}
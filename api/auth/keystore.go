@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+const apiKeyLen = 32
+
+// KeyStore maps API keys and request signing keys to the principals they
+// authenticate. API keys are only kept as hashes so a dump of the store can't
+// be replayed against the API.
+type KeyStore struct {
+	lock sync.RWMutex
+
+	// hex(sha256(api key)) -> principal
+	apiKeys map[string]*Principal
+	// key ID -> signing key
+	publicKeys map[string]signingKey
+}
+
+type signingKey struct {
+	key       ed25519.PublicKey
+	principal *Principal
+}
+
+// NewKeyStore returns an empty key store
+func NewKeyStore() *KeyStore {
+	return &KeyStore{
+		apiKeys:    make(map[string]*Principal),
+		publicKeys: make(map[string]signingKey),
+	}
+}
+
+// NewAPIKey generates a new API key that authenticates as [principal]. The
+// returned key is only available to the caller, the store keeps its hash.
+func (ks *KeyStore) NewAPIKey(principal *Principal) (string, error) {
+	keyBytes := make([]byte, apiKeyLen)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(keyBytes)
+	return key, ks.AddAPIKey(key, principal)
+}
+
+// AddAPIKey registers an existing API key for [principal]
+func (ks *KeyStore) AddAPIKey(key string, principal *Principal) error {
+	if key == "" {
+		return errEmptyKey
+	}
+	if principal == nil || principal.ID == "" {
+		return errMissingPrincipal
+	}
+
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+
+	ks.apiKeys[KeyFingerprint(key)] = principal
+	return nil
+}
+
+// RevokeAPIKey removes [key] from the store
+func (ks *KeyStore) RevokeAPIKey(key string) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+
+	delete(ks.apiKeys, KeyFingerprint(key))
+}
+
+// AddPublicKey registers an ed25519 public key, referenced by [keyID] in
+// signed requests, for [principal]
+func (ks *KeyStore) AddPublicKey(keyID string, key ed25519.PublicKey, principal *Principal) error {
+	if keyID == "" || len(key) != ed25519.PublicKeySize {
+		return errEmptyKey
+	}
+	if principal == nil || principal.ID == "" {
+		return errMissingPrincipal
+	}
+
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+
+	ks.publicKeys[keyID] = signingKey{
+		key:       key,
+		principal: principal,
+	}
+	return nil
+}
+
+// RevokePublicKey removes the public key registered under [keyID]
+func (ks *KeyStore) RevokePublicKey(keyID string) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+
+	delete(ks.publicKeys, keyID)
+}
+
+func (ks *KeyStore) lookupAPIKey(key string) (*Principal, bool) {
+	// Looking up the hash rather than the key means the map comparison leaks
+	// nothing useful about the key through timing.
+	hash := KeyFingerprint(key)
+
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+
+	principal, ok := ks.apiKeys[hash]
+	return principal, ok
+}
+
+func (ks *KeyStore) lookupPublicKey(keyID string) (signingKey, bool) {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+
+	key, ok := ks.publicKeys[keyID]
+	return key, ok
+}
+
+// KeyFingerprint returns the hash an API key is stored under. It identifies
+// the key without revealing it.
+func KeyFingerprint(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
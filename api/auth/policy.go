@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EventOwners resolves which organizer owns an event. It is implemented by the
+// ticket chain state so event ownership is always read from the chain.
+type EventOwners interface {
+	// Organizer returns the principal ID of the organizer of [eventID]
+	Organizer(eventID string) (string, error)
+}
+
+// Rule describes who may call an API method
+type Rule struct {
+	// Roles that may call the method. Admins may call every method.
+	Roles []Role
+	// EventScoped methods take an "eventID" parameter and may only be called
+	// by the organizer that owns the event.
+	EventScoped bool
+}
+
+// Policy maps API methods, named "service.Method" exactly as the JSON-RPC
+// server registers them, to the rule authorizing them. A rule registered for "service.*" applies to every method of the
+// service that doesn't have a rule of its own. Methods without a rule are
+// denied.
+type Policy struct {
+	lock        sync.RWMutex
+	rules       map[string]Rule
+	public      map[string]struct{}
+	publicPaths []string
+}
+
+// NewPolicy returns a policy that denies every method
+func NewPolicy() *Policy {
+	return &Policy{
+		rules:  make(map[string]Rule),
+		public: make(map[string]struct{}),
+	}
+}
+
+// DefaultPolicy returns the rules for the ticket chain and node APIs
+func DefaultPolicy() *Policy {
+	p := NewPolicy()
+	p.Allow("admin.*", Rule{Roles: []Role{RoleAdmin}})
+
+	p.Allow("gate.Scan", Rule{Roles: []Role{RoleScanner}})
+	p.Allow("gate.ScanImage", Rule{Roles: []Role{RoleScanner}})
+	p.Allow("gate.Challenge", Rule{Roles: []Role{RoleScanner}})
+	p.Allow("gate.ScanTag", Rule{Roles: []Role{RoleScanner}})
+	p.Allow("gate.GetSnapshot", Rule{Roles: []Role{RoleScanner}})
+	p.Allow("gate.SubmitJournal", Rule{Roles: []Role{RoleScanner}})
+	p.Allow("gate.GetConflicts", Rule{Roles: []Role{RoleOrganizer}, EventScoped: true})
+	p.Allow("gate.GetRotationSecret", Rule{Roles: []Role{RoleHolder}})
+	// Signed transactions are authorized by their signature on chain
+	p.Allow("tickets.IssueTx", Rule{Roles: []Role{RoleOrganizer, RoleHolder, RoleReseller}})
+
+	p.AllowPublic("tickets.GetTicket")
+	p.AllowPublic("tickets.GetEvent")
+	p.AllowPublic("tickets.GetTxStatus")
+	p.AllowPublic("tickets.GetListings")
+	p.AllowPublic("tickets.GetBalances")
+
+	// Health probes are polled by load balancers that hold no credentials
+	p.AllowPublicPath("/ext/health")
+	return p
+}
+
+// Allow registers the rule authorizing [method]
+func (p *Policy) Allow(method string, rule Rule) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.rules[method] = rule
+}
+
+// AllowPublic lets [method] be called without credentials
+func (p *Policy) AllowPublic(method string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.public[method] = struct{}{}
+}
+
+// AllowPublicPath lets every request to a URL path starting with [prefix] be
+// made without credentials. This is for routes that aren't JSON-RPC services.
+func (p *Policy) AllowPublicPath(prefix string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.publicPaths = append(p.publicPaths, prefix)
+}
+
+// IsPublicPath returns true if requests to [path] don't require credentials
+func (p *Policy) IsPublicPath(path string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, prefix := range p.publicPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublic returns true if [method] doesn't require credentials
+func (p *Policy) IsPublic(method string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	_, ok := p.public[method]
+	return ok
+}
+
+// Methods returns the methods that have a rule or are public, sorted
+func (p *Policy) Methods() []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	methods := make([]string, 0, len(p.rules)+len(p.public))
+	for method := range p.rules {
+		methods = append(methods, method)
+	}
+	for method := range p.public {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (p *Policy) rule(method string) (Rule, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if rule, ok := p.rules[method]; ok {
+		return rule, true
+	}
+	if i := strings.IndexByte(method, '.'); i >= 0 {
+		rule, ok := p.rules[method[:i]+".*"]
+		return rule, ok
+	}
+	return Rule{}, false
+}
+
+// Authorize returns nil if [principal] may call [method] for [eventID]. An
+// empty [eventID] fails every event scoped rule for non-admins.
+func (p *Policy) Authorize(principal *Principal, method, eventID string, events EventOwners) error {
+	if principal.HasRole(RoleAdmin) {
+		return nil
+	}
+
+	rule, ok := p.rule(method)
+	if !ok || !principal.HasAnyRole(rule.Roles...) {
+		return errForbidden
+	}
+	if !rule.EventScoped {
+		return nil
+	}
+
+	if eventID == "" {
+		return errMissingEvent
+	}
+	if events == nil {
+		return errForbidden
+	}
+	organizer, err := events.Organizer(eventID)
+	if err != nil {
+		return err
+	}
+	if organizer != principal.ID {
+		return errNotOrganizer
+	}
+	return nil
+}
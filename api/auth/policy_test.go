@@ -0,0 +1,161 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ticketsystem/main/api/admin"
+	"ticketsystem/main/api/auth"
+	"ticketsystem/main/api/health"
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/ticket/gate"
+	"ticketsystem/main/utils/logging"
+	"ticketsystem/main/vms/ticketvm"
+)
+
+// service is a JSON-RPC service as the node serves it
+type service struct {
+	name    string
+	path    string
+	rcvr    interface{}
+	handler http.Handler
+}
+
+func services(t *testing.T) []service {
+	dag := &ticketvm.DAG{}
+	genesis := &ticketvm.Genesis{ChainID: ids.ID{1}, Timestamp: 1}
+	if err := dag.Initialize(genesis, ticketvm.NewState(), common.NewMemDatabase(), logging.NoLog{}); err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gate.New(gate.Config{Key: key, State: dag.State(), Chain: dag, Log: logging.NoLog{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminHandler, err := admin.NewService(admin.Config{ProfileDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthHandlers, err := health.NewHandlers(health.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []service{
+		{name: "tickets", path: "/ext/bc/tickets", rcvr: &ticketvm.Service{}, handler: dag.CreateHandlers()[""].Handler},
+		{name: "gate", path: "/ext/gate", rcvr: &gate.Service{}, handler: g.CreateHandler().Handler},
+		{name: "admin", path: "/ext/admin", rcvr: &admin.Admin{}, handler: adminHandler.Handler},
+		{name: "health", path: "/ext/health", rcvr: &health.Service{}, handler: healthHandlers[""].Handler},
+	}
+}
+
+// rpcMethods returns the methods gorilla/rpc registers for [rcvr]: exported
+// methods taking a request, args and reply and returning an error
+func rpcMethods(rcvr interface{}) []string {
+	requestType := reflect.TypeOf(&http.Request{})
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	typ := reflect.TypeOf(rcvr)
+	var methods []string
+	for i := 0; i < typ.NumMethod(); i++ {
+		method := typ.Method(i).Type
+		if method.NumIn() == 4 && method.In(1) == requestType && method.NumOut() == 1 && method.Out(0) == errorType {
+			methods = append(methods, typ.Method(i).Name)
+		}
+	}
+	return methods
+}
+
+// call sends a JSON-RPC request for [method] to [h] and returns the status
+// and body of the response
+func call(t *testing.T, h http.Handler, path, method, apiKey string) (int, string) {
+	body := `{"jsonrpc":"2.0","method":"` + method + `","params":{"eventID":"ev"},"id":1}`
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	b, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, string(b)
+}
+
+func adminKey(t *testing.T) (*auth.KeyStore, string) {
+	keys := auth.NewKeyStore()
+	key, err := keys.NewAPIKey(&auth.Principal{ID: "admin", Roles: []auth.Role{auth.RoleAdmin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, key
+}
+
+func served(body string) bool {
+	return !strings.Contains(body, "can't find method") && !strings.Contains(body, "can't find service")
+}
+
+// TestPolicyCoversRegisteredMethods checks every method the node serves has a
+// rule, under the name the JSON-RPC server dispatches it by
+func TestPolicyCoversRegisteredMethods(t *testing.T) {
+	policy := auth.DefaultPolicy()
+	covered := make(map[string]bool)
+	for _, method := range policy.Methods() {
+		covered[method] = true
+	}
+	keys, key := adminKey(t)
+	a := auth.New(keys, policy, nil)
+	for _, s := range services(t) {
+		h := a.WrapHandler(s.handler)
+		for _, name := range rpcMethods(s.rcvr) {
+			method := s.name + "." + name
+			if !covered[method] && !covered[s.name+".*"] && !policy.IsPublicPath(s.path) {
+				t.Errorf("%s has no rule", method)
+			}
+			status, body := call(t, h, s.path, method, key)
+			if status == http.StatusForbidden || !served(body) {
+				t.Errorf("%s wasn't dispatched: %d %s", method, status, body)
+			}
+		}
+	}
+}
+
+// TestPolicyMethodsAreRegistered checks every rule names a method the node
+// serves, and that public methods are served without credentials
+func TestPolicyMethodsAreRegistered(t *testing.T) {
+	policy := auth.DefaultPolicy()
+	keys, key := adminKey(t)
+	a := auth.New(keys, policy, nil)
+	byName := make(map[string]service)
+	for _, s := range services(t) {
+		byName[s.name] = s
+	}
+	for _, method := range policy.Methods() {
+		name, suffix, _ := strings.Cut(method, ".")
+		s, ok := byName[name]
+		if !ok {
+			t.Errorf("%s: no service %q", method, name)
+			continue
+		}
+		if suffix == "*" {
+			continue
+		}
+		apiKey := key
+		if policy.IsPublic(method) {
+			apiKey = ""
+		}
+		status, body := call(t, a.WrapHandler(s.handler), s.path, method, apiKey)
+		if status == http.StatusForbidden || status == http.StatusUnauthorized || !served(body) {
+			t.Errorf("%s isn't served: %d %s", method, status, body)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"fmt"
+)
+
+// Role is a capability granted to a principal calling the API
+type Role string
+
+const (
+	// RoleAdmin may call every method, including node operations
+	RoleAdmin Role = "admin"
+	// RoleOrganizer creates events and issues tickets for the events it owns
+	RoleOrganizer Role = "organizer"
+	// RoleReseller lists and sells tickets on the secondary market
+	RoleReseller Role = "reseller"
+	// RoleScanner validates tickets and checks them in at the gate
+	RoleScanner Role = "scanner"
+	// RoleHolder owns tickets and may transfer them
+	RoleHolder Role = "holder"
+)
+
+var roles = map[Role]struct{}{
+	RoleAdmin:     {},
+	RoleOrganizer: {},
+	RoleReseller:  {},
+	RoleScanner:   {},
+	RoleHolder:    {},
+}
+
+// ParseRole returns the role named by [str]
+func ParseRole(str string) (Role, error) {
+	role := Role(str)
+	if _, ok := roles[role]; !ok {
+		return "", fmt.Errorf("%w: %q", errUnknownRole, str)
+	}
+	return role, nil
+}
+
+// Principal is the authenticated caller of an API method
+type Principal struct {
+	// ID identifies the caller. For organizers this is the ID stored as the
+	// owner of their events.
+	ID    string `json:"id"`
+	Roles []Role `json:"roles"`
+}
+
+// HasRole returns true if the principal was granted [role]
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyRole returns true if the principal was granted at least one of [roles]
+func (p *Principal) HasAnyRole(roles ...Role) bool {
+	for _, role := range roles {
+		if p.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	errNotEnoughPeers   = errors.New("not enough connected peers")
+	errConsensusStalled = errors.New("consensus is not making progress")
+)
+
+// ConsensusProgress is reported by the consensus engine
+type ConsensusProgress interface {
+	// NumProcessing returns the number of blocks that are issued but not yet
+	// decided
+	NumProcessing() int
+	// LastAccepted returns when a block was last accepted
+	LastAccepted() time.Time
+}
+
+// PeerCounter is reported by the network
+type PeerCounter interface {
+	NumPeers() int
+}
+
+type consensusDetails struct {
+	Processing   int       `json:"processing"`
+	LastAccepted time.Time `json:"lastAccepted"`
+}
+
+// ConsensusProgressing fails if blocks are processing but none has been
+// accepted for [maxStall]. An idle chain with nothing to decide is healthy.
+func ConsensusProgressing(progress ConsensusProgress, maxStall time.Duration) Checker {
+	return CheckerFunc(func() (interface{}, error) {
+		details := consensusDetails{
+			Processing:   progress.NumProcessing(),
+			LastAccepted: progress.LastAccepted(),
+		}
+		if details.Processing > 0 && time.Since(details.LastAccepted) > maxStall {
+			return details, fmt.Errorf("%w: no block accepted for %s", errConsensusStalled, time.Since(details.LastAccepted))
+		}
+		return details, nil
+	})
+}
+
+type peerDetails struct {
+	Connected int `json:"connected"`
+	Required  int `json:"required"`
+}
+
+// PeersConnected fails if fewer than [minPeers] peers are connected
+func PeersConnected(peers PeerCounter, minPeers int) Checker {
+	return CheckerFunc(func() (interface{}, error) {
+		details := peerDetails{
+			Connected: peers.NumPeers(),
+			Required:  minPeers,
+		}
+		if details.Connected < minPeers {
+			return details, errNotEnoughPeers
+		}
+		return details, nil
+	})
+}
@@ -0,0 +1,134 @@
+package health
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errDuplicateCheck = errors.New("duplicated check")
+
+// Checker reports the health of a subsystem. A nil error means healthy, the
+// returned details are shown to the caller either way.
+type Checker interface {
+	HealthCheck() (interface{}, error)
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func() (interface{}, error)
+
+// HealthCheck implements the Checker interface
+func (f CheckerFunc) HealthCheck() (interface{}, error) {
+	return f()
+}
+
+// Result is the outcome of the last run of a check
+type Result struct {
+	Details   interface{}   `json:"message,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
+	// ContiguousFailures is the number of times in a row the check failed
+	ContiguousFailures int64     `json:"contiguousFailures,omitempty"`
+	TimeOfFirstFailure time.Time `json:"timeOfFirstFailure,omitempty"`
+}
+
+// checks is a set of named checks along with their last results
+type checks struct {
+	lock    sync.Mutex
+	checks  map[string]Checker
+	results map[string]Result
+}
+
+func newChecks() *checks {
+	return &checks{
+		checks:  make(map[string]Checker),
+		results: make(map[string]Result),
+	}
+}
+
+func (c *checks) register(name string, checker Checker) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.checks[name]; ok {
+		return fmt.Errorf("%w: %q", errDuplicateCheck, name)
+	}
+	c.checks[name] = checker
+	return nil
+}
+
+// run runs every check and returns their results, along with whether all of
+// them passed
+func (c *checks) run(now func() time.Time) (map[string]Result, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	healthy := true
+	results := make(map[string]Result, len(c.checks))
+	for name, checker := range c.checks {
+		start := now()
+		details, err := checker.HealthCheck()
+		end := now()
+
+		result := Result{
+			Details:   details,
+			Timestamp: end,
+			Duration:  end.Sub(start),
+		}
+		if err != nil {
+			healthy = false
+			prev := c.results[name]
+			result.Error = err.Error()
+			result.ContiguousFailures = prev.ContiguousFailures + 1
+			result.TimeOfFirstFailure = prev.TimeOfFirstFailure
+			if prev.ContiguousFailures == 0 {
+				result.TimeOfFirstFailure = end
+			}
+		}
+		c.results[name] = result
+		results[name] = result
+	}
+	return results, healthy
+}
+
+// Health tracks the liveness and readiness of the node.
+//
+// Liveness checks fail when the node is stuck and should be restarted.
+// Readiness checks fail when the node is running but shouldn't serve traffic
+// yet, e.g. while it has no peers or consensus isn't making progress.
+type Health struct {
+	liveness  *checks
+	readiness *checks
+	clock     func() time.Time
+}
+
+// New returns a Health without any checks registered
+func New() *Health {
+	return &Health{
+		liveness:  newChecks(),
+		readiness: newChecks(),
+		clock:     time.Now,
+	}
+}
+
+// RegisterLivenessCheck adds a check that must pass for the node to be alive
+func (h *Health) RegisterLivenessCheck(name string, checker Checker) error {
+	return h.liveness.register(name, checker)
+}
+
+// RegisterReadinessCheck adds a check that must pass for the node to be ready
+func (h *Health) RegisterReadinessCheck(name string, checker Checker) error {
+	return h.readiness.register(name, checker)
+}
+
+// Liveness runs the liveness checks
+func (h *Health) Liveness() (map[string]Result, bool) {
+	return h.liveness.run(h.clock)
+}
+
+// Readiness runs the readiness checks
+func (h *Health) Readiness() (map[string]Result, bool) {
+	return h.readiness.run(h.clock)
+}
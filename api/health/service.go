@@ -0,0 +1,72 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+
+	"ticketsystem/main/snow/engine/common"
+)
+
+// Service is the JSON-RPC API of the health checks
+type Service struct {
+	health *Health
+}
+
+// APIReply is the health of one set of checks
+type APIReply struct {
+	Checks  map[string]Result `json:"checks"`
+	Healthy bool              `json:"healthy"`
+}
+
+// Liveness returns the results of the liveness checks
+func (s *Service) Liveness(_ *http.Request, _ *struct{}, reply *APIReply) error {
+	reply.Checks, reply.Healthy = s.health.Liveness()
+	return nil
+}
+
+// Readiness returns the results of the readiness checks
+func (s *Service) Readiness(_ *http.Request, _ *struct{}, reply *APIReply) error {
+	reply.Checks, reply.Healthy = s.health.Readiness()
+	return nil
+}
+
+// NewHandlers returns the handlers of the health API, keyed by the endpoint
+// they are served under. The base endpoint answers JSON-RPC calls to
+// "health.Liveness" and "health.Readiness". "/liveness" and "/readiness"
+// answer GET requests with 200 when healthy and 503 otherwise so they can be
+// used directly as probes.
+func NewHandlers(h *Health) (map[string]*common.HTTPHandler, error) {
+	server := rpc.NewServer()
+	codec := json2.NewCodec()
+	server.RegisterCodec(codec, "application/json")
+	server.RegisterCodec(codec, "application/json;charset=UTF-8")
+	if err := server.RegisterService(&Service{health: h}, "health"); err != nil {
+		return nil, err
+	}
+	return map[string]*common.HTTPHandler{
+		"":           {LockOptions: common.NoLock, Handler: server},
+		"/liveness":  {LockOptions: common.NoLock, Handler: probeHandler(h.Liveness)},
+		"/readiness": {LockOptions: common.NoLock, Handler: probeHandler(h.Readiness)},
+	}, nil
+}
+
+func probeHandler(run func() (map[string]Result, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		checks, healthy := run()
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(APIReply{
+			Checks:  checks,
+			Healthy: healthy,
+		})
+	})
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	requestLatency *prometheus.HistogramVec
+}
+
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		requestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "request_duration_seconds",
+			Help:    "Time taken to serve an API request",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "code"}),
+	}
+	return m, reg.Register(m.requestLatency)
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// wrapHandler records the latency of every request served by [h] under
+// [route]. Requests rejected by throttling or authentication are included.
+func (m *metrics) wrapHandler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(recorder, r)
+		m.requestLatency.
+			WithLabelValues(route, strconv.Itoa(recorder.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
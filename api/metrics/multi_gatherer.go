@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	errDuplicatedNamespace = errors.New("duplicated namespace")
+	errEmptyNamespace      = errors.New("empty namespace")
+)
+
+// MultiGatherer is the shared registry subsystems plug their metrics into.
+// Each subsystem registers its own gatherer under a namespace, which prefixes
+// all of its metric names.
+type MultiGatherer interface {
+	prometheus.Gatherer
+
+	// Register adds [gatherer] under [namespace]
+	Register(namespace string, gatherer prometheus.Gatherer) error
+}
+
+type multiGatherer struct {
+	lock      sync.RWMutex
+	gatherers map[string]prometheus.Gatherer
+}
+
+// NewMultiGatherer returns an empty MultiGatherer
+func NewMultiGatherer() MultiGatherer {
+	return &multiGatherer{
+		gatherers: make(map[string]prometheus.Gatherer),
+	}
+}
+
+// NewRegistry returns a registry for a subsystem that is already registered
+// in [gatherer] under [namespace]
+func NewRegistry(gatherer MultiGatherer, namespace string) (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	return registry, gatherer.Register(namespace, registry)
+}
+
+func (g *multiGatherer) Register(namespace string, gatherer prometheus.Gatherer) error {
+	if namespace == "" {
+		return errEmptyNamespace
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, exists := g.gatherers[namespace]; exists {
+		return fmt.Errorf("%w: %q", errDuplicatedNamespace, namespace)
+	}
+	g.gatherers[namespace] = gatherer
+	return nil
+}
+
+func (g *multiGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	var results []*dto.MetricFamily
+	for namespace, gatherer := range g.gatherers {
+		metrics, err := gatherer.Gather()
+		if err != nil {
+			return nil, err
+		}
+		for _, metric := range metrics {
+			name := fmt.Sprintf("%s_%s", namespace, metric.GetName())
+			metric.Name = &name
+			results = append(results, metric)
+		}
+	}
+	// Gatherers are stored in a map, sort so the output is stable
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].GetName() < results[j].GetName()
+	})
+	return results, nil
+}
@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"ticketsystem/main/snow/engine/common"
+)
+
+// NewService returns the handler that serves every metric registered in
+// [gatherer] in the Prometheus exposition format
+func NewService(gatherer MultiGatherer) *common.HTTPHandler {
+	return &common.HTTPHandler{
+		LockOptions: common.NoLock,
+		Handler:     promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+	}
+}
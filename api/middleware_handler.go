@@ -0,0 +1,20 @@
+package api
+
+import "net/http"
+
+// middlewareHandler calls [before] and [after] around every request served
+// by [handler], e.g. to hold a chain's lock
+type middlewareHandler struct {
+	before, after func()
+	handler       http.Handler
+}
+
+func (mh middlewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if mh.before != nil {
+		mh.before()
+	}
+	if mh.after != nil {
+		defer mh.after()
+	}
+	mh.handler.ServeHTTP(w, r)
+}
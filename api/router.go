@@ -1,4 +1,140 @@
 package api
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errUnknownBaseURL  = errors.New("unknown base url")
+	errUnknownEndpoint = errors.New("unknown endpoint")
+	errAlreadyReserved = errors.New("route is already reserved")
+)
+
+// router maps URLs to handlers. A route may be served under aliases, which
+// are reserved so that no handler can be added under them later.
 type router struct {
+	lock   sync.RWMutex
+	router *mux.Router
+
+	routeLock      sync.Mutex
+	reservedRoutes map[string]bool
+	// aliases maps a base URL to the base URLs it is also served under
+	aliases map[string][]string
+	// routes maps a base URL to its endpoints' handlers
+	routes map[string]map[string]http.Handler
+}
+
+func newRouter() *router {
+	return &router{
+		router:         mux.NewRouter(),
+		reservedRoutes: make(map[string]bool),
+		aliases:        make(map[string][]string),
+		routes:         make(map[string]map[string]http.Handler),
+	}
+}
+
+func (r *router) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	r.router.ServeHTTP(writer, request)
+}
+
+// GetHandler returns the handler of [endpoint] under [base]
+func (r *router) GetHandler(base, endpoint string) (http.Handler, error) {
+	r.routeLock.Lock()
+	defer r.routeLock.Unlock()
+
+	urlBase, exists := r.routes[base]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", errUnknownBaseURL, base)
+	}
+	handler, exists := urlBase[endpoint]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s%s", errUnknownEndpoint, base, endpoint)
+	}
+	return handler, nil
+}
+
+// AddRouter serves [handler] at [base]+[endpoint] and under every alias of
+// [base]
+func (r *router) AddRouter(base, endpoint string, handler http.Handler) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.routeLock.Lock()
+	defer r.routeLock.Unlock()
+
+	return r.addRouter(base, endpoint, handler)
+}
+
+func (r *router) addRouter(base, endpoint string, handler http.Handler) error {
+	if r.reservedRoutes[base] {
+		return fmt.Errorf("%w: %s", errAlreadyReserved, base)
+	}
+	return r.forceAddRouter(base, endpoint, handler)
+}
+
+func (r *router) forceAddRouter(base, endpoint string, handler http.Handler) error {
+	endpoints := r.routes[base]
+	if endpoints == nil {
+		endpoints = make(map[string]http.Handler)
+	}
+	url := base + endpoint
+	if _, exists := endpoints[endpoint]; exists {
+		return fmt.Errorf("failed to create endpoint as %s already exists", url)
+	}
+
+	endpoints[endpoint] = handler
+	r.routes[base] = endpoints
+
+	// Name routes based on their URL for easy retrieval in the future
+	route := r.router.Handle(url, handler)
+	if route == nil {
+		return fmt.Errorf("failed to create new route for %s", url)
+	}
+	route.Name(url)
+
+	var err error
+	for _, alias := range r.aliases[base] {
+		if innerErr := r.forceAddRouter(alias, endpoint, handler); err == nil {
+			err = innerErr
+		}
+	}
+	return err
+}
+
+// AddAlias serves every endpoint of [base] under each of [aliases] as well
+func (r *router) AddAlias(base string, aliases ...string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.routeLock.Lock()
+	defer r.routeLock.Unlock()
+
+	for _, alias := range aliases {
+		if r.reservedRoutes[alias] {
+			return fmt.Errorf("%w: %s", errAlreadyReserved, alias)
+		}
+	}
+
+	for _, alias := range aliases {
+		r.reservedRoutes[alias] = true
+	}
+	r.aliases[base] = append(r.aliases[base], aliases...)
+
+	var err error
+	if endpoints, exists := r.routes[base]; exists {
+		for endpoint, handler := range endpoints {
+			for _, alias := range aliases {
+				if innerErr := r.forceAddRouter(alias, endpoint, handler); err == nil {
+					err = innerErr
+				}
+			}
+		}
+	}
+	return err
 }
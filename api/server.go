@@ -1,58 +1,110 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"sync"
+
+	"github.com/gorilla/handlers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/cors"
+
+	"ticketsystem/main/api/auth"
+	"ticketsystem/main/api/throttle"
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/utils/logging"
 )
 
-const baseUrl = "/ext"
+const baseURL = "/ext"
+
+var errUnknownLockOption = errors.New("invalid lock options")
 
 type Server struct {
 	log     logging.Logger
+	factory logging.Factory
 	router  *router
-	portUrl string
+	portURL string
+	srv     *http.Server
+	auth    *auth.Auth
+	// throttler, if non-nil, rate limits every route. It runs before
+	// authentication so floods are rejected as cheaply as possible.
+	throttler *throttle.Throttler
+	metrics   *metrics
 }
 
-// Initialize creates the API server at the provided port
-func (s *Server) Initialize(log logging.Logger, factory logging.Factory, port uint16) {
+// Initialize creates the API server at the provided port. If [authenticator]
+// is non-nil every route requires an API key or a signed request. If
+// [throttler] is non-nil every route is rate limited. Request latencies are
+// registered in [registerer].
+func (s *Server) Initialize(
+	log logging.Logger,
+	factory logging.Factory,
+	port uint16,
+	authenticator *auth.Auth,
+	throttler *throttle.Throttler,
+	registerer prometheus.Registerer,
+) error {
 	s.log = log
 	s.factory = factory
 	s.portURL = fmt.Sprintf(":%d", port)
 	s.router = newRouter()
+	s.srv = &http.Server{
+		Addr:    s.portURL,
+		Handler: cors.Default().Handler(s.router),
+	}
+	s.auth = authenticator
+	s.throttler = throttler
+
+	var err error
+	s.metrics, err = newMetrics(registerer)
+	return err
 }
 
-// Dispatch starts the API server
+// Dispatch starts the API server. It blocks until the server is shut down.
 func (s *Server) Dispatch() error {
-	handler := cors.Default().Handler(s.router)
-	return http.ListenAndServe(s.portURL, handler)
+	if err := s.srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-// DispatchTLS starts the API server with the provided TLS certificate
+// DispatchTLS starts the API server with the provided TLS certificate. It
+// blocks until the server is shut down.
 func (s *Server) DispatchTLS(certFile, keyFile string) error {
-	handler := cors.Default().Handler(s.router)
-	return http.ListenAndServeTLS(s.portURL, certFile, keyFile, handler)
+	if err := s.srv.ListenAndServeTLS(certFile, keyFile); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting requests and waits for the ones being served to
+// finish, or for [ctx] to be done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
 }
 
 // RegisterChain registers the API endpoints associated with this chain That
 // is, add <route, handler> pairs to server so that http calls can be made to
-// the vm
-func (s *Server) RegisterChain(ctx *snow.Context, vmIntf interface{}) {
+// the vm. Handlers that ask for a lock hold [lock] while serving.
+func (s *Server) RegisterChain(chainID ids.ID, lock *sync.RWMutex, vmIntf interface{}) {
 	vm, ok := vmIntf.(common.VM)
 	if !ok {
 		return
 	}
 
 	// all subroutes to a chain begin with "bc/<the chain's ID>"
-	defaultEndpoint := "bc/" + ctx.ChainID.String()
-	httpLogger, err := s.factory.MakeChain(ctx.ChainID, "http")
+	defaultEndpoint := "bc/" + chainID.String()
+	httpLogger, err := s.factory.MakeChain(chainID, "http")
 	if err != nil {
 		s.log.Error("Failed to create new http logger: %s", err)
 		return
 	}
-	s.log.Verbo("About to add API endpoints for chain with ID %s", ctx.ChainID)
+	s.log.Verbo("About to add API endpoints for chain with ID %s", chainID)
 
 	// Register each endpoint
 	for extension, service := range vm.CreateHandlers() {
@@ -64,7 +116,7 @@ func (s *Server) RegisterChain(ctx *snow.Context, vmIntf interface{}) {
 			continue
 		}
 		s.log.Verbo("adding API endpoint: %s", defaultEndpoint+extension)
-		if err := s.AddRoute(service, &ctx.Lock, defaultEndpoint, extension, httpLogger); err != nil {
+		if err := s.AddRoute(service, lock, defaultEndpoint, extension, httpLogger); err != nil {
 			s.log.Error("error adding route: %s", err)
 		}
 	}
@@ -75,6 +127,16 @@ func (s *Server) AddRoute(handler *common.HTTPHandler, lock *sync.RWMutex, base,
 	url := fmt.Sprintf("%s/%s", baseURL, base)
 	s.log.Info("adding route %s%s", url, endpoint)
 	h := handlers.CombinedLoggingHandler(log, handler.Handler)
+	if s.throttler != nil {
+		h = s.throttler.WrapAuthenticatedHandler(h)
+	}
+	if s.auth != nil {
+		h = s.auth.WrapHandler(h)
+	}
+	if s.throttler != nil {
+		h = s.throttler.WrapHandler(h)
+	}
+	h = s.metrics.wrapHandler(url+endpoint, h)
 	switch handler.LockOptions {
 	case common.WriteLock:
 		return s.router.AddRouter(url, endpoint, middlewareHandler{
@@ -0,0 +1,110 @@
+package throttle
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Quota is a token bucket refilled at [Rate] tokens per second that holds at
+// most [Burst] tokens. A zero rate disables the limit.
+type Quota struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Enabled returns true if the quota limits anything
+func (q Quota) Enabled() bool {
+	return q.Rate > 0
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes one token from the bucket. If the bucket is empty it returns
+// how long until a token will be available.
+func (b *bucket) take(q Quota, now time.Time) (bool, time.Duration) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(q.Burst), b.tokens+elapsed*q.Rate)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / q.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Limiter rate limits requests per key, each key getting its own bucket
+type Limiter struct {
+	lock    sync.Mutex
+	quota   Quota
+	buckets map[string]*bucket
+
+	// Buckets that have been idle long enough to refill completely are
+	// dropped, they behave exactly like a new bucket
+	idleTimeout time.Duration
+	lastPrune   time.Time
+}
+
+// NewLimiter returns a limiter that gives every key [quota]
+func NewLimiter(quota Quota) *Limiter {
+	if quota.Burst < 1 {
+		quota.Burst = 1
+	}
+	idleTimeout := time.Minute
+	if quota.Enabled() {
+		idleTimeout = time.Duration(float64(quota.Burst)/quota.Rate*float64(time.Second)) + time.Second
+	}
+	return &Limiter{
+		quota:       quota,
+		buckets:     make(map[string]*bucket),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// Allow takes a token for [key]. If none is available it returns false and
+// how long the caller should wait before retrying.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	if !l.quota.Enabled() {
+		return true, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastPrune) > l.idleTimeout {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(l.quota.Burst),
+			last:   now,
+		}
+		l.buckets[key] = b
+	}
+	return b.take(l.quota, now)
+}
+
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > l.idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// Len returns the number of keys currently tracked
+func (l *Limiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.buckets)
+}
@@ -0,0 +1,277 @@
+package throttle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ticketsystem/main/api/auth"
+)
+
+const maxBodySize = 1 << 20 // 1 MiB
+
+var (
+	errRateLimited  = errors.New("rate limit exceeded")
+	errBodyTooLarge = errors.New("request body too large")
+)
+
+// Limits are the quotas for one kind of caller
+type Limits struct {
+	Read     Quota `json:"read"`
+	Purchase Quota `json:"purchase"`
+}
+
+// Config describes the quotas applied to API calls
+type Config struct {
+	IP     Limits `json:"ip"`
+	APIKey Limits `json:"apiKey"`
+
+	// Holder quotas are charged to the authenticated principal. Only the
+	// purchase quota applies. The per event cap on the tickets a holder may
+	// own is enforced by the ticket chain when the transaction is issued.
+	Holder Limits `json:"holder"`
+
+	// PurchaseMethods are throttled with the purchase quotas, every other
+	// method with the read quotas
+	PurchaseMethods []string `json:"purchaseMethods"`
+}
+
+// DefaultConfig returns quotas suited to a public ticket drop
+func DefaultConfig() Config {
+	return Config{
+		IP: Limits{
+			Read:     Quota{Rate: 20, Burst: 40},
+			Purchase: Quota{Rate: 1, Burst: 3},
+		},
+		APIKey: Limits{
+			Read:     Quota{Rate: 50, Burst: 100},
+			Purchase: Quota{Rate: 5, Burst: 10},
+		},
+		Holder: Limits{
+			Purchase: Quota{Rate: 0.2, Burst: 2},
+		},
+		PurchaseMethods: []string{"tickets.IssueTx"},
+	}
+}
+
+type limiters struct {
+	read     *Limiter
+	purchase *Limiter
+}
+
+func newLimiters(l Limits) limiters {
+	return limiters{
+		read:     NewLimiter(l.Read),
+		purchase: NewLimiter(l.Purchase),
+	}
+}
+
+func (l limiters) get(purchase bool) *Limiter {
+	if purchase {
+		return l.purchase
+	}
+	return l.read
+}
+
+// Throttler rate limits API calls per IP, per API key and per authenticated
+// holder
+type Throttler struct {
+	ip, apiKey, holder limiters
+
+	purchaseMethods map[string]struct{}
+	clock           func() time.Time
+}
+
+// New returns a throttler enforcing [config]
+func New(config Config) *Throttler {
+	purchaseMethods := make(map[string]struct{}, len(config.PurchaseMethods))
+	for _, method := range config.PurchaseMethods {
+		purchaseMethods[method] = struct{}{}
+	}
+	return &Throttler{
+		ip:              newLimiters(config.IP),
+		apiKey:          newLimiters(config.APIKey),
+		holder:          newLimiters(config.Holder),
+		purchaseMethods: purchaseMethods,
+		clock:           time.Now,
+	}
+}
+
+type rpcRequest struct {
+	Method string          `json:"method"`
+	ID     json.RawMessage `json:"id"`
+}
+
+// WrapHandler wraps [h] so that calls exceeding their per IP or per API key
+// quota are rejected with 429 Too Many Requests and a Retry-After header. It
+// runs before authentication, so it only charges what the caller can't forge:
+// its IP and the secret API key it presents. Signed requests are charged to
+// their key by WrapAuthenticatedHandler, once the signature was checked, as
+// key IDs are public.
+func (t *Throttler) WrapHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call, ok := readCall(w, r)
+		if !ok {
+			return
+		}
+		_, purchase := t.purchaseMethods[call.Method]
+		now := t.clock()
+
+		if ok, wait := t.allow(now, charge{t.ip.get(purchase), remoteIP(r)}, charge{t.apiKey.get(purchase), apiKeyID(r)}); !ok {
+			writeRateLimited(w, call.ID, wait)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// WrapAuthenticatedHandler wraps [h] so that signed requests are charged to
+// the API key quota of their key, and purchases to the holder quota of the
+// authenticated principal. It must run after authentication, as the principal
+// is taken from the request context. Calls without a principal are only
+// subject to the per IP and per API key quotas.
+func (t *Throttler) WrapAuthenticatedHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok || principal == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		call, ok := readCall(w, r)
+		if !ok {
+			return
+		}
+		_, purchase := t.purchaseMethods[call.Method]
+		charges := []charge{{t.apiKey.get(purchase), signedKeyID(r)}}
+		if purchase {
+			charges = append(charges, charge{t.holder.purchase, principal.ID})
+		}
+		if ok, wait := t.allow(t.clock(), charges...); !ok {
+			writeRateLimited(w, call.ID, wait)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// readCall reads the JSON-RPC call in the body of [r] and restores the body
+// for the wrapped handler. Requests without a body, such as health probes,
+// are returned as an empty call. Returns false if an error was written.
+func readCall(w http.ResponseWriter, r *http.Request) (rpcRequest, bool) {
+	var call rpcRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, nil, err)
+		return call, false
+	}
+	if len(body) > maxBodySize {
+		writeError(w, http.StatusRequestEntityTooLarge, nil, errBodyTooLarge)
+		return call, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &call); err != nil {
+			writeError(w, http.StatusBadRequest, nil, err)
+			return call, false
+		}
+	}
+	return call, true
+}
+
+// charge is a bucket a call is charged to
+type charge struct {
+	limiter *Limiter
+	key     string
+}
+
+// allow takes a token from every bucket the call is charged to. The longest
+// wait is returned so the client doesn't retry into another limit.
+func (t *Throttler) allow(now time.Time, charges ...charge) (bool, time.Duration) {
+	allowed := true
+	var maxWait time.Duration
+	for _, c := range charges {
+		if c.key == "" {
+			continue
+		}
+		if ok, wait := c.limiter.Allow(c.key, now); !ok {
+			allowed = false
+			if wait > maxWait {
+				maxWait = wait
+			}
+		}
+	}
+	return allowed, maxWait
+}
+
+func writeRateLimited(w http.ResponseWriter, id json.RawMessage, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, id, fmt.Errorf("%w: retry in %ds", errRateLimited, seconds))
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// apiKeyID identifies the API key of the request without keeping the raw key
+// in memory
+func apiKeyID(r *http.Request) string {
+	if key := r.Header.Get(auth.APIKeyHeader); key != "" {
+		return "key:" + auth.KeyFingerprint(key)
+	}
+	return ""
+}
+
+// signedKeyID identifies the key a signed request was signed with. It may only
+// be charged once the signature was verified.
+func signedKeyID(r *http.Request) string {
+	if r.Header.Get(auth.APIKeyHeader) != "" {
+		return ""
+	}
+	if keyID := r.Header.Get(auth.KeyIDHeader); keyID != "" {
+		return "sig:" + keyID
+	}
+	return ""
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Error   rpcError        `json:"error"`
+	ID      json.RawMessage `json:"id"`
+}
+
+func writeError(w http.ResponseWriter, status int, id json.RawMessage, err error) {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(rpcResponse{
+		Version: "2.0",
+		Error: rpcError{
+			Code:    -32600,
+			Message: err.Error(),
+		},
+		ID: id,
+	})
+}
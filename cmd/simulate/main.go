@@ -0,0 +1,85 @@
+// simulate runs snowball among simulated validators with an adversary
+// holding a growing share of the weight, and reports how often and how fast
+// honest validators reach finality.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"ticketsystem/main/snow/simulation"
+
+	snowball "ticketsystem/main/snow"
+)
+
+func main() {
+	params := snowball.DefaultParameters
+	var (
+		numValidators = flag.Int("validators", 100, "number of equally weighted validators")
+		shares        = flag.String("adversary-shares", "0,0.2,0.33,0.51", "comma separated shares of weight held by the adversary")
+		strategy      = flag.String("strategy", string(simulation.Balance), "adversary strategy: balance or flip")
+		trials        = flag.Int("trials", 20, "runs per adversary share")
+		maxRounds     = flag.Int("max-rounds", 500, "polling rounds before a run gives up")
+		seed          = flag.Int64("seed", 1, "seed of the first run")
+	)
+	flag.IntVar(&params.K, "snow-sample-size", params.K, "k")
+	flag.IntVar(&params.Alpha, "snow-quorum-size", params.Alpha, "alpha")
+	flag.IntVar(&params.BetaVirtuous, "snow-virtuous-commit-threshold", params.BetaVirtuous, "beta virtuous")
+	flag.IntVar(&params.BetaRogue, "snow-rogue-commit-threshold", params.BetaRogue, "beta rogue")
+	flag.Parse()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "adversary\tfinalized runs\tmean rounds\thonest finalized\tconflicting finalized\tsafety violations\n")
+	for _, field := range strings.Split(*shares, ",") {
+		share, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bad adversary share %q: %s\n", field, err)
+			os.Exit(1)
+		}
+
+		var (
+			finalizedRuns, rounds, violations int
+			honest, finalized, conflicting    int
+		)
+		for trial := 0; trial < *trials; trial++ {
+			result, err := simulation.Run(simulation.Config{
+				Params:         params,
+				Validators:     *numValidators,
+				AdversaryShare: share,
+				Strategy:       simulation.Strategy(*strategy),
+				MaxRounds:      *maxRounds,
+				Seed:           *seed + int64(trial),
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "simulation failed: %s\n", err)
+				os.Exit(1)
+			}
+			if result.Finalized == result.HonestValidators {
+				finalizedRuns++
+				rounds += result.Rounds
+			}
+			if result.SafetyViolated {
+				violations++
+			}
+			honest += result.HonestValidators
+			finalized += result.Finalized
+			conflicting += result.FinalizedConflicting
+		}
+
+		meanRounds := "-"
+		if finalizedRuns > 0 {
+			meanRounds = fmt.Sprintf("%.1f", float64(rounds)/float64(finalizedRuns))
+		}
+		fmt.Fprintf(w, "%.0f%%\t%d/%d\t%s\t%.1f%%\t%.1f%%\t%d\n",
+			100*share, finalizedRuns, *trials, meanRounds,
+			100*float64(finalized)/float64(honest),
+			100*float64(conflicting)/float64(honest),
+			violations,
+		)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/node"
+	"ticketsystem/main/utils"
+	"ticketsystem/main/utils/logging"
+)
+
+const configFileFlag = "config-file"
+
+var errBadPort = errors.New("port must be at most 65535")
+
+func defaultDataDir() string {
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".ticketsystem")
+	}
+	return ".ticketsystem"
+}
+
+// stakingPaths returns the default staking key and certificate in [dataDir]
+func stakingPaths(dataDir string) (string, string) {
+	stakingDir := filepath.Join(dataDir, "staking")
+	return filepath.Join(stakingDir, "staker.key"), filepath.Join(stakingDir, "staker.crt")
+}
+
+// parseConfig returns the node's config. The config file named by
+// --config-file is read first, so that flags set on the command line
+// override it. Paths left empty are resolved against the data directory.
+func parseConfig(args []string) (node.Config, error) {
+	config := node.DefaultConfig()
+	config.DataDir = defaultDataDir()
+	if path := configFileArg(args); path != "" {
+		if err := readConfigFile(path, &config); err != nil {
+			return config, err
+		}
+	}
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.String(configFileFlag, "", "JSON config file; flags override its values")
+	fs.StringVar(&config.DataDir, "data-dir", config.DataDir, "directory of the database, logs, staking key and genesis")
+	fs.StringVar(&config.GenesisFile, "genesis-file", config.GenesisFile, "genesis file (default <data-dir>/genesis.json)")
+	fs.StringVar(&config.ConsensusMode, "consensus-mode", config.ConsensusMode, "linear or dag")
+
+	httpPort := uint(config.HTTPPort)
+	fs.UintVar(&httpPort, "http-port", httpPort, "port of the API")
+	fs.StringVar(&config.HTTPTLSKeyFile, "http-tls-key-file", config.HTTPTLSKeyFile, "TLS key of the API, served over HTTPS if set with --http-tls-cert-file")
+	fs.StringVar(&config.HTTPTLSCertFile, "http-tls-cert-file", config.HTTPTLSCertFile, "TLS certificate of the API")
+	fs.BoolVar(&config.APIAuthEnabled, "api-auth-enabled", config.APIAuthEnabled, "require an API key or a signed request")
+	fs.BoolVar(&config.APIThrottleEnabled, "api-throttle-enabled", config.APIThrottleEnabled, "rate limit the API")
+
+	fs.BoolVar(&config.StakingEnabled, "staking-enabled", config.StakingEnabled, "authenticate peers with their staking certificates")
+	fs.StringVar(&config.StakingKeyFile, "staking-tls-key-file", config.StakingKeyFile, "staking key (default <data-dir>/staking/staker.key)")
+	fs.StringVar(&config.StakingCertFile, "staking-tls-cert-file", config.StakingCertFile, "staking certificate (default <data-dir>/staking/staker.crt)")
+
+	fs.Func("network-id", "network ID, separating test networks from production", func(s string) error {
+		networkID, err := strconv.ParseUint(s, 10, 32)
+		config.Network.NetworkID = uint32(networkID)
+		return err
+	})
+	fs.StringVar(&config.Network.ListenAddr, "staking-listen-addr", config.Network.ListenAddr, "address peers connect to")
+	fs.TextVar(&config.Network.MyIP, "public-ip", config.Network.MyIP, "IP and port advertised to peers")
+	fs.StringVar(&config.PublicIPResolver, "dynamic-public-ip", config.PublicIPResolver, "resolve the public IP with a static, interface or http resolver")
+	fs.StringVar(&config.PublicIPResolverArg, "dynamic-public-ip-arg", config.PublicIPResolverArg, "argument of the public IP resolver")
+	fs.Func("bootstrap-ips", "comma separated IP:port of the peers to connect to on startup", func(s string) error {
+		config.Network.Bootstrap = nil
+		for _, field := range splitList(s) {
+			ip, err := utils.ToIPDesc(field)
+			if err != nil {
+				return err
+			}
+			config.Network.Bootstrap = append(config.Network.Bootstrap, utils.ConfigIPDesc{IPDesc: ip})
+		}
+		return nil
+	})
+	fs.Func("bootstrap-ids", "comma separated node IDs of the beacons the chain is bootstrapped from", func(s string) error {
+		config.Beacons = nil
+		for _, field := range splitList(s) {
+			nodeID, err := ids.ShortFromString(field)
+			if err != nil {
+				return err
+			}
+			config.Beacons = append(config.Beacons, nodeID)
+		}
+		return nil
+	})
+
+	fs.IntVar(&config.Consensus.K, "snow-sample-size", config.Consensus.K, "k; the genesis' parameters are used if zero")
+	fs.IntVar(&config.Consensus.Alpha, "snow-quorum-size", config.Consensus.Alpha, "alpha")
+	fs.IntVar(&config.Consensus.BetaVirtuous, "snow-virtuous-commit-threshold", config.Consensus.BetaVirtuous, "beta virtuous")
+	fs.IntVar(&config.Consensus.BetaRogue, "snow-rogue-commit-threshold", config.Consensus.BetaRogue, "beta rogue")
+	fs.DurationVar(&config.QueryTimeout, "snow-query-timeout", config.QueryTimeout, "how long a poll waits for votes")
+	fs.IntVar(&config.ConcurrentPolls, "snow-concurrent-polls", config.ConcurrentPolls, "most polls in flight in DAG mode")
+
+	fs.StringVar(&config.GateKeyFile, "gate-key-file", config.GateKeyFile, "organizer key check-ins are signed with; serves the gate API if set")
+	fs.StringVar(&config.GateKeyCacheFile, "gate-key-cache-file", config.GateKeyCacheFile, "saved key cache holding the events' rotation keys")
+	fs.Int64Var(&config.GateFee, "gate-fee", config.GateFee, "fee each check-in pays, in minor units of the chain's fee currency")
+
+	fs.StringVar(&config.Logging.Directory, "log-dir", config.Logging.Directory, "directory of the log files (default <data-dir>/logs)")
+	fs.Func("log-level", "level written to the log files", func(s string) (err error) {
+		config.Logging.LogLevel, err = logging.ToLevel(s)
+		return err
+	})
+	fs.Func("log-display-level", "level written to the terminal", func(s string) (err error) {
+		config.Logging.DisplayLevel, err = logging.ToLevel(s)
+		return err
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return config, err
+	}
+	if httpPort > 65535 {
+		return config, fmt.Errorf("%w: %d", errBadPort, httpPort)
+	}
+	config.HTTPPort = uint16(httpPort)
+
+	defaultKey, defaultCert := stakingPaths(config.DataDir)
+	setDefault(&config.GenesisFile, filepath.Join(config.DataDir, "genesis.json"))
+	setDefault(&config.StakingKeyFile, defaultKey)
+	setDefault(&config.StakingCertFile, defaultCert)
+	setDefault(&config.Logging.Directory, filepath.Join(config.DataDir, "logs"))
+	return config, nil
+}
+
+// configFileArg returns the value of --config-file in [args], which must be
+// known before the other flags are parsed
+func configFileArg(args []string) string {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if value, ok := strings.CutPrefix(name, configFileFlag+"="); ok {
+			return value
+		}
+		if name == configFileFlag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func readConfigFile(path string, config *node.Config) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func setDefault(s *string, value string) {
+	if *s == "" {
+		*s = value
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/snow/engine/snowman"
+	"ticketsystem/main/utils/logging"
+	"ticketsystem/main/vms/ticketvm"
+
+	database "ticketsystem/main/shared/Database"
+)
+
+// inspectDB prints the accepted chain and the statistics of a node's
+// database. The node must be stopped, as leveldb is opened exclusively.
+func inspectDB(args []string) error {
+	fs := flag.NewFlagSet("db inspect", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", defaultDataDir(), "data directory of the node")
+	height := fs.Int64("height", -1, "also print the block accepted at this height")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dbDir := filepath.Join(*dataDir, "db")
+	if _, err := os.Stat(dbDir); err != nil {
+		return err
+	}
+	db, err := database.NewTicketDatabase(dbDir, database.DefaultConfig(), logging.NoLog{}, prometheus.NewRegistry())
+	if err != nil {
+		return fmt.Errorf("couldn't open the database, is the node running? %w", err)
+	}
+	defer db.Close()
+
+	lastAccepted, err := ticketvm.StoredLastAccepted(db)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		fmt.Println("no chain is stored")
+	case err != nil:
+		return err
+	default:
+		genesis, err := ticketvm.StoredBlock(db, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("genesis block:        %s\n", genesis.ID())
+		fmt.Printf("last accepted block:  %s\n", lastAccepted.ID())
+		fmt.Printf("last accepted height: %d\n", lastAccepted.Height())
+		fmt.Printf("last accepted at:     %s\n", time.Unix(lastAccepted.Timestamp, 0).UTC().Format(time.RFC3339))
+	}
+
+	target, bootstrapping, err := snowman.BootstrapTarget(db)
+	if err != nil {
+		return err
+	}
+	if bootstrapping {
+		fmt.Printf("bootstrapping to:     %s\n", target)
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("size:                 %d bytes\n", stats.SizeBytes)
+	fmt.Printf("\n%s\n", stats.LevelDB)
+
+	if *height < 0 {
+		return nil
+	}
+	blk, err := ticketvm.StoredBlock(db, uint64(*height))
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(blk)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/staking"
+	"ticketsystem/main/vms/ticketvm"
+
+	snowball "ticketsystem/main/snow"
+)
+
+var (
+	errGenesisExists = errors.New("genesis file already exists")
+	errBadValidator  = errors.New("validator must be nodeID:operator:weight")
+	errBadOrganizer  = errors.New("organizer must be name:publicKey")
+)
+
+// initGenesis writes the genesis of a new chain. Without --validators the
+// local node, identified by its staking certificate, is the only validator.
+func initGenesis(args []string) error {
+	params := snowball.DefaultParameters
+	fs := flag.NewFlagSet("init-genesis", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", defaultDataDir(), "data directory the genesis and staking key are in by default")
+	output := fs.String("output", "", "path to write the genesis to (default <data-dir>/genesis.json)")
+	force := fs.Bool("force", false, "overwrite an existing genesis file")
+	chainIDStr := fs.String("chain-id", "", "chain ID (default random)")
+	timestamp := fs.Int64("timestamp", time.Now().Unix(), "timestamp of the genesis block, in Unix seconds")
+	keyPath := fs.String("staking-tls-key-file", "", "staking key of the local node (default <data-dir>/staking/staker.key)")
+	certPath := fs.String("staking-tls-cert-file", "", "staking certificate of the local node (default <data-dir>/staking/staker.crt)")
+	operator := fs.String("operator", "local", "operator of the local node")
+	weight := fs.Uint64("weight", 1, "weight of the local node")
+	validators := fs.String("validators", "", "comma separated nodeID:operator:weight of the initial validators, instead of the local node")
+	operators := fs.String("operators", "", "comma separated addresses of further operators admitted to add validators")
+	organizers := fs.String("organizers", "", "comma separated name:publicKey of the organizers, with hex encoded ed25519 keys")
+	fs.IntVar(&params.K, "snow-sample-size", params.K, "k")
+	fs.IntVar(&params.Alpha, "snow-quorum-size", params.Alpha, "alpha")
+	fs.IntVar(&params.BetaVirtuous, "snow-virtuous-commit-threshold", params.BetaVirtuous, "beta virtuous")
+	fs.IntVar(&params.BetaRogue, "snow-rogue-commit-threshold", params.BetaRogue, "beta rogue")
+	var caps ticketvm.StakeCaps
+	fs.Uint64Var(&caps.MaxValidatorWeight, "max-validator-weight", 0, "most weight a single validator may hold; zero means no limit")
+	fs.Uint64Var(&caps.MaxOperatorWeight, "max-operator-weight", 0, "most weight one operator's validators may hold; zero means no limit")
+	fs.Uint64Var(&caps.MaxOperatorShareBps, "max-operator-share-bps", 0, "largest share of the weight, in basis points, one operator may hold; zero means no limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	setDefault(output, filepath.Join(*dataDir, "genesis.json"))
+	if _, err := os.Stat(*output); err == nil && !*force {
+		return fmt.Errorf("%w: %s", errGenesisExists, *output)
+	}
+
+	genesis := &ticketvm.Genesis{
+		Timestamp: *timestamp,
+		Consensus: params,
+		StakeCaps: caps,
+	}
+	if *chainIDStr != "" {
+		chainID, err := ids.FromString(*chainIDStr)
+		if err != nil {
+			return err
+		}
+		genesis.ChainID = chainID
+	} else if _, err := rand.Read(genesis.ChainID[:]); err != nil {
+		return err
+	}
+
+	if *validators != "" {
+		for _, field := range splitList(*validators) {
+			vdr, err := parseValidator(field)
+			if err != nil {
+				return err
+			}
+			genesis.Validators = append(genesis.Validators, vdr)
+		}
+	} else {
+		defaultKey, defaultCert := stakingPaths(*dataDir)
+		setDefault(keyPath, defaultKey)
+		setDefault(certPath, defaultCert)
+		cert, err := staking.LoadTLSCert(*keyPath, *certPath, true)
+		if err != nil {
+			return fmt.Errorf("couldn't load staking key: %w", err)
+		}
+		genesis.Validators = []ticketvm.Validator{{
+			NodeID:   staking.NodeID(cert.Leaf),
+			Operator: *operator,
+			Weight:   *weight,
+		}}
+	}
+	genesis.Operators = splitList(*operators)
+	for _, field := range splitList(*organizers) {
+		name, key, ok := strings.Cut(field, ":")
+		if !ok {
+			return fmt.Errorf("%w: %q", errBadOrganizer, field)
+		}
+		genesis.Organizers = append(genesis.Organizers, ticketvm.GenesisOrganizer{
+			Name:      name,
+			PublicKey: key,
+		})
+	}
+	if err := genesis.Verify(); err != nil {
+		return err
+	}
+	genesisID, err := genesis.BlockID()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote genesis to %s\n", *output)
+	fmt.Printf("chain ID: %s\n", genesis.ChainID)
+	fmt.Printf("genesis block ID: %s\n", genesisID)
+	return nil
+}
+
+func parseValidator(str string) (ticketvm.Validator, error) {
+	fields := strings.Split(str, ":")
+	if len(fields) != 3 {
+		return ticketvm.Validator{}, fmt.Errorf("%w: %q", errBadValidator, str)
+	}
+	nodeID, err := ids.ShortFromString(fields[0])
+	if err != nil {
+		return ticketvm.Validator{}, err
+	}
+	weight, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return ticketvm.Validator{}, fmt.Errorf("%w: %q", errBadValidator, str)
+	}
+	return ticketvm.Validator{
+		NodeID:   nodeID,
+		Operator: fields[1],
+		Weight:   weight,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"ticketsystem/main/staking"
+	"ticketsystem/main/vms/ticketvm"
+)
+
+var (
+	errUnknownKeyType = errors.New("unknown key type")
+	errKeyExists      = errors.New("key file already exists")
+)
+
+// keygen writes a new staking key and certificate, whose hash is the node ID
+// the node is known by, or a new organizer signing key
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", defaultDataDir(), "data directory the staking key is written to by default")
+	keyType := fs.String("type", "staking", "staking or organizer")
+	keyPath := fs.String("staking-tls-key-file", "", "path to write the staking key to (default <data-dir>/staking/staker.key)")
+	certPath := fs.String("staking-tls-cert-file", "", "path to write the staking certificate to (default <data-dir>/staking/staker.crt)")
+	organizerPath := fs.String("organizer-key-file", "organizer.key", "path to write the hex encoded organizer key to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	switch *keyType {
+	case "staking":
+		defaultKey, defaultCert := stakingPaths(*dataDir)
+		setDefault(keyPath, defaultKey)
+		setDefault(certPath, defaultCert)
+		if err := staking.InitNodeStakingKeyPair(*keyPath, *certPath); err != nil {
+			return fmt.Errorf("couldn't generate staking key: %w", err)
+		}
+		cert, err := staking.LoadTLSCert(*keyPath, *certPath, false)
+		if err != nil {
+			return fmt.Errorf("couldn't load staking key: %w", err)
+		}
+		fmt.Printf("wrote staking key to %s and certificate to %s\n", *keyPath, *certPath)
+		fmt.Printf("node ID: %s\n", staking.NodeID(cert.Leaf))
+		return nil
+	case "organizer":
+		if _, err := os.Stat(*organizerPath); err == nil {
+			return fmt.Errorf("%w: %s", errKeyExists, *organizerPath)
+		}
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(*organizerPath), 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(*organizerPath, []byte(hex.EncodeToString(priv.Seed())+"\n"), 0o600); err != nil {
+			return err
+		}
+		fmt.Printf("wrote organizer key to %s\n", *organizerPath)
+		fmt.Printf("public key: %s\n", hex.EncodeToString(pub))
+		fmt.Printf("address: %s\n", ticketvm.Address(pub))
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errUnknownKeyType, *keyType)
+	}
+}
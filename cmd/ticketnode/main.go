@@ -0,0 +1,65 @@
+// ticketnode runs a node of the ticket chain and manages its files.
+//
+//	ticketnode run [flags]           run the node
+//	ticketnode init-genesis [flags]  write a genesis for a new chain
+//	ticketnode keygen [flags]        write a staking or organizer key
+//	ticketnode db inspect [flags]    summarize the node's database
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const usage = `usage: ticketnode <command> [flags]
+
+commands:
+  run           run the node
+  init-genesis  write a genesis for a new chain
+  keygen        write a staking or organizer key
+  db inspect    summarize the node's database
+
+Run "ticketnode <command> -h" for the command's flags.
+`
+
+var errUnknownCommand = errors.New("unknown command")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "run":
+		err = run(args)
+	case "init-genesis":
+		err = initGenesis(args)
+	case "keygen":
+		err = keygen(args)
+	case "db":
+		if len(args) == 0 || args[0] != "inspect" {
+			err = fmt.Errorf("%w: db %s", errUnknownCommand, strings.Join(args, " "))
+			break
+		}
+		err = inspectDB(args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		err = fmt.Errorf("%w: %s", errUnknownCommand, cmd)
+	}
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUnknownCommand):
+		fmt.Fprintf(os.Stderr, "%s\n\n%s", err, usage)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "ticketnode: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ticketsystem/main/node"
+	"ticketsystem/main/utils/logging"
+)
+
+// run runs the node until it is interrupted
+func run(args []string) error {
+	config, err := parseConfig(args)
+	if err != nil {
+		return err
+	}
+	logFactory := logging.NewFactory(config.Logging)
+	defer logFactory.Close()
+
+	n := &node.Node{}
+	if err := n.Initialize(config, logFactory); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		n.Log.Info("received %s", sig)
+		_ = n.Shutdown()
+	}()
+
+	err = n.Dispatch()
+	signal.Stop(signals)
+	close(signals)
+	return err
+}
@@ -0,0 +1,59 @@
+package ids
+
+// Bag is a multiset of IDs. It is used to tally the votes of a poll.
+type Bag struct {
+	counts map[ID]int
+	size   int
+
+	mode     ID
+	modeFreq int
+}
+
+// Add adds one of each of [ids] to the bag
+func (b *Bag) Add(ids ...ID) {
+	for _, id := range ids {
+		b.AddCount(id, 1)
+	}
+}
+
+// AddCount adds [count] of [id] to the bag
+func (b *Bag) AddCount(id ID, count int) {
+	if count <= 0 {
+		return
+	}
+	if b.counts == nil {
+		b.counts = make(map[ID]int)
+	}
+	total := b.counts[id] + count
+	b.counts[id] = total
+	b.size += count
+
+	if total > b.modeFreq {
+		b.mode = id
+		b.modeFreq = total
+	}
+}
+
+// Count returns how many times [id] was added
+func (b *Bag) Count(id ID) int {
+	return b.counts[id]
+}
+
+// Len returns the number of IDs in the bag, counting duplicates
+func (b *Bag) Len() int {
+	return b.size
+}
+
+// List returns the distinct IDs in the bag
+func (b *Bag) List() []ID {
+	ids := make([]ID, 0, len(b.counts))
+	for id := range b.counts {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Mode returns the most common ID and how many times it was added
+func (b *Bag) Mode() (ID, int) {
+	return b.mode, b.modeFreq
+}
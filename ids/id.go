@@ -0,0 +1,74 @@
+package ids
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IDLen is the number of bytes in an ID
+const IDLen = 32
+
+var errBadIDLen = errors.New("bad ID length")
+
+// Empty is the zero ID
+var Empty = ID{}
+
+// ID identifies a chain, a block or a ticket transaction. IDs are usually
+// the sha256 hash of the thing they identify.
+type ID [IDLen]byte
+
+// ToID returns [b] as an ID. [b] must be IDLen bytes long.
+func ToID(b []byte) (ID, error) {
+	var id ID
+	if len(b) != IDLen {
+		return id, fmt.Errorf("%w: expected %d bytes but got %d", errBadIDLen, IDLen, len(b))
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// FromString parses the hex representation of an ID
+func FromString(str string) (ID, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(str, "0x"))
+	if err != nil {
+		return ID{}, err
+	}
+	return ToID(b)
+}
+
+// Checksum returns the ID of [b]
+func Checksum(b []byte) ID {
+	return sha256.Sum256(b)
+}
+
+// Bytes returns a copy of the bytes of the ID
+func (id ID) Bytes() []byte {
+	return append([]byte(nil), id[:]...)
+}
+
+// IsZero returns true if the ID is Empty
+func (id ID) IsZero() bool {
+	return id == Empty
+}
+
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalText encodes the ID as hex, which also makes it a valid JSON map key
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText parses the hex representation of an ID
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := FromString(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,64 @@
+package ids
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ShortIDLen is the number of bytes in a ShortID
+const ShortIDLen = 20
+
+// ShortEmpty is the zero ShortID
+var ShortEmpty = ShortID{}
+
+// ShortID identifies a node
+type ShortID [ShortIDLen]byte
+
+// ToShortID returns [b] as a ShortID. [b] must be ShortIDLen bytes long.
+func ToShortID(b []byte) (ShortID, error) {
+	var id ShortID
+	if len(b) != ShortIDLen {
+		return id, fmt.Errorf("%w: expected %d bytes but got %d", errBadIDLen, ShortIDLen, len(b))
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// ShortFromString parses the hex representation of a ShortID
+func ShortFromString(str string) (ShortID, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(str, "0x"))
+	if err != nil {
+		return ShortID{}, err
+	}
+	return ToShortID(b)
+}
+
+// Bytes returns a copy of the bytes of the ShortID
+func (id ShortID) Bytes() []byte {
+	return append([]byte(nil), id[:]...)
+}
+
+// IsZero returns true if the ShortID is ShortEmpty
+func (id ShortID) IsZero() bool {
+	return id == ShortEmpty
+}
+
+func (id ShortID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalText encodes the ShortID as hex
+func (id ShortID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText parses the hex representation of a ShortID
+func (id *ShortID) UnmarshalText(text []byte) error {
+	parsed, err := ShortFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
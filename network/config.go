@@ -0,0 +1,94 @@
+package network
+
+import (
+	"crypto/tls"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils"
+	"ticketsystem/main/utils/dynamicip"
+)
+
+// Config of the peer to peer network
+type Config struct {
+	// NetworkID separates test networks from production networks
+	NetworkID uint32 `json:"networkID"`
+	// ChainID of the ticket chain. Peers on another chain are dropped.
+	ChainID ids.ID `json:"chainID"`
+	// NodeID of this node
+	NodeID ids.ShortID `json:"nodeID"`
+	// TLS authenticates peer connections with the staking certificates of
+	// both sides, as returned by staking.NewTLSConfig. Peers are then
+	// identified by the hash of their certificate. If nil, connections are
+	// plain TCP and peers are trusted to report their own node ID, which is
+	// only suitable for local testing.
+	TLS *tls.Config `json:"-"`
+	// Version is the software version advertised to peers
+	Version string `json:"version"`
+
+	// ListenAddr is the address the node listens on, e.g. ":9651"
+	ListenAddr string `json:"listenAddr"`
+	// MyIP is the address advertised to peers. If IPResolver is set, the IP
+	// is replaced by the resolved one; a zero port is replaced by the port
+	// the node listens on.
+	MyIP utils.ConfigIPDesc `json:"myIP"`
+	// IPResolver finds the public IP of the node. It is consulted on
+	// startup and every IPResolutionFrequency after that.
+	IPResolver            dynamicip.Resolver `json:"-"`
+	IPResolutionFrequency time.Duration      `json:"ipResolutionFrequency"`
+	// Bootstrap peers are dialed on startup and redialed whenever the
+	// connection drops
+	Bootstrap []utils.ConfigIPDesc `json:"bootstrap"`
+
+	// MaxPeers is the most peers the node is connected to at once
+	MaxPeers int `json:"maxPeers"`
+	// MaxMessageSize is the largest frame accepted from a peer
+	MaxMessageSize uint32 `json:"maxMessageSize"`
+	// SendQueueSize is the number of messages buffered per peer. Messages
+	// sent to a peer whose queue is full are dropped.
+	SendQueueSize int `json:"sendQueueSize"`
+
+	HandshakeTimeout time.Duration `json:"handshakeTimeout"`
+	// PingFrequency is how often peers are pinged. A peer that sends nothing
+	// for PingTimeout is disconnected.
+	PingFrequency time.Duration `json:"pingFrequency"`
+	PingTimeout   time.Duration `json:"pingTimeout"`
+
+	// PeerListGossipFrequency is how often a sample of known peers is sent
+	// to connected peers
+	PeerListGossipFrequency time.Duration `json:"peerListGossipFrequency"`
+	PeerListSize            int           `json:"peerListSize"`
+	// IPs learned from peer lists are dialed for up to GossipedIPTimeout and
+	// dropped if they don't connect by then. At most MaxGossipedIPs of them
+	// are pending at once; further ones are ignored.
+	MaxGossipedIPs    int           `json:"maxGossipedIPs"`
+	GossipedIPTimeout time.Duration `json:"gossipedIPTimeout"`
+
+	// Dial backoff starts at InitialReconnectDelay and doubles up to
+	// MaxReconnectDelay
+	InitialReconnectDelay time.Duration `json:"initialReconnectDelay"`
+	MaxReconnectDelay     time.Duration `json:"maxReconnectDelay"`
+}
+
+// DefaultConfig returns the network config used when none is provided. The
+// chain ID, node ID and addresses must still be set.
+func DefaultConfig() Config {
+	return Config{
+		NetworkID:               1,
+		Version:                 "ticket/0.1.0",
+		ListenAddr:              ":9651",
+		MaxPeers:                64,
+		MaxMessageSize:          2 * 1024 * 1024,
+		SendQueueSize:           256,
+		HandshakeTimeout:        10 * time.Second,
+		PingFrequency:           30 * time.Second,
+		PingTimeout:             90 * time.Second,
+		PeerListGossipFrequency: time.Minute,
+		PeerListSize:            20,
+		MaxGossipedIPs:          512,
+		GossipedIPTimeout:       10 * time.Minute,
+		InitialReconnectDelay:   time.Second,
+		MaxReconnectDelay:       time.Minute,
+		IPResolutionFrequency:   5 * time.Minute,
+	}
+}
@@ -0,0 +1,158 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils"
+)
+
+// Op identifies the kind of a message
+type Op byte
+
+// Messages exchanged between peers
+const (
+	// Handshake
+	Version Op = iota
+	// Peer discovery
+	GetPeerList
+	PeerList
+	// Keepalive
+	Ping
+	Pong
+	// Block gossip
+	Get
+	Put
+	// Consensus
+	PushQuery
+	PullQuery
+	Chits
+	// Application messages, passed to the VM
+	AppGossip
+	// Bootstrapping
+	GetAcceptedFrontier
+	AcceptedFrontier
+	GetAncestors
+	Ancestors
+)
+
+var opNames = [...]string{
+	Version:     "version",
+	GetPeerList: "get_peerlist",
+	PeerList:    "peerlist",
+	Ping:        "ping",
+	Pong:        "pong",
+	Get:         "get",
+	Put:         "put",
+	PushQuery:   "push_query",
+	PullQuery:   "pull_query",
+	Chits:       "chits",
+	AppGossip:   "app_gossip",
+
+	GetAcceptedFrontier: "get_accepted_frontier",
+	AcceptedFrontier:    "accepted_frontier",
+	GetAncestors:        "get_ancestors",
+	Ancestors:           "ancestors",
+}
+
+func (op Op) String() string {
+	if int(op) >= len(opNames) {
+		return fmt.Sprintf("Unknown Op(%d)", byte(op))
+	}
+	return opNames[op]
+}
+
+var (
+	errUnknownOp     = errors.New("unknown op")
+	errMessageTooBig = errors.New("message too big")
+	errEmptyFrame    = errors.New("empty frame")
+)
+
+// Message is a message sent between peers. Only the fields relevant to the
+// Op are set.
+type Message struct {
+	Op Op `json:"-"`
+
+	// Version
+	NetworkID uint32       `json:"networkID,omitempty"`
+	ChainID   ids.ID       `json:"chainID,omitempty"`
+	NodeID    ids.ShortID  `json:"nodeID,omitempty"`
+	MyIP      utils.IPDesc `json:"myIP,omitempty"`
+	Timestamp int64        `json:"timestamp,omitempty"`
+	// VersionStr is the software version of the sender, e.g. "ticket/0.1.0"
+	VersionStr string `json:"version,omitempty"`
+
+	// PeerList
+	Peers []utils.IPDesc `json:"peers,omitempty"`
+
+	// Get, Put, PushQuery, PullQuery, Chits and bootstrapping
+	RequestID uint32   `json:"requestID,omitempty"`
+	BlockID   ids.ID   `json:"blockID,omitempty"`
+	Height    uint64   `json:"height,omitempty"`
+	Block     []byte   `json:"block,omitempty"`
+	Votes     []ids.ID `json:"votes,omitempty"`
+
+	// AppGossip
+	Payload []byte `json:"payload,omitempty"`
+
+	// Ancestors holds the requested block followed by its parent, its
+	// parent's parent and so on
+	Blocks [][]byte `json:"blocks,omitempty"`
+}
+
+// Messages are framed as a 4 byte big endian length followed by the op and
+// the JSON encoded message.
+const frameHeaderLen = 4
+
+// writeMessage frames [msg] onto [w]
+func writeMessage(w io.Writer, msg *Message, maxSize uint32) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	size := uint32(len(body) + 1)
+	if size > maxSize {
+		return fmt.Errorf("%w: %s of %d bytes", errMessageTooBig, msg.Op, size)
+	}
+
+	frame := make([]byte, frameHeaderLen+int(size))
+	binary.BigEndian.PutUint32(frame, size)
+	frame[frameHeaderLen] = byte(msg.Op)
+	copy(frame[frameHeaderLen+1:], body)
+	_, err = w.Write(frame)
+	return err
+}
+
+// readMessage reads one framed message from [r]
+func readMessage(r io.Reader, maxSize uint32) (*Message, error) {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	switch {
+	case size == 0:
+		return nil, errEmptyFrame
+	case size > maxSize:
+		return nil, fmt.Errorf("%w: %d bytes", errMessageTooBig, size)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	op := Op(frame[0])
+	if int(op) >= len(opNames) {
+		return nil, fmt.Errorf("%w: %d", errUnknownOp, frame[0])
+	}
+	msg := &Message{}
+	if err := json.Unmarshal(frame[1:], msg); err != nil {
+		return nil, fmt.Errorf("couldn't parse %s: %w", op, err)
+	}
+	msg.Op = op
+	return msg, nil
+}
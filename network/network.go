@@ -0,0 +1,666 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/staking"
+	"ticketsystem/main/utils"
+	"ticketsystem/main/utils/logging"
+)
+
+var (
+	errClosed           = errors.New("network closed")
+	errTooManyPeers     = errors.New("too many peers")
+	errWrongNetwork     = errors.New("peer is on a different network")
+	errWrongChain       = errors.New("peer is on a different chain")
+	errSelfConnection   = errors.New("connected to self")
+	errDuplicatePeer    = errors.New("already connected to peer")
+	errUnexpectedOp     = errors.New("unexpected message during handshake")
+	errMissingNodeID    = errors.New("peer didn't provide a node ID")
+	errUnexpectedNodeID = errors.New("peer's node ID doesn't match its certificate")
+)
+
+// Handler receives the consensus and block messages from peers
+type Handler interface {
+	Connected(nodeID ids.ShortID)
+	Disconnected(nodeID ids.ShortID)
+	HandleInbound(nodeID ids.ShortID, msg *Message)
+}
+
+// Network connects the node to its peers
+type Network interface {
+	// Dispatch listens for peers and dials the bootstrap peers. It blocks
+	// until the network is closed.
+	Dispatch() error
+
+	// Track keeps the node connected to [ip], redialing it with backoff
+	// whenever the connection drops
+	Track(ip utils.IPDesc)
+
+	// Send queues [msg] for each of [nodeIDs] and returns the peers it was
+	// queued for
+	Send(msg *Message, nodeIDs ...ids.ShortID) []ids.ShortID
+
+	// Gossip queues [msg] for every connected peer and returns the peers it
+	// was queued for
+	Gossip(msg *Message) []ids.ShortID
+
+	// MyIP returns the address advertised to peers
+	MyIP() utils.IPDesc
+
+	// Peers returns the currently connected peers
+	Peers() []PeerInfo
+	NumPeers() int
+
+	Close() error
+}
+
+type network struct {
+	config  Config
+	log     logging.Logger
+	handler Handler
+
+	dialer   net.Dialer
+	listener net.Listener
+
+	lock sync.RWMutex
+	// myIP is the address advertised to peers
+	myIP  utils.IPDesc
+	peers map[ids.ShortID]*peer
+	// tracked are the IPs the node stays connected to, keyed by IPDesc.String()
+	tracked map[string]utils.IPDesc
+	// gossiped are the IPs learned from peer lists that are being dialed,
+	// with the time the node gives up on them
+	gossiped map[string]time.Time
+	// dialing are the tracked and gossiped IPs with a dial loop running
+	dialing map[string]struct{}
+	// backoff is how long to wait before the next dial of an IP
+	backoff map[string]time.Duration
+	// connected are the IPs of connected peers
+	connected map[string]ids.ShortID
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewNetwork returns a network for [config] that delivers messages to
+// [handler]
+func NewNetwork(config Config, log logging.Logger, handler Handler) Network {
+	return &network{
+		config:    config,
+		log:       log,
+		handler:   handler,
+		dialer:    net.Dialer{Timeout: config.HandshakeTimeout},
+		myIP:      config.MyIP.IPDesc,
+		peers:     make(map[ids.ShortID]*peer),
+		tracked:   make(map[string]utils.IPDesc),
+		gossiped:  make(map[string]time.Time),
+		dialing:   make(map[string]struct{}),
+		backoff:   make(map[string]time.Duration),
+		connected: make(map[string]ids.ShortID),
+		closed:    make(chan struct{}),
+	}
+}
+
+func (n *network) Dispatch() error {
+	listener, err := net.Listen("tcp", n.config.ListenAddr)
+	if err != nil {
+		return err
+	}
+	n.lock.Lock()
+	if n.isClosed() {
+		n.lock.Unlock()
+		return listener.Close()
+	}
+	n.listener = listener
+	if n.myIP.Port == 0 {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+			n.myIP.Port = uint16(addr.Port)
+		}
+	}
+	n.lock.Unlock()
+
+	if n.config.IPResolver != nil {
+		if err := n.resolveIP(); err != nil {
+			n.log.Warn("failed to resolve public IP, advertising %s: %s", n.MyIP(), err)
+		}
+		if n.config.IPResolutionFrequency > 0 {
+			go n.updateIP()
+		}
+	}
+	n.log.Info("listening for peers on %s, advertising %s", listener.Addr(), n.MyIP())
+
+	for _, ip := range n.config.Bootstrap {
+		n.Track(ip.IPDesc)
+	}
+	go n.gossipPeerLists()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-n.closed:
+				return nil
+			default:
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go func() {
+			if err := n.connect(conn, true, utils.IPDesc{}); err != nil {
+				n.log.Debug("rejected inbound connection from %s: %s", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+func (n *network) Track(ip utils.IPDesc) {
+	if !n.dialable(ip) {
+		return
+	}
+	key := ip.String()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.isClosed() {
+		return
+	}
+	n.tracked[key] = ip
+	n.startDial(key, ip)
+}
+
+// discover dials [ip], learned from a peer list, until it connects or
+// GossipedIPTimeout passes. Unlike tracked IPs, it isn't redialed once the
+// connection drops: peers that are still reachable are gossiped again.
+func (n *network) discover(ip utils.IPDesc) {
+	if !n.dialable(ip) {
+		return
+	}
+	key := ip.String()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.isClosed() || len(n.gossiped) >= n.config.MaxGossipedIPs {
+		return
+	}
+	if _, ok := n.tracked[key]; ok {
+		return
+	}
+	if _, ok := n.gossiped[key]; ok {
+		return
+	}
+	if _, ok := n.connected[key]; ok {
+		return
+	}
+	n.gossiped[key] = time.Now().Add(n.config.GossipedIPTimeout)
+	n.startDial(key, ip)
+}
+
+func (n *network) dialable(ip utils.IPDesc) bool {
+	return !ip.IsZero() && !ip.IP.IsUnspecified() && !ip.Equal(n.MyIP())
+}
+
+// startDial starts the dial loop of [ip] unless it is connected or already
+// being dialed. The caller holds the lock.
+func (n *network) startDial(key string, ip utils.IPDesc) {
+	if _, ok := n.connected[key]; ok {
+		return
+	}
+	if _, ok := n.dialing[key]; ok {
+		return
+	}
+	n.dialing[key] = struct{}{}
+	go n.dial(ip)
+}
+
+// dial connects to [ip], retrying with exponential backoff until the
+// connection succeeds, the network is closed or, for gossiped IPs, the node
+// gives up on it. The backoff of tracked IPs is kept across reconnects so
+// that a peer that drops the node right after the handshake isn't redialed in
+// a tight loop.
+func (n *network) dial(ip utils.IPDesc) {
+	key := ip.String()
+	defer func() {
+		n.lock.Lock()
+		delete(n.dialing, key)
+		delete(n.gossiped, key)
+		if _, ok := n.tracked[key]; !ok {
+			delete(n.backoff, key)
+		}
+		n.lock.Unlock()
+	}()
+
+	for {
+		n.lock.RLock()
+		delay := n.backoff[key]
+		n.lock.RUnlock()
+
+		if delay > 0 {
+			// Jitter keeps nodes that lost the same peer from redialing it
+			// in lockstep
+			wait := delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+			select {
+			case <-time.After(wait):
+			case <-n.closed:
+				return
+			}
+		}
+
+		n.lock.RLock()
+		_, connected := n.connected[key]
+		full := len(n.peers) >= n.config.MaxPeers
+		_, tracked := n.tracked[key]
+		deadline := n.gossiped[key]
+		n.lock.RUnlock()
+
+		if connected || (!tracked && time.Now().After(deadline)) {
+			return
+		}
+		if !full {
+			conn, err := n.dialer.Dial("tcp", key)
+			if err == nil {
+				err = n.connect(conn, false, ip)
+			}
+			if err == nil {
+				return
+			}
+			n.log.Verbo("failed to connect to %s: %s", ip, err)
+		}
+
+		n.lock.Lock()
+		n.backoff[key] = n.nextBackoff(n.backoff[key])
+		n.lock.Unlock()
+	}
+}
+
+func (n *network) nextBackoff(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return n.config.InitialReconnectDelay
+	}
+	if delay *= 2; delay > n.config.MaxReconnectDelay {
+		return n.config.MaxReconnectDelay
+	}
+	return delay
+}
+
+// connect runs the handshake on [conn] and registers the peer. [dialedIP] is
+// the tracked IP for outbound connections.
+func (n *network) connect(conn net.Conn, inbound bool, dialedIP utils.IPDesc) error {
+	if n.NumPeers() >= n.config.MaxPeers {
+		_ = conn.Close()
+		return errTooManyPeers
+	}
+
+	conn, certID, err := n.upgrade(conn, inbound)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	version, err := n.handshake(conn)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if n.config.TLS != nil && version.NodeID != certID {
+		_ = conn.Close()
+		return fmt.Errorf("%w: claimed %s, certificate is %s", errUnexpectedNodeID, version.NodeID, certID)
+	}
+
+	ip, confirmed := peerIP(conn, version.MyIP, inbound, dialedIP)
+	p := &peer{
+		net:          n,
+		conn:         conn,
+		nodeID:       version.NodeID,
+		ip:           ip,
+		version:      version.VersionStr,
+		inbound:      inbound,
+		confirmed:    confirmed,
+		connectedAt:  time.Now(),
+		lastReceived: time.Now().UnixNano(),
+		sendQueue:    make(chan *Message, n.config.SendQueueSize),
+		closed:       make(chan struct{}),
+	}
+
+	n.lock.Lock()
+	switch {
+	case n.isClosed():
+		err = errClosed
+	case len(n.peers) >= n.config.MaxPeers:
+		err = errTooManyPeers
+	case n.peers[p.nodeID] != nil:
+		err = errDuplicatePeer
+	}
+	if err != nil {
+		n.lock.Unlock()
+		_ = conn.Close()
+		return err
+	}
+	n.peers[p.nodeID] = p
+	n.connected[p.ip.String()] = p.nodeID
+	n.lock.Unlock()
+
+	n.log.Debug("connected to %s at %s (inbound: %t, version: %s)", p.nodeID, p.ip, inbound, p.version)
+	p.start()
+	p.send(n.peerList())
+	if n.handler != nil {
+		n.handler.Connected(p.nodeID)
+	}
+	return nil
+}
+
+func (n *network) MyIP() utils.IPDesc {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.myIP
+}
+
+// resolveIP replaces the advertised IP with the one found by the resolver
+func (n *network) resolveIP() error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.HandshakeTimeout)
+	defer cancel()
+
+	ip, err := n.config.IPResolver.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if !n.myIP.IP.Equal(ip) {
+		n.log.Info("advertised IP changed from %s to %s", n.myIP.IP, ip)
+		n.myIP.IP = ip
+	}
+	return nil
+}
+
+// updateIP periodically re-resolves the advertised IP, so that nodes behind
+// dynamic addresses stay reachable. Peers learn the new address on their
+// next handshake with the node.
+func (n *network) updateIP() {
+	ticker := time.NewTicker(n.config.IPResolutionFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := n.resolveIP(); err != nil {
+				n.log.Warn("failed to resolve public IP: %s", err)
+			}
+		case <-n.closed:
+			return
+		}
+	}
+}
+
+// upgrade runs the TLS handshake on [conn] when TLS is configured and returns
+// the node ID proven by the peer's certificate
+func (n *network) upgrade(conn net.Conn, inbound bool) (net.Conn, ids.ShortID, error) {
+	if n.config.TLS == nil {
+		return conn, ids.ShortEmpty, nil
+	}
+
+	var tlsConn *tls.Conn
+	if inbound {
+		tlsConn = tls.Server(conn, n.config.TLS)
+	} else {
+		tlsConn = tls.Client(conn, n.config.TLS)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.HandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return conn, ids.ShortEmpty, err
+	}
+
+	cert, err := staking.PeerCertificate(tlsConn.ConnectionState())
+	if err != nil {
+		return conn, ids.ShortEmpty, err
+	}
+	return tlsConn, staking.NodeID(cert), nil
+}
+
+// handshake exchanges Version messages on [conn] and validates the peer's
+// version
+func (n *network) handshake(conn net.Conn) (*Message, error) {
+	if err := conn.SetDeadline(time.Now().Add(n.config.HandshakeTimeout)); err != nil {
+		return nil, err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- writeMessage(conn, &Message{
+			Op:         Version,
+			NetworkID:  n.config.NetworkID,
+			ChainID:    n.config.ChainID,
+			NodeID:     n.config.NodeID,
+			MyIP:       n.MyIP(),
+			Timestamp:  time.Now().Unix(),
+			VersionStr: n.config.Version,
+		}, n.config.MaxMessageSize)
+	}()
+
+	version, err := readMessage(conn, n.config.MaxMessageSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+
+	switch {
+	case version.Op != Version:
+		return nil, fmt.Errorf("%w: %s", errUnexpectedOp, version.Op)
+	case version.NetworkID != n.config.NetworkID:
+		return nil, fmt.Errorf("%w: %d", errWrongNetwork, version.NetworkID)
+	case version.ChainID != n.config.ChainID:
+		return nil, fmt.Errorf("%w: %s", errWrongChain, version.ChainID)
+	case version.NodeID.IsZero():
+		return nil, errMissingNodeID
+	case version.NodeID == n.config.NodeID:
+		return nil, errSelfConnection
+	}
+	return version, conn.SetDeadline(time.Time{})
+}
+
+// peerIP returns the address other nodes can dial the peer at. Peers that
+// advertise an unspecified IP are reachable at the address they connected
+// from, on their advertised port. The address is confirmed if it was dialed
+// or its IP is the one the peer connected from; an inbound peer could
+// advertise any other.
+func peerIP(conn net.Conn, advertised utils.IPDesc, inbound bool, dialedIP utils.IPDesc) (utils.IPDesc, bool) {
+	if !inbound {
+		return dialedIP, true
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !advertised.IsZero() && !advertised.IP.IsUnspecified() {
+		return advertised, ok && advertised.IP.Equal(addr.IP)
+	}
+	if ok {
+		return utils.IPDesc{IP: addr.IP, Port: advertised.Port}, true
+	}
+	return advertised, false
+}
+
+// disconnected is called once the connection to [p] is closed
+func (n *network) disconnected(p *peer) {
+	key := p.ip.String()
+
+	n.lock.Lock()
+	if n.peers[p.nodeID] == p {
+		delete(n.peers, p.nodeID)
+		delete(n.connected, key)
+	}
+	ip, tracked := n.tracked[key]
+	switch {
+	case !tracked:
+		delete(n.backoff, key)
+	case time.Since(p.connectedAt) >= n.config.MaxReconnectDelay:
+		delete(n.backoff, key)
+	default:
+		n.backoff[key] = n.nextBackoff(n.backoff[key])
+	}
+	n.lock.Unlock()
+
+	n.log.Debug("disconnected from %s at %s", p.nodeID, p.ip)
+	if n.handler != nil {
+		n.handler.Disconnected(p.nodeID)
+	}
+	if tracked {
+		n.Track(ip)
+	}
+}
+
+// handle processes a message received from [p] after the handshake
+func (n *network) handle(p *peer, msg *Message) {
+	switch msg.Op {
+	case Version:
+		n.log.Debug("dropping %s: sent a second version message", p.nodeID)
+		p.close()
+	case GetPeerList:
+		p.send(n.peerList())
+	case PeerList:
+		peers := msg.Peers
+		if len(peers) > n.config.PeerListSize {
+			peers = peers[:n.config.PeerListSize]
+		}
+		for _, ip := range peers {
+			n.discover(ip)
+		}
+	case Ping:
+		p.send(&Message{Op: Pong})
+	case Pong:
+	default:
+		if n.handler != nil {
+			n.handler.HandleInbound(p.nodeID, msg)
+		}
+	}
+}
+
+// peerList returns a PeerList of a random sample of connected peers whose
+// address is confirmed
+func (n *network) peerList() *Message {
+	n.lock.RLock()
+	ips := make([]utils.IPDesc, 0, len(n.peers))
+	for _, p := range n.peers {
+		if p.confirmed && !p.ip.IsZero() && !p.ip.IP.IsUnspecified() {
+			ips = append(ips, p.ip)
+		}
+	}
+	n.lock.RUnlock()
+
+	rand.Shuffle(len(ips), func(i, j int) { ips[i], ips[j] = ips[j], ips[i] })
+	if len(ips) > n.config.PeerListSize {
+		ips = ips[:n.config.PeerListSize]
+	}
+	return &Message{Op: PeerList, Peers: ips}
+}
+
+func (n *network) gossipPeerLists() {
+	ticker := time.NewTicker(n.config.PeerListGossipFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.Gossip(n.peerList())
+		case <-n.closed:
+			return
+		}
+	}
+}
+
+func (n *network) Send(msg *Message, nodeIDs ...ids.ShortID) []ids.ShortID {
+	n.lock.RLock()
+	peers := make([]*peer, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if p, ok := n.peers[nodeID]; ok {
+			peers = append(peers, p)
+		}
+	}
+	n.lock.RUnlock()
+
+	return send(msg, peers)
+}
+
+func (n *network) Gossip(msg *Message) []ids.ShortID {
+	n.lock.RLock()
+	peers := make([]*peer, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p)
+	}
+	n.lock.RUnlock()
+
+	return send(msg, peers)
+}
+
+func send(msg *Message, peers []*peer) []ids.ShortID {
+	sent := make([]ids.ShortID, 0, len(peers))
+	for _, p := range peers {
+		if p.send(msg) {
+			sent = append(sent, p.nodeID)
+		}
+	}
+	return sent
+}
+
+func (n *network) Peers() []PeerInfo {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	peers := make([]PeerInfo, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, p.info())
+	}
+	return peers
+}
+
+func (n *network) NumPeers() int {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return len(n.peers)
+}
+
+func (n *network) Close() error {
+	n.closeOnce.Do(func() {
+		n.lock.Lock()
+		close(n.closed)
+		listener := n.listener
+		peers := make([]*peer, 0, len(n.peers))
+		for _, p := range n.peers {
+			peers = append(peers, p)
+		}
+		n.lock.Unlock()
+
+		if listener != nil {
+			_ = listener.Close()
+		}
+		for _, p := range peers {
+			p.close()
+		}
+	})
+	return nil
+}
+
+func (n *network) isClosed() bool {
+	select {
+	case <-n.closed:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils"
+)
+
+type peer struct {
+	net  *network
+	conn net.Conn
+
+	nodeID  ids.ShortID
+	ip      utils.IPDesc
+	version string
+	inbound bool
+	// confirmed is true if [ip] was dialed or is the address the peer
+	// connected from, so it may be passed on in peer lists
+	confirmed bool
+
+	connectedAt time.Time
+	// lastReceived is the unix nano time of the last message from the peer
+	lastReceived int64
+
+	sendQueue chan *Message
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// PeerInfo describes a connected peer
+type PeerInfo struct {
+	NodeID       ids.ShortID  `json:"nodeID"`
+	IP           utils.IPDesc `json:"ip"`
+	Version      string       `json:"version"`
+	Inbound      bool         `json:"inbound"`
+	ConnectedAt  time.Time    `json:"connectedAt"`
+	LastReceived time.Time    `json:"lastReceived"`
+}
+
+func (p *peer) info() PeerInfo {
+	return PeerInfo{
+		NodeID:       p.nodeID,
+		IP:           p.ip,
+		Version:      p.version,
+		Inbound:      p.inbound,
+		ConnectedAt:  p.connectedAt,
+		LastReceived: time.Unix(0, atomic.LoadInt64(&p.lastReceived)),
+	}
+}
+
+func (p *peer) start() {
+	go p.readMessages()
+	go p.writeMessages()
+}
+
+// send queues [msg] for the peer. It returns false if the message was dropped
+// because the peer is too far behind or disconnected.
+func (p *peer) send(msg *Message) bool {
+	select {
+	case <-p.closed:
+		return false
+	default:
+	}
+	select {
+	case p.sendQueue <- msg:
+		return true
+	default:
+		p.net.log.Debug("dropping %s to %s: send queue full", msg.Op, p.nodeID)
+		return false
+	}
+}
+
+func (p *peer) readMessages() {
+	defer p.close()
+
+	for {
+		// Pings are sent more often than the timeout, so a healthy peer
+		// always has something to read before the deadline
+		if err := p.conn.SetReadDeadline(time.Now().Add(p.net.config.PingTimeout)); err != nil {
+			return
+		}
+		msg, err := readMessage(p.conn, p.net.config.MaxMessageSize)
+		if err != nil {
+			p.net.log.Verbo("stopped reading from %s: %s", p.nodeID, err)
+			return
+		}
+		atomic.StoreInt64(&p.lastReceived, time.Now().UnixNano())
+		p.net.handle(p, msg)
+	}
+}
+
+func (p *peer) writeMessages() {
+	defer p.close()
+
+	pinger := time.NewTicker(p.net.config.PingFrequency)
+	defer pinger.Stop()
+
+	for {
+		var msg *Message
+		select {
+		case msg = <-p.sendQueue:
+		case <-pinger.C:
+			msg = &Message{Op: Ping}
+		case <-p.closed:
+			return
+		}
+
+		if err := p.conn.SetWriteDeadline(time.Now().Add(p.net.config.PingTimeout)); err != nil {
+			return
+		}
+		if err := writeMessage(p.conn, msg, p.net.config.MaxMessageSize); err != nil {
+			p.net.log.Verbo("stopped writing to %s: %s", p.nodeID, err)
+			return
+		}
+	}
+}
+
+func (p *peer) close() {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		_ = p.conn.Close()
+		p.net.disconnected(p)
+	})
+}
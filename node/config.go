@@ -0,0 +1,122 @@
+package node
+
+import (
+	"time"
+
+	"ticketsystem/main/api/auth"
+	"ticketsystem/main/api/throttle"
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+	"ticketsystem/main/snow/engine/snowman"
+	"ticketsystem/main/snow/validators"
+	"ticketsystem/main/utils/logging"
+	"ticketsystem/main/vms/ticketvm"
+
+	database "ticketsystem/main/shared/Database"
+	snowball "ticketsystem/main/snow"
+)
+
+// Consensus modes
+const (
+	// Linear decides one block of transactions at a time
+	Linear = "linear"
+	// DAG decides every ticket's conflict set in parallel
+	DAG = "dag"
+)
+
+// Config of the node
+type Config struct {
+	// DataDir holds the database, the logs and, by default, the staking key
+	// and the genesis file
+	DataDir string `json:"dataDir"`
+	// GenesisFile is the genesis the chain starts from
+	GenesisFile string `json:"genesisFile"`
+
+	// HTTPPort the API is served on
+	HTTPPort uint16 `json:"httpPort"`
+	// HTTPTLSKeyFile and HTTPTLSCertFile, if both are set, serve the API
+	// over HTTPS
+	HTTPTLSKeyFile  string `json:"httpTLSKeyFile"`
+	HTTPTLSCertFile string `json:"httpTLSCertFile"`
+	// APIAuthEnabled requires an API key or a signed request on every
+	// route that isn't public
+	APIAuthEnabled bool `json:"apiAuthEnabled"`
+	// APIKeys are the API keys accepted, mapped to who holds them. The
+	// genesis organizers' keys are always accepted for signed requests.
+	APIKeys map[string]auth.Principal `json:"apiKeys"`
+	// APIThrottleEnabled rate limits every route
+	APIThrottleEnabled bool            `json:"apiThrottleEnabled"`
+	APIThrottle        throttle.Config `json:"apiThrottle"`
+
+	// StakingEnabled authenticates peers with their staking certificates.
+	// Without it peers are trusted to report their own node ID.
+	StakingEnabled bool `json:"stakingEnabled"`
+	// StakingKeyFile and StakingCertFile are created if neither exists
+	StakingKeyFile  string `json:"stakingKeyFile"`
+	StakingCertFile string `json:"stakingCertFile"`
+
+	// Network's chain ID is taken from the genesis, and its node ID and TLS
+	// config from the staking certificate
+	Network network.Config `json:"network"`
+	// PublicIPResolver, if set, is the kind of dynamicip resolver the public
+	// IP is found with, and PublicIPResolverArg its argument
+	PublicIPResolver    string `json:"publicIPResolver"`
+	PublicIPResolverArg string `json:"publicIPResolverArg"`
+	// Beacons are the node IDs of the peers the chain is bootstrapped from,
+	// usually the nodes at Network.Bootstrap
+	Beacons          []ids.ShortID `json:"beacons"`
+	BootstrapTimeout time.Duration `json:"bootstrapTimeout"`
+
+	// ConsensusMode is Linear or DAG
+	ConsensusMode string `json:"consensusMode"`
+	// Consensus overrides the genesis' snowball parameters if K is set
+	Consensus    snowball.Parameters `json:"consensus"`
+	QueryTimeout time.Duration       `json:"queryTimeout"`
+	// ConcurrentPolls is the most polls in flight in DAG mode
+	ConcurrentPolls int                      `json:"concurrentPolls"`
+	BuildInterval   time.Duration            `json:"buildInterval"`
+	Monitor         validators.MonitorConfig `json:"monitor"`
+
+	VM      ticketvm.Config `json:"vm"`
+	DB      database.Config `json:"db"`
+	Logging logging.Config  `json:"logging"`
+
+	// GateKeyFile, if set, is the organizer key the gate API signs check-ins
+	// with, and GateKeyCacheFile a saved key cache holding the rotation
+	// keys of the organizer's events. GateFee is the fee each check-in pays.
+	GateKeyFile      string `json:"gateKeyFile"`
+	GateKeyCacheFile string `json:"gateKeyCacheFile"`
+	GateFee          int64  `json:"gateFee"`
+
+	// HealthMinPeers is the fewest connected peers the node is ready with
+	HealthMinPeers int `json:"healthMinPeers"`
+	// HealthMaxStall is the longest consensus may go without accepting a
+	// block while blocks are processing before the node is unhealthy
+	HealthMaxStall time.Duration `json:"healthMaxStall"`
+
+	// ShutdownTimeout is how long API requests being served are waited for
+	// on shutdown
+	ShutdownTimeout time.Duration `json:"shutdownTimeout"`
+}
+
+// DefaultConfig returns the config used when none is provided. Paths are
+// left empty and resolved against the data directory.
+func DefaultConfig() Config {
+	return Config{
+		HTTPPort:         9650,
+		APIThrottle:      throttle.DefaultConfig(),
+		StakingEnabled:   true,
+		Network:          network.DefaultConfig(),
+		BootstrapTimeout: snowman.DefaultBootstrapTimeout,
+		ConsensusMode:    Linear,
+		QueryTimeout:     2 * time.Second,
+		BuildInterval:    ticketvm.DefaultBuildInterval,
+		Monitor:          validators.DefaultMonitorConfig(),
+		VM:               ticketvm.DefaultConfig,
+		DB:               database.DefaultConfig(),
+		Logging:          logging.DefaultConfig(),
+		HealthMinPeers:   1,
+		HealthMaxStall:   time.Minute,
+		ShutdownTimeout:  10 * time.Second,
+	}
+}
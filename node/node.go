@@ -0,0 +1,492 @@
+// Package node wires the database, the network, consensus, the ticket chain
+// and the API into a running node.
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"ticketsystem/main/api"
+	"ticketsystem/main/api/admin"
+	"ticketsystem/main/api/auth"
+	"ticketsystem/main/api/health"
+	"ticketsystem/main/api/metrics"
+	"ticketsystem/main/api/throttle"
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+	"ticketsystem/main/snow/engine/avalanche"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/snow/engine/snowman"
+	"ticketsystem/main/snow/validators"
+	"ticketsystem/main/staking"
+	"ticketsystem/main/ticket/gate"
+	"ticketsystem/main/utils/dynamicip"
+	"ticketsystem/main/utils/logging"
+	"ticketsystem/main/vms/ticketvm"
+
+	database "ticketsystem/main/shared/Database"
+	snowball "ticketsystem/main/snow"
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+var (
+	errUnknownConsensusMode = errors.New("unknown consensus mode")
+	errNotBootstrapped      = errors.New("node is still bootstrapping")
+)
+
+// Node is a member of the ticket chain's network. It validates the chain if
+// its node ID is in the validator set and only follows it otherwise.
+type Node struct {
+	Config     Config
+	Log        logging.Logger
+	LogFactory logging.Factory
+	// ID of the node, derived from its staking certificate
+	ID ids.ShortID
+
+	genesis  *ticketvm.Genesis
+	params   snowball.Parameters
+	db       *database.TicketDatabase
+	gatherer metrics.MultiGatherer
+	net      network.Network
+	server   api.Server
+	// chainLock is held by the chain's API handlers that ask for a lock
+	chainLock sync.RWMutex
+
+	// Linear mode
+	vm           *ticketvm.VM
+	bootstrapper *snowman.Bootstrapper
+	engine       *snowman.Engine
+	// DAG mode
+	dag       *ticketvm.DAG
+	dagEngine *avalanche.Engine
+
+	// gate serves the gate API, if the node has a gate key
+	gate *gate.Gate
+
+	lock    sync.Mutex
+	builder *ticketvm.Builder
+	stopped bool
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// Initialize the node. Nothing is served until Dispatch is called. Loggers
+// are made with [logFactory], which the node doesn't close.
+func (n *Node) Initialize(config Config, logFactory logging.Factory) error {
+	if config.ConsensusMode != Linear && config.ConsensusMode != DAG {
+		return fmt.Errorf("%w: %q", errUnknownConsensusMode, config.ConsensusMode)
+	}
+	n.Config = config
+	n.LogFactory = logFactory
+	log, err := logFactory.Make("node")
+	if err != nil {
+		return err
+	}
+	n.Log = log
+
+	if n.genesis, err = ticketvm.LoadGenesis(config.GenesisFile); err != nil {
+		return err
+	}
+	n.params = n.genesis.Consensus
+	if config.Consensus.K != 0 {
+		n.params = config.Consensus
+	}
+	n.gatherer = metrics.NewMultiGatherer()
+
+	if err := n.initDatabase(); err != nil {
+		return fmt.Errorf("couldn't open the database: %w", err)
+	}
+	if err := n.initChain(); err != nil {
+		_ = n.db.Close()
+		return fmt.Errorf("couldn't initialize the chain: %w", err)
+	}
+	if err := n.initAPI(); err != nil {
+		_ = n.db.Close()
+		return fmt.Errorf("couldn't initialize the API: %w", err)
+	}
+	n.Log.Info("initialized node %s on chain %s in %s mode", n.ID, n.genesis.ChainID, config.ConsensusMode)
+	return nil
+}
+
+func (n *Node) initDatabase() error {
+	log, err := n.LogFactory.Make("db")
+	if err != nil {
+		return err
+	}
+	reg, err := metrics.NewRegistry(n.gatherer, "db")
+	if err != nil {
+		return err
+	}
+	n.db, err = database.NewTicketDatabase(filepath.Join(n.Config.DataDir, "db"), n.Config.DB, log, reg)
+	return err
+}
+
+// networkConfig returns the network's config, identifying the node by its
+// staking certificate
+func (n *Node) networkConfig() (network.Config, error) {
+	config := n.Config.Network
+	cert, err := staking.LoadTLSCert(n.Config.StakingKeyFile, n.Config.StakingCertFile, true)
+	if err != nil {
+		return config, fmt.Errorf("couldn't load the staking certificate: %w", err)
+	}
+	n.ID = staking.NodeID(cert.Leaf)
+	config.NodeID = n.ID
+	config.ChainID = n.genesis.ChainID
+	if n.Config.StakingEnabled {
+		config.TLS = staking.NewTLSConfig(cert)
+	} else {
+		n.Log.Warn("staking is disabled, peers aren't authenticated")
+	}
+	if n.Config.PublicIPResolver != "" {
+		if config.IPResolver, err = dynamicip.NewResolver(n.Config.PublicIPResolver, n.Config.PublicIPResolverArg); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+func (n *Node) initChain() error {
+	netConfig, err := n.networkConfig()
+	if err != nil {
+		return err
+	}
+	netLog, err := n.LogFactory.Make("network")
+	if err != nil {
+		return err
+	}
+	chainLog, err := n.LogFactory.MakeChain(n.genesis.ChainID, "vm")
+	if err != nil {
+		return err
+	}
+	consensusLog, err := n.LogFactory.MakeChain(n.genesis.ChainID, "consensus")
+	if err != nil {
+		return err
+	}
+	reg, err := metrics.NewRegistry(n.gatherer, "consensus")
+	if err != nil {
+		return err
+	}
+	monitor, err := validators.NewMonitor(n.Config.Monitor, consensusLog, reg, nil)
+	if err != nil {
+		return err
+	}
+
+	if n.Config.ConsensusMode == DAG {
+		n.dag = &ticketvm.DAG{}
+		if err := n.dag.Initialize(n.genesis, ticketvm.NewState(), n.db, chainLog); err != nil {
+			return err
+		}
+		// The DAG isn't bootstrapped, so its engine handles messages from
+		// the start
+		n.dagEngine = &avalanche.Engine{}
+		n.net = network.NewNetwork(netConfig, netLog, n.dagEngine)
+		vdrs := validators.NewSet()
+		if err := vdrs.Set(genesisValidators(n.genesis)); err != nil {
+			return err
+		}
+		if err := n.dagEngine.Initialize(avalanche.Config{
+			NodeID:          n.ID,
+			Params:          n.params,
+			VM:              n.dag,
+			Sender:          n.net,
+			Validators:      vdrs,
+			Monitor:         monitor,
+			Log:             consensusLog,
+			QueryTimeout:    n.Config.QueryTimeout,
+			ConcurrentPolls: n.Config.ConcurrentPolls,
+			Registerer:      reg,
+		}); err != nil {
+			return err
+		}
+		n.dag.SetIssuer(n.dagEngine)
+		return nil
+	}
+
+	n.vm = &ticketvm.VM{}
+	if err := n.vm.Initialize(n.Config.VM, n.genesis, ticketvm.NewState(), n.db, nil, chainLog); err != nil {
+		return err
+	}
+	n.engine = &snowman.Engine{}
+	n.bootstrapper = &snowman.Bootstrapper{}
+	n.net = network.NewNetwork(netConfig, netLog, n.bootstrapper)
+	n.vm.SetGossiper(n.net)
+	return n.bootstrapper.Initialize(snowman.BootstrapConfig{
+		VM:             n.vm,
+		Sender:         n.net,
+		DB:             n.db,
+		Beacons:        n.Config.Beacons,
+		Log:            consensusLog,
+		RequestTimeout: n.Config.BootstrapTimeout,
+		Engine:         n.engine,
+		OnFinished: func() error {
+			if err := n.engine.Initialize(snowman.Config{
+				NodeID:       n.ID,
+				Params:       n.params,
+				VM:           n.vm,
+				Sender:       n.net,
+				Validators:   validators.NewSet(),
+				Monitor:      monitor,
+				Log:          consensusLog,
+				QueryTimeout: n.Config.QueryTimeout,
+				Registerer:   reg,
+			}); err != nil {
+				return err
+			}
+			return n.startBuilder(chainLog)
+		},
+	})
+}
+
+// startBuilder starts building blocks once the engine is initialized
+func (n *Node) startBuilder(log logging.Logger) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.stopped {
+		return nil
+	}
+	n.builder = ticketvm.NewBuilder(n.vm, n.engine, n.Config.BuildInterval, log)
+	go n.builder.Dispatch()
+	return nil
+}
+
+func genesisValidators(genesis *ticketvm.Genesis) []validators.Validator {
+	vdrs := make([]validators.Validator, len(genesis.Validators))
+	for i, vdr := range genesis.Validators {
+		vdrs[i] = validators.Validator{
+			NodeID:   vdr.NodeID,
+			Operator: vdr.Operator,
+			Weight:   vdr.Weight,
+		}
+	}
+	return vdrs
+}
+
+func (n *Node) initAPI() error {
+	apiLog, err := n.LogFactory.Make("api")
+	if err != nil {
+		return err
+	}
+	reg, err := metrics.NewRegistry(n.gatherer, "api")
+	if err != nil {
+		return err
+	}
+
+	var (
+		chain     common.VM
+		gateChain gate.Chain
+		state     *ticketvm.State
+		progress  health.ConsensusProgress
+		reporter  admin.ConsensusReporter
+	)
+	if n.dag != nil {
+		chain, gateChain, state = n.dag, n.dag, n.dag.State()
+		progress, reporter = n.dagEngine, dagReporter{n.dagEngine}
+	} else {
+		chain, gateChain, state = n.vm, n.vm, n.vm.State()
+		progress, reporter = n.engine, linearReporter{n.engine}
+	}
+
+	var authenticator *auth.Auth
+	if n.Config.APIAuthEnabled {
+		keys := auth.NewKeyStore()
+		for key, principal := range n.Config.APIKeys {
+			principal := principal
+			if err := keys.AddAPIKey(key, &principal); err != nil {
+				return err
+			}
+		}
+		for _, organizer := range n.genesis.Organizers {
+			key, err := organizer.Key()
+			if err != nil {
+				return err
+			}
+			if err := keys.AddPublicKey(organizer.Name, key, &auth.Principal{
+				ID:    ticketvm.Address(key),
+				Roles: []auth.Role{auth.RoleOrganizer},
+			}); err != nil {
+				return err
+			}
+		}
+		authenticator = auth.New(keys, auth.DefaultPolicy(), state)
+	}
+	var throttler *throttle.Throttler
+	if n.Config.APIThrottleEnabled {
+		throttler = throttle.New(n.Config.APIThrottle)
+	}
+	if err := n.server.Initialize(apiLog, n.LogFactory, n.Config.HTTPPort, authenticator, throttler, reg); err != nil {
+		return err
+	}
+
+	n.server.RegisterChain(n.genesis.ChainID, &n.chainLock, chain)
+	if err := n.server.AddAliases("bc/"+n.genesis.ChainID.String(), "bc/tickets"); err != nil {
+		return err
+	}
+
+	if n.Config.GateKeyFile != "" {
+		if err := n.initGate(state, gateChain, apiLog); err != nil {
+			return err
+		}
+	}
+
+	adminHandler, err := admin.NewService(admin.Config{
+		Consensus:  reporter,
+		DB:         n.db,
+		Logs:       n.LogFactory,
+		ProfileDir: filepath.Join(n.Config.DataDir, "profiles"),
+	})
+	if err != nil {
+		return err
+	}
+	if err := n.server.AddRoute(adminHandler, &n.chainLock, "admin", "", apiLog); err != nil {
+		return err
+	}
+
+	h := health.New()
+	if err := h.RegisterLivenessCheck("database", health.CheckerFunc(n.db.HealthCheck)); err != nil {
+		return err
+	}
+	if err := h.RegisterReadinessCheck("database", health.CheckerFunc(n.db.HealthCheck)); err != nil {
+		return err
+	}
+	if err := h.RegisterReadinessCheck("bootstrapped", health.CheckerFunc(n.bootstrapped)); err != nil {
+		return err
+	}
+	if err := h.RegisterReadinessCheck("consensus", health.ConsensusProgressing(progress, n.Config.HealthMaxStall)); err != nil {
+		return err
+	}
+	if err := h.RegisterReadinessCheck("peers", health.PeersConnected(n.net, n.Config.HealthMinPeers)); err != nil {
+		return err
+	}
+	healthHandlers, err := health.NewHandlers(h)
+	if err != nil {
+		return err
+	}
+	for endpoint, handler := range healthHandlers {
+		if err := n.server.AddRoute(handler, &n.chainLock, "health", endpoint, apiLog); err != nil {
+			return err
+		}
+	}
+
+	return n.server.AddRoute(metrics.NewService(n.gatherer), &n.chainLock, "metrics", "", apiLog)
+}
+
+// initGate serves the gate API, which checks in the tickets scanned at the
+// gates of the events of the organizer whose key is in GateKeyFile
+func (n *Node) initGate(state *ticketvm.State, chain gate.Chain, log logging.Logger) error {
+	key, err := ticketvm.LoadKey(n.Config.GateKeyFile)
+	if err != nil {
+		return err
+	}
+	keys := qr.NewKeyCache()
+	if n.Config.GateKeyCacheFile != "" {
+		b, err := os.ReadFile(n.Config.GateKeyCacheFile)
+		if err != nil {
+			return err
+		}
+		if err := keys.UnmarshalJSON(b); err != nil {
+			return err
+		}
+	}
+	gateLog, err := n.LogFactory.Make("gate")
+	if err != nil {
+		return err
+	}
+	n.gate, err = gate.New(gate.Config{
+		Key:   key,
+		Fee:   n.Config.GateFee,
+		Keys:  keys,
+		State: state,
+		Chain: chain,
+		Log:   gateLog,
+	})
+	if err != nil {
+		return err
+	}
+	go n.gate.Dispatch()
+	n.Log.Info("serving the gate API for organizer %s", n.gate.Organizer())
+	return n.server.AddRoute(n.gate.CreateHandler(), &n.chainLock, "gate", "", log)
+}
+
+func (n *Node) bootstrapped() (interface{}, error) {
+	if n.bootstrapper != nil && !n.bootstrapper.Finished() {
+		return nil, errNotBootstrapped
+	}
+	return nil, nil
+}
+
+// Dispatch connects to the network and serves the API. It blocks until the
+// node is shut down or either stops with an error, in which case the node is
+// shut down.
+func (n *Node) Dispatch() error {
+	errs := make(chan error, 2)
+	go func() {
+		n.Log.Info("serving the API on port %d", n.Config.HTTPPort)
+		if n.Config.HTTPTLSKeyFile != "" && n.Config.HTTPTLSCertFile != "" {
+			errs <- n.server.DispatchTLS(n.Config.HTTPTLSCertFile, n.Config.HTTPTLSKeyFile)
+		} else {
+			errs <- n.server.Dispatch()
+		}
+	}()
+	go func() {
+		errs <- n.net.Dispatch()
+	}()
+
+	err := <-errs
+	if err != nil {
+		n.Log.Error("stopping: %s", err)
+	}
+	if shutdownErr := n.Shutdown(); err == nil {
+		err = shutdownErr
+	}
+	<-errs
+	return err
+}
+
+// Shutdown stops the node: the API stops taking requests, consensus stops,
+// the network is closed and the database's write buffer is drained to disk.
+// It is safe to call more than once.
+func (n *Node) Shutdown() error {
+	n.shutdownOnce.Do(func() {
+		n.Log.Info("shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), n.Config.ShutdownTimeout)
+		defer cancel()
+		if err := n.server.Shutdown(ctx); err != nil {
+			n.Log.Warn("API requests were still being served: %s", err)
+		}
+
+		n.lock.Lock()
+		n.stopped = true
+		if n.builder != nil {
+			n.builder.Close()
+		}
+		n.lock.Unlock()
+		if n.gate != nil {
+			n.gate.Close()
+		}
+		if n.dagEngine != nil {
+			n.dagEngine.Shutdown()
+		}
+		if n.bootstrapper != nil {
+			n.bootstrapper.Shutdown()
+			n.engine.Shutdown()
+		}
+		if err := n.net.Close(); err != nil {
+			n.Log.Warn("failed to close the network: %s", err)
+		}
+
+		// Accepted blocks may still be in the write buffer
+		if err := n.db.Close(); err != nil {
+			n.Log.Error("failed to close the database: %s", err)
+			n.shutdownErr = err
+			return
+		}
+		n.Log.Info("shut down")
+	})
+	return n.shutdownErr
+}
@@ -0,0 +1,50 @@
+package node
+
+import (
+	"ticketsystem/main/api/admin"
+	"ticketsystem/main/snow/engine/avalanche"
+	"ticketsystem/main/snow/engine/snowman"
+)
+
+// linearReporter reports the blocks the snowman engine is deciding to the
+// admin API
+type linearReporter struct {
+	engine *snowman.Engine
+}
+
+func (r linearReporter) Processing() []admin.BlockState {
+	decisions := r.engine.Processing()
+	states := make([]admin.BlockState, len(decisions))
+	for i, decision := range decisions {
+		states[i] = admin.BlockState{
+			BlockID:    decision.BlockID.String(),
+			Height:     decision.Height,
+			Preference: decision.Preference.String(),
+			Confidence: decision.Confidence,
+			Finalized:  decision.Finalized,
+		}
+	}
+	return states
+}
+
+// dagReporter reports the vertices the avalanche engine is deciding to the
+// admin API, once for each conflict set they are in. Vertices have no
+// height.
+type dagReporter struct {
+	engine *avalanche.Engine
+}
+
+func (r dagReporter) Processing() []admin.BlockState {
+	var states []admin.BlockState
+	for _, decision := range r.engine.Processing() {
+		for _, member := range decision.Members {
+			states = append(states, admin.BlockState{
+				BlockID:    member.String(),
+				Preference: decision.Preference.String(),
+				Confidence: decision.Confidence,
+				Finalized:  decision.Finalized,
+			})
+		}
+	}
+	return states
+}
@@ -1,242 +1,299 @@
 package database
 
 import (
-	"context"
-	"fmt"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/cache"
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/utils/logging"
 )
 
+// ErrNotFound is returned by Get when the key isn't in the database
+var ErrNotFound = common.ErrNotFound
+
+var errClosed = errors.New("database is closed")
+
+// Config of the database
+type Config struct {
+	// BlockCacheSize is the size of leveldb's block cache, in bytes
+	BlockCacheSize int `json:"blockCacheSize"`
+	// WriteBufferSize is the size of leveldb's memtable, in bytes
+	WriteBufferSize int `json:"writeBufferSize"`
+	// ReadCacheSize is the number of values kept in the read cache
+	ReadCacheSize int `json:"readCacheSize"`
+	// MaxBatchSize is the number of bytes buffered before the buffer is
+	// flushed to disk
+	MaxBatchSize int `json:"maxBatchSize"`
+	// FlushInterval is the longest a write stays buffered
+	FlushInterval time.Duration `json:"flushInterval"`
+}
+
+// DefaultConfig returns the config used when none is provided
+func DefaultConfig() Config {
+	return Config{
+		BlockCacheSize:  64 * 1024 * 1024,
+		WriteBufferSize: 32 * 1024 * 1024,
+		ReadCacheSize:   16 * 1024,
+		MaxBatchSize:    16 * 1024 * 1024,
+		FlushInterval:   100 * time.Millisecond,
+	}
+}
+
+// pendingWrite is a buffered Put or, if [deleted] is set, Delete
+type pendingWrite struct {
+	value   []byte
+	deleted bool
+}
+
+// TicketDatabase is the leveldb database of the node. Writes are buffered and
+// flushed to disk in order, one atomic batch at a time, so a crash loses the
+// latest writes but never leaves a later write on disk without an earlier
+// one. Buffered writes are visible to reads.
 type TicketDatabase struct {
-	db               *leveldb.DB
-	writeBuffer      chan *leveldb.Batch
-	writeBufferBytes *atomic.Value
-	readCache        *cache.Cache
-	writeAheadLog    *WriteAheadLog
-	backgroundTask   *BackgroundTask
-	keyBufferPool    *sync.Pool
-	valueBufferPool  *sync.Pool
-	redisPool        *redis.Pool
-	frequencyCache   *redisCache
+	log    logging.Logger
+	config Config
+	db     *leveldb.DB
+
+	lock sync.RWMutex
+	// batch holds the buffered writes and pending indexes them by key
+	batch   *leveldb.Batch
+	pending map[string]pendingWrite
+	// flushing are the writes of the batch being written to disk
+	flushing map[string]pendingWrite
+	// writes counts Put and Delete calls, so that a value read from disk
+	// isn't cached if the key may have been written in the meantime
+	writes    uint64
+	readCache *lru
+	closed    bool
+
+	// flushLock serializes flushes so batches reach disk in order
+	flushLock sync.Mutex
+	quit      chan struct{}
+	done      chan struct{}
+
+	cacheHits    uint64
+	cacheMisses  uint64
+	healthChecks uint64
+	metrics      *metrics
 }
 
-func NewTicketDatabase(file string, cacheSize, writeBufferSize int, useWriteAheadLog bool, redisURL string) (*TicketDatabase, error) {
-	opts := &opt.Options{
-		Filter:                filter.NewBloomFilter(10),
-		BlockCache:            cache.NewLRU(cacheSize),
-		WriteBuffer:           writeBufferSize,
-		CompactionL0:          &opt.LevelCompaction{TargetFileSize: 64 * 1024 * 1024},
-		CompactionL0Trigger:   8,
-		CompactionTotalSize:   512 * 1024 * 1024,
-		CompactionConcurrency: 4,
-		Compression:           opt.SnappyCompression,
-	}
-	db, err := leveldb.OpenFile(file, opts)
+// NewTicketDatabase opens, or creates, the database in [dir]
+func NewTicketDatabase(dir string, config Config, log logging.Logger, reg prometheus.Registerer) (*TicketDatabase, error) {
+	db, err := leveldb.OpenFile(dir, &opt.Options{
+		Filter:             filter.NewBloomFilter(10),
+		BlockCacheCapacity: config.BlockCacheSize,
+		WriteBuffer:        config.WriteBufferSize,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var writeAheadLog *WriteAheadLog
-	if useWriteAheadLog {
-		writeAheadLog, err = NewWriteAheadLog(file + ".wal")
-		if err != nil {
-			db.Close()
-			return nil, err
-		}
-	}
-
-	backgroundTask := NewBackgroundTask(db, writeAheadLog)
-
 	td := &TicketDatabase{
-		db:               db,
-		writeBuffer:      make(chan *leveldb.Batch, 16),
-		writeBufferBytes: &atomic.Value{},
-		readCache:        cache.NewLRU(cacheSize),
-		writeAheadLog:    writeAheadLog,
-		backgroundTask:   backgroundTask,
-		keyBufferPool: &sync.Pool{
-			New: func() interface{} {
-				return make([]byte, 0, 64)
-			},
-		},
-		valueBufferPool: &sync.Pool{
-			New: func() interface{} {
-				return make([]byte, 0, 1024)
-			},
-		},
-	}
-
-	if redisURL != "" {
-		td.redisPool = &redis.Pool{
-			MaxIdle:     10,
-			MaxActive:   100,
-			IdleTimeout: 5 * time.Minute,
-			Dial: func() (redis.Conn, error) {
-				return redis.DialURL(redisURL)
-			},
-		}
-
-		td.frequencyCache = newRedisCache(td.redisPool, "frequency", 24*time.Hour)
+		log:       log,
+		config:    config,
+		db:        db,
+		batch:     new(leveldb.Batch),
+		pending:   make(map[string]pendingWrite),
+		readCache: newLRU(config.ReadCacheSize),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
 	}
-
-	for i := 0; i < 16; i++ {
-		go td.writeWorker()
+	td.metrics, err = newMetrics(td, reg)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
 	}
-
-	go td.backgroundTask.Start()
-
+	go td.flushPeriodically()
 	return td, nil
 }
 
-func (td *TicketDatabase) Put(key, value []byte) {
-	// Get key buffer from pool
-	keyLen := len(key)
-	keyBuf := td.keyBufferPool.Get().([]byte)[:0]
-	keyBuf = append(keyBuf, key...)
-
-	// Get value buffer from pool
-	valueLen := len(value)
-	valueBuf := td.valueBufferPool.Get().([]byte)[:0]
-	valueBuf = append(valueBuf, value...)
-
-	// Add write to batch
-	writeBatch := td.writeBufferBytes.Load().(*leveldb.Batch)
-	writeBatch.Put(keyBuf, valueBuf)
-
-	// Return key and value buffers to pool
-	td.keyBufferPool.Put(keyBuf[:0])
-	td.valueBufferPool.Put(valueBuf[:0])
-
-	// Flush write buffer if it reaches a certain size
-	if writeBatch.ValueSize()+keyLen+valueLen > 16*1024*1024 {
-		td.flushWriteBuffer()
+// Has implements the common.Database interface
+func (td *TicketDatabase) Has(key []byte) (bool, error) {
+	_, err := td.Get(key)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	default:
+		return false, err
 	}
 }
 
+// Get implements the common.Database interface
 func (td *TicketDatabase) Get(key []byte) ([]byte, error) {
-	// Check read cache first
-	if val, ok := td.readCache.Get(string(key)); ok {
-		return val.([]byte), nil
-	} // Check frequency cache
-	if td.frequencyCache != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-		defer cancel()
-		if val, err := td.frequencyCache.get(ctx, string(key)); err == nil {
-			td.readCache.Add(string(key), val)
-			return val, nil
+	td.lock.Lock()
+	if td.closed {
+		td.lock.Unlock()
+		return nil, errClosed
+	}
+	if write, ok := td.buffered(string(key)); ok {
+		td.lock.Unlock()
+		if write.deleted {
+			return nil, ErrNotFound
 		}
+		return copyBytes(write.value), nil
 	}
+	if value, ok := td.readCache.get(string(key)); ok {
+		td.lock.Unlock()
+		atomic.AddUint64(&td.cacheHits, 1)
+		return copyBytes(value), nil
+	}
+	writes := td.writes
+	td.lock.Unlock()
+	atomic.AddUint64(&td.cacheMisses, 1)
 
-	// Otherwise, check LevelDB database
-	val, err := td.db.Get(key, nil)
+	value, err := td.db.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		if err == leveldb.ErrNotFound {
-			return nil, ErrNotFound
-		}
 		return nil, err
 	}
 
-	// Update read cache
-	td.readCache.Add(string(key), val)
+	td.lock.Lock()
+	if td.writes == writes {
+		td.readCache.put(string(key), value)
+	}
+	td.lock.Unlock()
+	return copyBytes(value), nil
+}
 
-	return val, nil
+// buffered returns the latest buffered write of [key]. The caller holds the
+// lock.
+func (td *TicketDatabase) buffered(key string) (pendingWrite, bool) {
+	if write, ok := td.pending[key]; ok {
+		return write, true
+	}
+	write, ok := td.flushing[key]
+	return write, ok
 }
 
-func (td *TicketDatabase) Delete(key []byte) error {
-	// Add write to batch
-	writeBatch := td.writeBufferBytes.Load().(*leveldb.Batch)
-	writeBatch.Delete(key)
+// Put implements the common.Database interface
+func (td *TicketDatabase) Put(key, value []byte) error {
+	return td.write(key, pendingWrite{value: copyBytes(value)})
+}
 
-	// Remove key from read cache
-	td.readCache.Remove(string(key))
+// Delete implements the common.Database interface
+func (td *TicketDatabase) Delete(key []byte) error {
+	return td.write(key, pendingWrite{deleted: true})
+}
 
+func (td *TicketDatabase) write(key []byte, write pendingWrite) error {
+	td.lock.Lock()
+	if td.closed {
+		td.lock.Unlock()
+		return errClosed
+	}
+	if write.deleted {
+		td.batch.Delete(key)
+	} else {
+		td.batch.Put(key, write.value)
+	}
+	td.pending[string(key)] = write
+	td.readCache.remove(string(key))
+	td.writes++
+	full := len(td.batch.Dump()) >= td.config.MaxBatchSize
+	td.lock.Unlock()
+
+	if full {
+		return td.Flush()
+	}
 	return nil
 }
 
-func (td *TicketDatabase) writeWorker() {
-	for writeBatch := range td.writeBuffer {
-		err := td.db.Write(writeBatch, &opt.WriteOptions{Sync: false})
-		if err != nil {
-			fmt.Println("Error flushing write buffer:", err)
-		}
-		// Write to write-ahead log, if enabled
-		if td.writeAheadLog != nil {
-			err = td.writeAheadLog.Write(writeBatch)
-			if err != nil {
-				fmt.Println("Error writing to write-ahead log:", err)
-			}
-		}
+// Flush writes the buffered writes to disk
+func (td *TicketDatabase) Flush() error {
+	td.flushLock.Lock()
+	defer td.flushLock.Unlock()
 
-		// Clear batch and update byte count
-		writeBatch.Reset()
-		td.writeBufferBytes.Store(writeBatch)
+	td.lock.Lock()
+	batch := td.batch
+	if batch.Len() == 0 {
+		td.lock.Unlock()
+		return nil
 	}
-	// Write to write-ahead log, if enabled
-	if td.writeAheadLog != nil {
-		err = td.writeAheadLog.Write(writeBatch)
-		if err != nil {
-			fmt.Println("Error writing to write-ahead log:", err)
+	td.flushing = td.pending
+	td.batch = new(leveldb.Batch)
+	td.pending = make(map[string]pendingWrite)
+	td.lock.Unlock()
+
+	td.metrics.batchSize.Observe(float64(batch.Len()))
+	start := time.Now()
+	err := td.db.Write(batch, nil)
+	td.metrics.flushLatency.Observe(time.Since(start).Seconds())
+
+	td.lock.Lock()
+	if err != nil {
+		// Merge the writes back with the ones made since, which take
+		// precedence, so they are retried by the next flush
+		for key, write := range td.pending {
+			td.flushing[key] = write
+		}
+		td.batch = new(leveldb.Batch)
+		for key, write := range td.flushing {
+			if write.deleted {
+				td.batch.Delete([]byte(key))
+			} else {
+				td.batch.Put([]byte(key), write.value)
+			}
 		}
+		td.pending = td.flushing
 	}
-
-	// Clear batch and update byte count
-	writeBatch.Reset()
-	td.writeBufferBytes.Store(writeBatch)
-}
+	td.flushing = nil
+	td.lock.Unlock()
+	return err
 }
 
-func (td *TicketDatabase) flushWriteBuffer() {
-	// Swap write buffer
-	writeBatch := td.writeBufferBytes.Load().(*leveldb.Batch)
-	newWriteBatch := new(leveldb.Batch)
-	td.writeBufferBytes.Store(newWriteBatch)
+func (td *TicketDatabase) flushPeriodically() {
+	defer close(td.done)
 
-	// Send old write buffer to write worker
-	td.writeBuffer <- writeBatch
+	ticker := time.NewTicker(td.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := td.Flush(); err != nil {
+				td.log.Error("failed to flush write buffer: %s", err)
+			}
+		case <-td.quit:
+			return
+		}
+	}
 }
 
+// Close drains the write buffer to disk and closes the database
 func (td *TicketDatabase) Close() error {
-	close(td.writeBuffer)
-	td.backgroundTask.Stop()
-	err := td.db.Close()
-	if err != nil {
-		return err
+	td.lock.Lock()
+	if td.closed {
+		td.lock.Unlock()
+		return errClosed
 	}
+	td.lock.Unlock()
 
-	if td.writeAheadLog != nil {
-		err = td.writeAheadLog.Close()
-		if err != nil {
-			return err
-		}
-	}
+	close(td.quit)
+	<-td.done
+	flushErr := td.Flush()
 
-	if td.redisPool != nil {
-		td.redisPool.Close()
-	}
+	td.lock.Lock()
+	td.closed = true
+	td.lock.Unlock()
 
-	return nil
-	err := td.db.Close()
-	if err != nil {
+	if err := td.db.Close(); err != nil {
 		return err
 	}
+	return flushErr
+}
 
-	if td.writeAheadLog != nil {
-		err = td.writeAheadLog.Close()
-		if err != nil {
-			return err
-		}
-	}
-
-	if td.redisPool != nil {
-		td.redisPool.Close()
-	}
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
 
-	return nil
-}
\ No newline at end of file
+var _ common.Database = &TicketDatabase{}
@@ -0,0 +1,57 @@
+package database
+
+import "container/list"
+
+// lru is a least recently used cache of values read from disk. It isn't safe
+// for concurrent use.
+type lru struct {
+	size     int
+	elements map[string]*list.Element
+	order    *list.List
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:     size,
+		elements: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *lru) get(key string) ([]byte, bool) {
+	elem, ok := c.elements[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).value, true
+}
+
+func (c *lru) put(key string, value []byte) {
+	if c.size <= 0 {
+		return
+	}
+	if elem, ok := c.elements[key]; ok {
+		elem.Value.(*lruEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.elements[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elements, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lru) remove(key string) {
+	if elem, ok := c.elements[key]; ok {
+		c.order.Remove(elem)
+		delete(c.elements, key)
+	}
+}
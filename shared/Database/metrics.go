@@ -0,0 +1,50 @@
+package database
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metrics struct {
+	// batchSize is the number of operations in each flushed write batch
+	batchSize prometheus.Histogram
+	// flushLatency is how long writing a batch to leveldb takes
+	flushLatency prometheus.Histogram
+}
+
+// newMetrics registers the database metrics in [reg]. Cache hits and misses
+// are read from the counters kept for Stats so they are never out of sync.
+func newMetrics(td *TicketDatabase, reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "write_batch_size",
+			Help:    "Number of operations in each write batch flushed to disk",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+		}),
+		flushLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "flush_latency_seconds",
+			Help:    "Time taken to write a batch to disk",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+	}
+	cacheHits := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cache_hits",
+		Help: "Number of reads served from the read cache",
+	}, func() float64 {
+		return float64(atomic.LoadUint64(&td.cacheHits))
+	})
+	cacheMisses := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "cache_misses",
+		Help: "Number of reads that had to go to disk",
+	}, func() float64 {
+		return float64(atomic.LoadUint64(&td.cacheMisses))
+	})
+
+	for _, c := range []prometheus.Collector{m.batchSize, m.flushLatency, cacheHits, cacheMisses} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
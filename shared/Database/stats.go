@@ -0,0 +1,84 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// healthCheckKey is written and read back to verify the database is writable.
+// The prefix keeps it out of the ticket key space.
+var healthCheckKey = []byte("\x00health")
+
+var errHealthCheckMismatch = errors.New("health check read back a different value")
+
+// Stats describes the size and cache efficiency of the database
+type Stats struct {
+	SizeBytes    int64   `json:"sizeBytes"`
+	CacheHits    uint64  `json:"cacheHits"`
+	CacheMisses  uint64  `json:"cacheMisses"`
+	CacheHitRate float64 `json:"cacheHitRate"`
+	// Pending is the number of writes buffered but not yet flushed
+	Pending int `json:"pending"`
+	// LevelDB is the leveldb compaction table, as reported by leveldb.stats
+	LevelDB string `json:"leveldb"`
+}
+
+// Stats returns the current size and cache statistics of the database
+func (td *TicketDatabase) Stats() (Stats, error) {
+	// SizeOf can't measure an unbounded range, so sum the size of the tables
+	// on every level instead
+	var dbStats leveldb.DBStats
+	if err := td.db.Stats(&dbStats); err != nil {
+		return Stats{}, err
+	}
+	var size int64
+	for _, levelSize := range dbStats.LevelSizes {
+		size += levelSize
+	}
+	levelStats, err := td.db.GetProperty("leveldb.stats")
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{
+		SizeBytes:   size,
+		CacheHits:   atomic.LoadUint64(&td.cacheHits),
+		CacheMisses: atomic.LoadUint64(&td.cacheMisses),
+		LevelDB:     levelStats,
+	}
+	td.lock.RLock()
+	stats.Pending = td.batch.Len()
+	td.lock.RUnlock()
+	if lookups := stats.CacheHits + stats.CacheMisses; lookups > 0 {
+		stats.CacheHitRate = float64(stats.CacheHits) / float64(lookups)
+	}
+	return stats, nil
+}
+
+// Compact compacts the whole key space of the underlying leveldb
+func (td *TicketDatabase) Compact() error {
+	return td.db.CompactRange(util.Range{})
+}
+
+// HealthCheck writes a value straight to leveldb, bypassing the write buffer,
+// and reads it back. It fails if the disk is full or read-only.
+func (td *TicketDatabase) HealthCheck() (interface{}, error) {
+	value := []byte(fmt.Sprintf("%d", atomic.AddUint64(&td.healthChecks, 1)))
+	if err := td.db.Put(healthCheckKey, value, &opt.WriteOptions{Sync: true}); err != nil {
+		return nil, err
+	}
+	stored, err := td.db.Get(healthCheckKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(stored, value) {
+		return nil, errHealthCheckMismatch
+	}
+	return td.Stats()
+}
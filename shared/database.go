@@ -1,10 +0,0 @@
-package shared
-
-type database struct {
-	_db ticketdb.Database
-}
-
-type DBConfig struct {
-	DataDir string
-	Name    string
-}
@@ -2,10 +2,9 @@ package snowball
 
 import (
 	"fmt"
-)
 
-// import ids
-// TODO: Ticket ids and multiset arrange for better performance and production
+	"ticketsystem/main/ids"
+)
 
 // Consensus represents a general snow instance that can be used directly to
 // process the results of network queries.
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Latency tracks how long blocks take to be decided
+type Latency interface {
+	// Issued marks [blockID] as processing from [issuedAt]
+	Issued(blockID string, issuedAt time.Time)
+	// Accepted marks [blockID] as finalized
+	Accepted(blockID string)
+	// Rejected marks [blockID] as rejected
+	Rejected(blockID string)
+	// NumProcessing returns the number of blocks issued but not yet decided
+	NumProcessing() int
+}
+
+type latency struct {
+	lock       sync.Mutex
+	clock      func() time.Time
+	processing map[string]time.Time
+
+	numProcessing prometheus.Gauge
+	// finality is the time from issuance to acceptance
+	finality  prometheus.Histogram
+	rejection prometheus.Histogram
+}
+
+// NewLatency registers the finality metrics in [reg]
+func NewLatency(reg prometheus.Registerer) (Latency, error) {
+	l := &latency{
+		clock:      time.Now,
+		processing: make(map[string]time.Time),
+		numProcessing: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "blocks_processing",
+			Help: "Number of blocks issued but not yet decided",
+		}),
+		finality: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "time_to_finality_seconds",
+			Help:    "Time from a block being issued to it being accepted",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+		}),
+		rejection: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "time_to_rejection_seconds",
+			Help:    "Time from a block being issued to it being rejected",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+		}),
+	}
+	for _, c := range []prometheus.Collector{l.numProcessing, l.finality, l.rejection} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (l *latency) Issued(blockID string, issuedAt time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, ok := l.processing[blockID]; ok {
+		return
+	}
+	l.processing[blockID] = issuedAt
+	l.numProcessing.Set(float64(len(l.processing)))
+}
+
+func (l *latency) Accepted(blockID string) {
+	l.decided(blockID, l.finality)
+}
+
+func (l *latency) Rejected(blockID string) {
+	l.decided(blockID, l.rejection)
+}
+
+func (l *latency) decided(blockID string, histogram prometheus.Histogram) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	issuedAt, ok := l.processing[blockID]
+	if !ok {
+		return
+	}
+	delete(l.processing, blockID)
+	histogram.Observe(l.clock().Sub(issuedAt).Seconds())
+	l.numProcessing.Set(float64(len(l.processing)))
+}
+
+func (l *latency) NumProcessing() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.processing)
+}
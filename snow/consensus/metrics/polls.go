@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Polls reports the outcome of the network polls issued by consensus
+type Polls interface {
+	Issued()
+	Successful()
+	Failed()
+}
+
+type polls struct {
+	// numIssued keeps track of the number of polls sent to the network
+	numIssued prometheus.Counter
+	// numSuccessful keeps track of the number of polls that reached alpha
+	numSuccessful prometheus.Counter
+	// numFailed keeps track of the number of polls that didn't reach alpha
+	numFailed prometheus.Counter
+}
+
+// NewPolls registers the poll counters in [reg]
+func NewPolls(reg prometheus.Registerer) (Polls, error) {
+	p := &polls{
+		numIssued: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "polls_issued",
+			Help: "Number of polls issued",
+		}),
+		numSuccessful: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "polls_successful",
+			Help: "Number of polls that collected at least alpha votes for one choice",
+		}),
+		numFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "polls_failed",
+			Help: "Number of polls that finished without alpha votes for any choice",
+		}),
+	}
+	for _, c := range []prometheus.Collector{p.numIssued, p.numSuccessful, p.numFailed} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *polls) Issued() {
+	p.numIssued.Inc()
+}
+
+func (p *polls) Successful() {
+	p.numSuccessful.Inc()
+}
+
+func (p *polls) Failed() {
+	p.numFailed.Inc()
+}
@@ -0,0 +1,728 @@
+package avalanche
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+	"ticketsystem/main/snow/consensus/metrics"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/snow/validators"
+	"ticketsystem/main/utils/logging"
+
+	snowball "ticketsystem/main/snow"
+)
+
+var (
+	errMissingParent  = errors.New("missing parent")
+	errRejectedParent = errors.New("parent was rejected")
+	errShutdown       = errors.New("engine is shut down")
+)
+
+const (
+	// DefaultConcurrentPolls is the number of polls in flight by default
+	DefaultConcurrentPolls = 64
+
+	// maxDecided is the number of decided conflict sets whose winner is
+	// remembered for peers still deciding them
+	maxDecided = 16384
+)
+
+// Config of the engine
+type Config struct {
+	// NodeID of this node, which is never polled
+	NodeID ids.ShortID
+	Params snowball.Parameters
+	VM     DAGVM
+	Sender common.Sender
+	// Validators are sampled by stake for polls
+	Validators validators.Set
+	// Monitor, if set, is told which validators are sampled
+	Monitor *validators.Monitor
+	// Sampler picks the peers polled. If nil, validators are sampled by
+	// stake or, without a validator set, connected peers uniformly.
+	Sampler common.Sampler
+	Log     logging.Logger
+	// QueryTimeout is how long a poll waits for chits. Peers that haven't
+	// answered by then are counted as not voting.
+	QueryTimeout time.Duration
+	// ConcurrentPolls is the most polls in flight. Each poll is about one
+	// vertex, so unrelated conflict sets are decided in parallel. Zero means
+	// DefaultConcurrentPolls.
+	ConcurrentPolls int
+	Registerer      prometheus.Registerer
+}
+
+// Decision is the snowball state of a conflict set being decided
+type Decision struct {
+	ConflictSet string
+	Members     []ids.ID
+	Preference  ids.ID
+	Confidence  int
+	Finalized   bool
+}
+
+// Engine runs snowball over every conflict set of the DAG at once. A vertex
+// is polled until it has been decided in each of its conflict sets, and
+// polls about vertices in unrelated conflict sets run concurrently.
+type Engine struct {
+	config  Config
+	uniform *common.UniformSampler
+
+	lock sync.Mutex
+	// vertices being decided
+	vertices map[ids.ID]*vertex
+	sets     map[string]*conflictSet
+	// decided maps each decided conflict set to its winner, so that peers
+	// still deciding the set can be answered after its vertices are gone.
+	// Only the last maxDecided sets are kept, oldest first in decidedOrder.
+	decided      map[string]ids.ID
+	decidedOrder []decidedSet
+	// queue of vertices waiting to be polled
+	queue     []ids.ID
+	polls     map[uint32]*poll
+	requestID uint32
+	// retrying is true while polls are delayed for lack of peers
+	retrying bool
+	// shutdown is set once the engine stops taking part in consensus
+	shutdown bool
+
+	lastAcceptedTime time.Time
+	pollMetrics      metrics.Polls
+	latency          metrics.Latency
+}
+
+type vertex struct {
+	vtx  Vertex
+	sets []string
+	// won is the number of conflict sets the vertex was decided in
+	won      int
+	children []ids.ID
+}
+
+type decidedSet struct {
+	key    string
+	winner ids.ID
+}
+
+type conflictSet struct {
+	consensus *snowball.Flat
+	// members are the vertices of the set that are still processing
+	members map[ids.ID]struct{}
+	decided bool
+}
+
+type poll struct {
+	vtxID   ids.ID
+	pending map[ids.ShortID]struct{}
+	votes   ids.Bag
+	timer   *time.Timer
+}
+
+// Initialize the engine. It must be called before the network is dispatched.
+func (e *Engine) Initialize(config Config) error {
+	if err := config.Params.Verify(); err != nil {
+		return err
+	}
+	pollMetrics, err := metrics.NewPolls(config.Registerer)
+	if err != nil {
+		return err
+	}
+	latency, err := metrics.NewLatency(config.Registerer)
+	if err != nil {
+		return err
+	}
+
+	e.config = config
+	if e.config.ConcurrentPolls <= 0 {
+		e.config.ConcurrentPolls = DefaultConcurrentPolls
+	}
+	switch {
+	case config.Sampler != nil:
+	case config.Validators != nil:
+		e.config.Sampler = common.NewValidatorSampler(config.Validators, config.NodeID, config.Monitor)
+	default:
+		e.uniform = common.NewUniformSampler()
+		e.config.Sampler = e.uniform
+	}
+	e.vertices = make(map[ids.ID]*vertex)
+	e.sets = make(map[string]*conflictSet)
+	e.decided = make(map[string]ids.ID)
+	e.polls = make(map[uint32]*poll)
+	e.lastAcceptedTime = time.Now()
+	e.pollMetrics = pollMetrics
+	e.latency = latency
+	return nil
+}
+
+// Issue adds a vertex built by this node and gossips it to peers
+func (e *Engine) Issue(vtx Vertex) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.shutdown {
+		return errShutdown
+	}
+	if err := e.add(vtx); err != nil {
+		return err
+	}
+	e.config.Sender.Gossip(&network.Message{
+		Op:      network.Put,
+		BlockID: vtx.ID(),
+		Block:   vtx.Bytes(),
+	})
+	return nil
+}
+
+// Connected implements the network.Handler interface
+func (e *Engine) Connected(nodeID ids.ShortID) {
+	if e.uniform != nil {
+		e.uniform.Connected(nodeID)
+	}
+}
+
+// Disconnected implements the network.Handler interface
+func (e *Engine) Disconnected(nodeID ids.ShortID) {
+	if e.uniform != nil {
+		e.uniform.Disconnected(nodeID)
+	}
+}
+
+// HandleInbound implements the network.Handler interface
+func (e *Engine) HandleInbound(nodeID ids.ShortID, msg *network.Message) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.shutdown {
+		return
+	}
+	switch msg.Op {
+	case network.Get:
+		e.get(nodeID, msg)
+	case network.Put:
+		e.put(nodeID, msg)
+	case network.PushQuery:
+		e.put(nodeID, msg)
+		e.chits(nodeID, msg)
+	case network.PullQuery:
+		e.chits(nodeID, msg)
+	case network.Chits:
+		e.vote(nodeID, msg)
+	default:
+		e.config.Log.Debug("dropping unexpected %s from %s", msg.Op, nodeID)
+	}
+}
+
+func (e *Engine) get(nodeID ids.ShortID, msg *network.Message) {
+	vtx, err := e.config.VM.GetVertex(msg.BlockID)
+	if err != nil {
+		e.config.Log.Verbo("%s asked for unknown vertex %s", nodeID, msg.BlockID)
+		return
+	}
+	e.config.Sender.Send(&network.Message{
+		Op:        network.Put,
+		RequestID: msg.RequestID,
+		BlockID:   vtx.ID(),
+		Block:     vtx.Bytes(),
+	}, nodeID)
+}
+
+func (e *Engine) put(nodeID ids.ShortID, msg *network.Message) {
+	if len(msg.Block) == 0 {
+		return
+	}
+	vtx, err := e.config.VM.ParseVertex(msg.Block)
+	if err != nil {
+		e.config.Log.Debug("failed to parse vertex from %s: %s", nodeID, err)
+		return
+	}
+	err = e.add(vtx)
+	switch {
+	case errors.Is(err, errMissingParent):
+		// The vertex is dropped, but the sender keeps polling it, so it
+		// is received again once its parents are fetched
+		e.fetchParents(nodeID, vtx)
+	case err != nil:
+		e.config.Log.Debug("dropping vertex %s from %s: %s", vtx.ID(), nodeID, err)
+	}
+}
+
+// fetchParents asks [nodeID] for the parents of [vtx] this node hasn't seen
+func (e *Engine) fetchParents(nodeID ids.ShortID, vtx Vertex) {
+	for _, parentID := range vtx.Parents() {
+		if _, ok := e.vertices[parentID]; ok {
+			continue
+		}
+		if _, err := e.config.VM.GetVertex(parentID); err == nil {
+			continue
+		}
+		e.config.Sender.Send(&network.Message{
+			Op:      network.Get,
+			BlockID: parentID,
+		}, nodeID)
+	}
+}
+
+// chits answers a query about a vertex with this node's preference in each of
+// the vertex's conflict sets
+func (e *Engine) chits(nodeID ids.ShortID, msg *network.Message) {
+	reply := &network.Message{
+		Op:        network.Chits,
+		RequestID: msg.RequestID,
+		BlockID:   msg.BlockID,
+	}
+	var sets []string
+	if node, ok := e.vertices[msg.BlockID]; ok {
+		sets = node.sets
+	} else if vtx, err := e.config.VM.GetVertex(msg.BlockID); err == nil {
+		if vtx.Status() == Accepted {
+			// An accepted vertex won every one of its conflict sets
+			reply.Votes = []ids.ID{msg.BlockID}
+			e.config.Sender.Send(reply, nodeID)
+			return
+		}
+		sets = vtx.Conflicts()
+	}
+	seen := make(map[ids.ID]struct{}, len(sets))
+	for _, key := range sets {
+		pref, ok := e.decided[key]
+		if !ok {
+			set, ok := e.sets[key]
+			if !ok {
+				continue
+			}
+			pref = set.consensus.Preference()
+		}
+		if _, ok := seen[pref]; !ok {
+			seen[pref] = struct{}{}
+			reply.Votes = append(reply.Votes, pref)
+		}
+	}
+	e.config.Sender.Send(reply, nodeID)
+}
+
+// add verifies [vtx] and adds it to its conflict sets. The vertex is queued
+// to be polled.
+func (e *Engine) add(vtx Vertex) error {
+	vtxID := vtx.ID()
+	if _, ok := e.vertices[vtxID]; ok {
+		return nil
+	}
+	if status := vtx.Status(); status == Accepted || status == Rejected {
+		return nil
+	}
+	for _, parentID := range vtx.Parents() {
+		if _, ok := e.vertices[parentID]; ok {
+			continue
+		}
+		parent, err := e.config.VM.GetVertex(parentID)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errMissingParent, parentID)
+		}
+		switch parent.Status() {
+		case Accepted:
+		case Rejected:
+			return fmt.Errorf("%w: %s", errRejectedParent, parentID)
+		default:
+			return fmt.Errorf("%w: %s is %s", errMissingParent, parentID, parent.Status())
+		}
+	}
+	if err := vtx.Verify(); err != nil {
+		return err
+	}
+
+	node := &vertex{
+		vtx:  vtx,
+		sets: vtx.Conflicts(),
+	}
+	e.vertices[vtxID] = node
+	e.latency.Issued(vtxID.String(), time.Now())
+	for _, parentID := range vtx.Parents() {
+		if parent, ok := e.vertices[parentID]; ok {
+			parent.children = append(parent.children, vtxID)
+		}
+	}
+
+	lost := false
+	for _, key := range node.sets {
+		set, ok := e.sets[key]
+		switch {
+		case e.decided[key] != ids.Empty:
+			// Another vertex already won this set
+			lost = true
+			continue
+		case !ok:
+			set = &conflictSet{
+				consensus: &snowball.Flat{},
+				members:   make(map[ids.ID]struct{}),
+			}
+			set.consensus.Initialize(e.config.Params, vtxID)
+			e.sets[key] = set
+		default:
+			set.consensus.Add(vtxID)
+		}
+		set.members[vtxID] = struct{}{}
+	}
+	if lost {
+		return e.reject(vtxID)
+	}
+	if len(node.sets) == 0 {
+		return e.tryAccept(vtxID)
+	}
+
+	e.queue = append(e.queue, vtxID)
+	e.issuePolls()
+	return nil
+}
+
+// issuePolls polls queued vertices until ConcurrentPolls are in flight
+func (e *Engine) issuePolls() {
+	if e.shutdown {
+		return
+	}
+	for len(e.polls) < e.config.ConcurrentPolls && len(e.queue) > 0 {
+		vtxID := e.queue[0]
+		node, ok := e.vertices[vtxID]
+		if !ok || node.won == len(node.sets) {
+			e.queue = e.queue[1:]
+			continue
+		}
+		if !e.issuePoll(node) {
+			e.retryPolls()
+			return
+		}
+		e.queue = e.queue[1:]
+	}
+}
+
+// issuePoll sends [node] to a sample of peers. It returns false if no peer
+// could be queried.
+func (e *Engine) issuePoll(node *vertex) bool {
+	vtxID := node.vtx.ID()
+	nodeIDs, err := e.config.Sampler.Sample(e.config.Params.K)
+	if err != nil {
+		e.config.Log.Verbo("delaying poll of %s: %s", vtxID, err)
+		return false
+	}
+
+	e.requestID++
+	requestID := e.requestID
+	sent := e.config.Sender.Send(&network.Message{
+		Op:        network.PushQuery,
+		RequestID: requestID,
+		BlockID:   vtxID,
+		Block:     node.vtx.Bytes(),
+	}, nodeIDs...)
+	if len(sent) == 0 {
+		e.config.Log.Verbo("delaying poll of %s: no sampled peer could be queried", vtxID)
+		return false
+	}
+
+	p := &poll{
+		vtxID:   vtxID,
+		pending: make(map[ids.ShortID]struct{}, len(sent)),
+	}
+	for _, nodeID := range sent {
+		p.pending[nodeID] = struct{}{}
+	}
+	p.timer = time.AfterFunc(e.config.QueryTimeout, func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		if _, ok := e.polls[requestID]; ok {
+			e.finishPoll(requestID)
+		}
+	})
+	e.polls[requestID] = p
+	e.pollMetrics.Issued()
+	return true
+}
+
+// retryPolls issues the queued polls after the query timeout
+func (e *Engine) retryPolls() {
+	if e.retrying {
+		return
+	}
+	e.retrying = true
+	time.AfterFunc(e.config.QueryTimeout, func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		e.retrying = false
+		e.issuePolls()
+	})
+}
+
+// vote records the chits of [nodeID]
+func (e *Engine) vote(nodeID ids.ShortID, msg *network.Message) {
+	p, ok := e.polls[msg.RequestID]
+	if !ok {
+		return
+	}
+	if _, ok := p.pending[nodeID]; !ok {
+		return
+	}
+	delete(p.pending, nodeID)
+
+	seen := make(map[ids.ID]struct{}, len(msg.Votes))
+	for _, vtxID := range msg.Votes {
+		if _, ok := seen[vtxID]; ok {
+			continue
+		}
+		seen[vtxID] = struct{}{}
+		if _, ok := e.vertices[vtxID]; ok {
+			p.votes.Add(vtxID)
+			continue
+		}
+		if _, err := e.config.VM.GetVertex(vtxID); err != nil {
+			// The voter prefers a vertex we haven't seen yet. The vote
+			// is dropped from this poll, but the vertex is fetched so
+			// later polls can count it.
+			e.config.Sender.Send(&network.Message{
+				Op:      network.Get,
+				BlockID: vtxID,
+			}, nodeID)
+		}
+	}
+	if len(p.pending) == 0 {
+		e.finishPoll(msg.RequestID)
+	}
+}
+
+// finishPoll applies the votes of a poll to each conflict set of the polled
+// vertex and decides the sets that finalized
+func (e *Engine) finishPoll(requestID uint32) {
+	p := e.polls[requestID]
+	delete(e.polls, requestID)
+	p.timer.Stop()
+
+	if err := e.recordPoll(p); err != nil {
+		e.config.Log.Fatal("failed to decide vertex %s: %s", p.vtxID, err)
+	}
+	if node, ok := e.vertices[p.vtxID]; ok && node.won < len(node.sets) {
+		e.queue = append(e.queue, p.vtxID)
+	}
+	e.issuePolls()
+}
+
+func (e *Engine) recordPoll(p *poll) error {
+	node, ok := e.vertices[p.vtxID]
+	if !ok {
+		return nil
+	}
+	successful := true
+	for _, key := range node.sets {
+		set, ok := e.sets[key]
+		if !ok || set.decided {
+			continue
+		}
+		var votes ids.Bag
+		for member := range set.members {
+			votes.AddCount(member, p.votes.Count(member))
+		}
+		if _, numVotes := votes.Mode(); numVotes < e.config.Params.Alpha {
+			successful = false
+		}
+		set.consensus.RecordPoll(votes)
+		if set.consensus.Finalized() {
+			if err := e.decideSet(key, set); err != nil {
+				return err
+			}
+		}
+	}
+	if successful {
+		e.pollMetrics.Successful()
+	} else {
+		e.pollMetrics.Failed()
+	}
+	return nil
+}
+
+// decideSet rejects every member of [set] but its preference, which is
+// accepted once it has won all of its sets and its parents are accepted
+func (e *Engine) decideSet(key string, set *conflictSet) error {
+	winner := set.consensus.Preference()
+	set.decided = true
+	e.decided[key] = winner
+	e.decidedOrder = append(e.decidedOrder, decidedSet{key: key, winner: winner})
+	if len(e.decidedOrder) > maxDecided {
+		oldest := e.decidedOrder[0]
+		e.decidedOrder = e.decidedOrder[1:]
+		// The set may have been decided again since
+		if e.decided[oldest.key] == oldest.winner {
+			delete(e.decided, oldest.key)
+		}
+	}
+
+	losers := make([]ids.ID, 0, len(set.members))
+	for member := range set.members {
+		if member != winner {
+			losers = append(losers, member)
+		}
+	}
+	for _, loser := range losers {
+		if err := e.reject(loser); err != nil {
+			return err
+		}
+	}
+	node, ok := e.vertices[winner]
+	if !ok {
+		return nil
+	}
+	node.won++
+	return e.tryAccept(winner)
+}
+
+// tryAccept accepts [vtxID] if it has won each of its conflict sets and its
+// parents are accepted, then tries its children
+func (e *Engine) tryAccept(vtxID ids.ID) error {
+	node, ok := e.vertices[vtxID]
+	if !ok || node.won < len(node.sets) {
+		return nil
+	}
+	for _, parentID := range node.vtx.Parents() {
+		if _, ok := e.vertices[parentID]; ok {
+			return nil
+		}
+	}
+	if err := node.vtx.Accept(); err != nil {
+		return err
+	}
+	delete(e.vertices, vtxID)
+	for _, key := range node.sets {
+		delete(e.sets, key)
+	}
+	e.latency.Accepted(vtxID.String())
+	e.lastAcceptedTime = time.Now()
+	e.config.Log.Verbo("accepted vertex %s", vtxID)
+
+	for _, childID := range node.children {
+		if err := e.tryAccept(childID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reject rejects [vtxID] and every vertex depending on it
+func (e *Engine) reject(vtxID ids.ID) error {
+	node, ok := e.vertices[vtxID]
+	if !ok {
+		return nil
+	}
+	delete(e.vertices, vtxID)
+	if err := node.vtx.Reject(); err != nil {
+		return err
+	}
+	e.latency.Rejected(vtxID.String())
+
+	for _, key := range node.sets {
+		set, ok := e.sets[key]
+		if !ok {
+			continue
+		}
+		if _, ok := set.members[vtxID]; !ok {
+			continue
+		}
+		delete(set.members, vtxID)
+		if e.decided[key] == vtxID {
+			// The winner was rejected along with an ancestor, so the input
+			// is still unspent
+			delete(e.decided, key)
+		}
+		switch {
+		case len(set.members) == 0:
+			// Nothing spends the input any more, so a later
+			// vertex can start a new set
+			delete(e.sets, key)
+		case !set.decided:
+			// The rejected vertex may have been preferred, so the set
+			// starts over with the members that can still be accepted
+			set.consensus = e.newConsensus(set.members)
+		}
+	}
+	for _, childID := range node.children {
+		if err := e.reject(childID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Engine) newConsensus(members map[ids.ID]struct{}) *snowball.Flat {
+	choices := make([]ids.ID, 0, len(members))
+	for member := range members {
+		choices = append(choices, member)
+	}
+	sort.Slice(choices, func(i, j int) bool {
+		return string(choices[i][:]) < string(choices[j][:])
+	})
+	consensus := &snowball.Flat{}
+	consensus.Initialize(e.config.Params, choices[0])
+	for _, choice := range choices[1:] {
+		consensus.Add(choice)
+	}
+	return consensus
+}
+
+// Shutdown stops the engine. Polls in flight are dropped and messages
+// received afterwards are ignored, so nothing is accepted once it returns.
+func (e *Engine) Shutdown() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.shutdown = true
+	for requestID, p := range e.polls {
+		p.timer.Stop()
+		delete(e.polls, requestID)
+	}
+}
+
+// NumProcessing returns the number of vertices being decided
+func (e *Engine) NumProcessing() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return len(e.vertices)
+}
+
+// LastAccepted returns when the last vertex was accepted
+func (e *Engine) LastAccepted() time.Time {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.lastAcceptedTime
+}
+
+// Processing returns the snowball state of every conflict set being decided
+func (e *Engine) Processing() []Decision {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	decisions := make([]Decision, 0, len(e.sets))
+	for key, set := range e.sets {
+		members := make([]ids.ID, 0, len(set.members))
+		for member := range set.members {
+			members = append(members, member)
+		}
+		decisions = append(decisions, Decision{
+			ConflictSet: key,
+			Members:     members,
+			Preference:  set.consensus.Preference(),
+			Confidence:  set.consensus.Confidence(),
+			Finalized:   set.decided,
+		})
+	}
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].ConflictSet < decisions[j].ConflictSet
+	})
+	return decisions
+}
+
+var _ network.Handler = &Engine{}
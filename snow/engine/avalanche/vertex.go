@@ -0,0 +1,66 @@
+package avalanche
+
+import (
+	"fmt"
+
+	"ticketsystem/main/ids"
+)
+
+// Status of a vertex
+type Status uint8
+
+// Vertex statuses
+const (
+	Unknown Status = iota
+	Processing
+	Accepted
+	Rejected
+)
+
+func (s Status) String() string {
+	switch s {
+	case Unknown:
+		return "unknown"
+	case Processing:
+		return "processing"
+	case Accepted:
+		return "accepted"
+	case Rejected:
+		return "rejected"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
+// Vertex is a node of the DAG. It is accepted once it wins every conflict set
+// it belongs to and all of its parents are accepted. It is rejected if it
+// loses a conflict set or one of its parents is rejected.
+type Vertex interface {
+	ID() ids.ID
+	// Parents are the vertices this vertex depends on
+	Parents() []ids.ID
+	// Bytes is the serialized vertex, as sent to peers
+	Bytes() []byte
+	Status() Status
+
+	// Verify that the vertex is valid on top of its ancestors. It is only
+	// called once the parents are processing or accepted.
+	Verify() error
+	// Conflicts returns the conflict sets the vertex belongs to. Two
+	// vertices sharing a conflict set can't both be accepted. It is only
+	// called once Verify returned nil.
+	Conflicts() []string
+	// Accept and Reject are called once, when the vertex is decided
+	Accept() error
+	Reject() error
+}
+
+// DAGVM parses and stores the vertices of the DAG
+type DAGVM interface {
+	// ParseVertex parses a vertex received from a peer. A known vertex is
+	// returned with its status.
+	ParseVertex(b []byte) (Vertex, error)
+	// GetVertex returns a vertex that was verified. Accepted vertices may be
+	// read back from storage, in which case their conflicts aren't known.
+	GetVertex(vtxID ids.ID) (Vertex, error)
+}
@@ -0,0 +1,72 @@
+package common
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned by Get when the key isn't in the database
+var ErrNotFound = errors.New("not found")
+
+// Database is the key value store a chain persists its blocks and
+// bootstrapping progress to
+type Database interface {
+	Has(key []byte) (bool, error)
+	// Get returns ErrNotFound if [key] isn't in the database
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+}
+
+// MemDatabase is a Database held in memory. It is used when the node runs
+// without a database, in which case it starts from genesis on every restart.
+type MemDatabase struct {
+	lock sync.RWMutex
+	kvs  map[string][]byte
+}
+
+// NewMemDatabase returns an empty MemDatabase
+func NewMemDatabase() *MemDatabase {
+	return &MemDatabase{kvs: make(map[string][]byte)}
+}
+
+// Has implements the Database interface
+func (db *MemDatabase) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	_, ok := db.kvs[string(key)]
+	return ok, nil
+}
+
+// Get implements the Database interface
+func (db *MemDatabase) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	value, ok := db.kvs[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// Put implements the Database interface
+func (db *MemDatabase) Put(key, value []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.kvs[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete implements the Database interface
+func (db *MemDatabase) Delete(key []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	delete(db.kvs, string(key))
+	return nil
+}
+
+var _ Database = &MemDatabase{}
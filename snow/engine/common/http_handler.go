@@ -0,0 +1,22 @@
+package common
+
+import (
+	"net/http"
+)
+
+// LockOption allows the vm to specify their lock option based on their endpoint
+type LockOption uint32
+
+// List of all allowed options
+const (
+	WriteLock LockOption = iota
+	ReadLock
+	NoLock
+)
+
+// HTTPHandler is an http.Handler along with the lock the API server should
+// hold while it runs
+type HTTPHandler struct {
+	LockOptions LockOption
+	Handler     http.Handler
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/validators"
+)
+
+var errNoPeers = errors.New("no peers to sample")
+
+// Sampler picks the peers a poll is sent to
+type Sampler interface {
+	// Sample returns up to [k] distinct peers
+	Sample(k int) ([]ids.ShortID, error)
+}
+
+// UniformSampler samples connected peers with equal probability. The engine
+// using it reports connections through Connected and Disconnected.
+type UniformSampler struct {
+	lock  sync.RWMutex
+	peers map[ids.ShortID]struct{}
+}
+
+// NewUniformSampler returns a sampler with no connected peers
+func NewUniformSampler() *UniformSampler {
+	return &UniformSampler{peers: make(map[ids.ShortID]struct{})}
+}
+
+// Connected adds [nodeID] to the peers sampled
+func (s *UniformSampler) Connected(nodeID ids.ShortID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.peers[nodeID] = struct{}{}
+}
+
+// Disconnected removes [nodeID] from the peers sampled
+func (s *UniformSampler) Disconnected(nodeID ids.ShortID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.peers, nodeID)
+}
+
+// Sample implements the Sampler interface
+func (s *UniformSampler) Sample(k int) ([]ids.ShortID, error) {
+	s.lock.RLock()
+	peers := make([]ids.ShortID, 0, len(s.peers))
+	for nodeID := range s.peers {
+		peers = append(peers, nodeID)
+	}
+	s.lock.RUnlock()
+
+	if len(peers) == 0 {
+		return nil, errNoPeers
+	}
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > k {
+		peers = peers[:k]
+	}
+	return peers, nil
+}
+
+// NewValidatorSampler returns a sampler picking validators of [vdrs] by
+// stake, never picking [self]. [monitor], if set, is told which validators
+// were sampled.
+func NewValidatorSampler(vdrs validators.Set, self ids.ShortID, monitor *validators.Monitor) Sampler {
+	return &validatorSampler{
+		vdrs:    vdrs,
+		self:    self,
+		monitor: monitor,
+	}
+}
+
+// validatorSampler samples validators by stake, never picking this node
+type validatorSampler struct {
+	vdrs validators.Set
+	self ids.ShortID
+	// monitor, if set, is told which validators were sampled
+	monitor *validators.Monitor
+}
+
+func (s *validatorSampler) Sample(k int) ([]ids.ShortID, error) {
+	size := k
+	if s.vdrs.Contains(s.self) {
+		size++
+	}
+	vdrs, err := s.vdrs.Sample(size)
+	if err != nil {
+		return nil, err
+	}
+
+	sampled := make([]validators.Validator, 0, k)
+	nodeIDs := make([]ids.ShortID, 0, k)
+	for _, vdr := range vdrs {
+		if vdr.NodeID != s.self && len(nodeIDs) < k {
+			sampled = append(sampled, vdr)
+			nodeIDs = append(nodeIDs, vdr.NodeID)
+		}
+	}
+	if len(nodeIDs) == 0 {
+		return nil, errNoPeers
+	}
+	if s.monitor != nil {
+		s.monitor.Observe(sampled)
+	}
+	return nodeIDs, nil
+}
@@ -0,0 +1,12 @@
+package common
+
+import (
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+)
+
+// Sender is the part of the network an engine sends messages through
+type Sender interface {
+	Send(msg *network.Message, nodeIDs ...ids.ShortID) []ids.ShortID
+	Gossip(msg *network.Message) []ids.ShortID
+}
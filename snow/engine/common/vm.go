@@ -0,0 +1,8 @@
+package common
+
+// VM describes the interface a chain exposes to the API server
+type VM interface {
+	// CreateHandlers returns the HTTP handlers of the chain, keyed by the
+	// extension they are served under, e.g. "/tickets"
+	CreateHandlers() map[string]*HTTPHandler
+}
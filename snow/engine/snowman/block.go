@@ -0,0 +1,49 @@
+package snowman
+
+import (
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/validators"
+)
+
+// Block is a block on the chain that the engine decides
+type Block interface {
+	ID() ids.ID
+	Parent() ids.ID
+	Height() uint64
+	// Bytes is the serialized block, as sent to peers
+	Bytes() []byte
+
+	// Verify that the block is valid on top of its parent
+	Verify() error
+	// Accept and Reject are called once, when the block is decided
+	Accept() error
+	Reject() error
+}
+
+// VM builds, parses and stores the blocks of the chain
+type VM interface {
+	// ParseBlock parses a block received from a peer
+	ParseBlock(b []byte) (Block, error)
+	// GetBlock returns a block that was accepted or is being processed
+	GetBlock(blkID ids.ID) (Block, error)
+	// LastAccepted returns the ID of the last accepted block
+	LastAccepted() ids.ID
+	// GetBlockIDAtHeight returns the ID of the accepted block at [height]
+	GetBlockIDAtHeight(height uint64) (ids.ID, error)
+}
+
+// ValidatorVM is implemented by VMs that manage the validator set on chain.
+// The engine reloads the set whenever a block is accepted, so validator
+// changes take effect at block boundaries.
+type ValidatorVM interface {
+	VM
+	Validators() ([]validators.Validator, error)
+}
+
+// GossipVM is implemented by VMs that gossip application messages, such as
+// pending transactions, between peers
+type GossipVM interface {
+	VM
+	// AppGossip handles a message gossiped by [nodeID]
+	AppGossip(nodeID ids.ShortID, msg []byte) error
+}
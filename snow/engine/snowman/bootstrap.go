@@ -0,0 +1,575 @@
+package snowman
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/utils/logging"
+)
+
+const (
+	// DefaultBootstrapTimeout is how long the bootstrapper waits for a
+	// beacon before asking another one
+	DefaultBootstrapTimeout = 5 * time.Second
+
+	// maxAncestors is the most blocks sent in one Ancestors message
+	maxAncestors = 2048
+	// maxAncestorsSize caps the block bytes of an Ancestors message, leaving
+	// room for the encoding under the network's message size limit
+	maxAncestorsSize = 1024 * 1024
+)
+
+var (
+	errChainDiverged  = errors.New("beacons' chain doesn't extend the last accepted block")
+	errNoOnFinished   = errors.New("bootstrapper needs an OnFinished callback")
+	errNilBootstrapDB = errors.New("bootstrapper needs a database")
+)
+
+// Bootstrapping progress, kept in the database so that an interrupted
+// bootstrap resumes where it stopped
+var (
+	bootstrapTargetKey   = []byte("bootstrap:target")
+	bootstrapBlockPrefix = []byte("bootstrap:block:")
+)
+
+func bootstrapBlockKey(blkID ids.ID) []byte {
+	return append(append([]byte(nil), bootstrapBlockPrefix...), blkID[:]...)
+}
+
+// BootstrapTarget returns the block an interrupted bootstrap stored in [db]
+// was fetching, if any
+func BootstrapTarget(db common.Database) (ids.ID, bool, error) {
+	target, err := db.Get(bootstrapTargetKey)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return ids.Empty, false, nil
+	case err != nil:
+		return ids.Empty, false, err
+	}
+	targetID, err := ids.ToID(target)
+	return targetID, err == nil, err
+}
+
+// BootstrapConfig of the bootstrapper
+type BootstrapConfig struct {
+	VM     VM
+	Sender common.Sender
+	DB     common.Database
+	// Beacons are the peers the accepted chain is fetched from. The chain a
+	// majority of them reports is the one executed. Without beacons the
+	// node starts from its last accepted block.
+	Beacons []ids.ShortID
+	Log     logging.Logger
+	// RequestTimeout is how long a beacon has to answer before the request
+	// is sent to another one
+	RequestTimeout time.Duration
+	// Engine handles every message once the node is bootstrapped
+	Engine network.Handler
+	// OnFinished is called once the chain is caught up, before messages are
+	// passed to Engine. It is where the engine is initialized.
+	OnFinished func() error
+}
+
+// Bootstrapper brings a node joining the chain up to date before it takes
+// part in consensus. It asks the beacons for their last accepted block,
+// downloads its ancestry back to the local last accepted block, checking
+// that every block links to the next by ID, and executes the blocks in
+// order. It repeats until the beacons' last accepted block is accepted
+// locally.
+type Bootstrapper struct {
+	config BootstrapConfig
+
+	lock      sync.Mutex
+	finished  bool
+	failed    bool
+	shutdown  bool
+	started   bool
+	connected map[ids.ShortID]struct{}
+	beacons   map[ids.ShortID]struct{}
+	requestID uint32
+	timer     *time.Timer
+
+	// frontier round in flight
+	frontierPending map[ids.ShortID]struct{}
+	frontiers       map[ids.ShortID]ids.ID
+
+	// target is the block being bootstrapped to and next is the first
+	// ancestor not fetched yet
+	target ids.ID
+	next   ids.ID
+	// peers that can be asked for ancestors, in turn
+	peers    []ids.ShortID
+	nextPeer int
+	fetching ids.ShortID
+}
+
+// Initialize the bootstrapper. It must be called before the network is
+// dispatched. Bootstrapping starts once a majority of the beacons is
+// connected.
+func (b *Bootstrapper) Initialize(config BootstrapConfig) error {
+	switch {
+	case config.DB == nil:
+		return errNilBootstrapDB
+	case config.OnFinished == nil:
+		return errNoOnFinished
+	}
+	b.config = config
+	b.connected = make(map[ids.ShortID]struct{})
+	b.beacons = make(map[ids.ShortID]struct{}, len(config.Beacons))
+	for _, beacon := range config.Beacons {
+		b.beacons[beacon] = struct{}{}
+	}
+
+	target, err := config.DB.Get(bootstrapTargetKey)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if b.target, err = ids.ToID(target); err != nil {
+			return err
+		}
+		config.Log.Info("resuming bootstrap to block %s", b.target)
+	}
+
+	if len(b.beacons) == 0 {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		b.finish()
+	}
+	return nil
+}
+
+// Finished returns true once the node is bootstrapped
+func (b *Bootstrapper) Finished() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.finished
+}
+
+// Connected implements the network.Handler interface
+func (b *Bootstrapper) Connected(nodeID ids.ShortID) {
+	b.lock.Lock()
+	if b.finished {
+		b.lock.Unlock()
+		b.config.Engine.Connected(nodeID)
+		return
+	}
+	defer b.lock.Unlock()
+
+	b.connected[nodeID] = struct{}{}
+	if _, ok := b.beacons[nodeID]; !ok || b.started || b.shutdown {
+		return
+	}
+	if numConnected := len(b.connectedBeacons()); numConnected <= len(b.beacons)/2 {
+		b.config.Log.Info("bootstrapping once a majority of beacons is connected: %d of %d", numConnected, len(b.beacons))
+		return
+	}
+	b.started = true
+	if b.target != ids.Empty {
+		b.peers = b.connectedBeacons()
+		b.resume()
+		return
+	}
+	b.getFrontier()
+}
+
+// Disconnected implements the network.Handler interface
+func (b *Bootstrapper) Disconnected(nodeID ids.ShortID) {
+	b.lock.Lock()
+	if b.finished {
+		b.lock.Unlock()
+		b.config.Engine.Disconnected(nodeID)
+		return
+	}
+	defer b.lock.Unlock()
+
+	delete(b.connected, nodeID)
+}
+
+// HandleInbound implements the network.Handler interface
+func (b *Bootstrapper) HandleInbound(nodeID ids.ShortID, msg *network.Message) {
+	b.lock.Lock()
+	if b.finished {
+		b.lock.Unlock()
+		b.config.Engine.HandleInbound(nodeID, msg)
+		return
+	}
+	defer b.lock.Unlock()
+
+	if b.shutdown {
+		return
+	}
+	switch msg.Op {
+	case network.GetAcceptedFrontier:
+		sendAcceptedFrontier(b.config.VM, b.config.Sender, nodeID, msg)
+	case network.GetAncestors:
+		sendAncestors(b.config.VM, b.config.Sender, b.config.Log, nodeID, msg)
+	case network.AcceptedFrontier:
+		b.acceptedFrontier(nodeID, msg)
+	case network.Ancestors:
+		b.ancestors(nodeID, msg)
+	default:
+		b.config.Log.Verbo("dropping %s from %s while bootstrapping", msg.Op, nodeID)
+	}
+}
+
+// Shutdown stops bootstrapping. Fetched blocks stay in the database, so the
+// next start resumes from them. Once bootstrapped, the engine must be shut
+// down separately.
+func (b *Bootstrapper) Shutdown() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.shutdown = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+func (b *Bootstrapper) connectedBeacons() []ids.ShortID {
+	beacons := make([]ids.ShortID, 0, len(b.beacons))
+	for _, beacon := range b.config.Beacons {
+		if _, ok := b.connected[beacon]; ok {
+			beacons = append(beacons, beacon)
+		}
+	}
+	return beacons
+}
+
+// request sends [msg] with a new request ID and calls [onTimeout] if the
+// request is still the latest one after the request timeout
+func (b *Bootstrapper) request(msg *network.Message, onTimeout func(), nodeIDs ...ids.ShortID) []ids.ShortID {
+	b.retry(onTimeout)
+	msg.RequestID = b.requestID
+	return b.config.Sender.Send(msg, nodeIDs...)
+}
+
+// retry calls [f] after the request timeout, unless another request is made
+// in the meantime
+func (b *Bootstrapper) retry(f func()) {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.requestID++
+	requestID := b.requestID
+	b.timer = time.AfterFunc(b.config.RequestTimeout, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		if b.requestID == requestID && !b.finished && !b.failed && !b.shutdown {
+			f()
+		}
+	})
+}
+
+// getFrontier asks the connected beacons for their last accepted block
+func (b *Bootstrapper) getFrontier() {
+	b.frontiers = make(map[ids.ShortID]ids.ID)
+	b.frontierPending = make(map[ids.ShortID]struct{})
+	sent := b.request(&network.Message{Op: network.GetAcceptedFrontier}, b.decideFrontier, b.connectedBeacons()...)
+	for _, nodeID := range sent {
+		b.frontierPending[nodeID] = struct{}{}
+	}
+}
+
+func (b *Bootstrapper) acceptedFrontier(nodeID ids.ShortID, msg *network.Message) {
+	if msg.RequestID != b.requestID || b.frontierPending == nil {
+		return
+	}
+	if _, ok := b.frontierPending[nodeID]; !ok {
+		return
+	}
+	delete(b.frontierPending, nodeID)
+	b.frontiers[nodeID] = msg.BlockID
+	if len(b.frontierPending) == 0 {
+		b.decideFrontier()
+	}
+}
+
+// decideFrontier picks the block a majority of the beacons reported. If
+// there isn't one, for example because the beacons are accepting blocks
+// while being asked, the beacons are asked again.
+func (b *Bootstrapper) decideFrontier() {
+	b.frontierPending = nil
+	votes := ids.Bag{}
+	for _, blkID := range b.frontiers {
+		votes.Add(blkID)
+	}
+	target, numVotes := votes.Mode()
+	if numVotes <= len(b.beacons)/2 {
+		b.config.Log.Debug("no majority among %d beacon frontiers, asking again", len(b.frontiers))
+		b.retry(b.getFrontier)
+		return
+	}
+
+	if b.isAccepted(target) {
+		b.finish()
+		return
+	}
+	b.peers = b.peers[:0]
+	for nodeID, blkID := range b.frontiers {
+		if blkID == target {
+			b.peers = append(b.peers, nodeID)
+		}
+	}
+	if err := b.config.DB.Put(bootstrapTargetKey, target[:]); err != nil {
+		b.fail(err)
+		return
+	}
+	b.config.Log.Info("bootstrapping to block %s", target)
+	b.target = target
+	b.next = target
+	b.fetch()
+}
+
+// resume continues an interrupted bootstrap. The blocks fetched before are
+// walked back from the target to find where fetching stopped.
+func (b *Bootstrapper) resume() {
+	if b.isAccepted(b.target) {
+		b.executed()
+		return
+	}
+	b.next = b.target
+	for {
+		bytes, err := b.config.DB.Get(bootstrapBlockKey(b.next))
+		if errors.Is(err, common.ErrNotFound) {
+			b.fetch()
+			return
+		}
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		blk, err := b.config.VM.ParseBlock(bytes)
+		if err != nil {
+			b.fail(fmt.Errorf("couldn't parse stored block %s: %w", b.next, err))
+			return
+		}
+		done, err := b.reachedAccepted(blk)
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		if done {
+			b.execute()
+			return
+		}
+		b.next = blk.Parent()
+	}
+}
+
+// fetch asks the next peer for the ancestors of the first missing block
+func (b *Bootstrapper) fetch() {
+	if len(b.peers) == 0 {
+		b.peers = b.connectedBeacons()
+	}
+	if len(b.peers) == 0 {
+		b.config.Log.Debug("no beacon to fetch %s from, retrying", b.next)
+		b.retry(b.fetch)
+		return
+	}
+	b.nextPeer %= len(b.peers)
+	b.fetching = b.peers[b.nextPeer]
+	b.nextPeer++
+	b.request(&network.Message{
+		Op:      network.GetAncestors,
+		BlockID: b.next,
+	}, b.fetch, b.fetching)
+}
+
+func (b *Bootstrapper) ancestors(nodeID ids.ShortID, msg *network.Message) {
+	if msg.RequestID != b.requestID || nodeID != b.fetching || b.target == ids.Empty {
+		return
+	}
+	fetched := 0
+	for _, bytes := range msg.Blocks {
+		blk, err := b.config.VM.ParseBlock(bytes)
+		if err != nil {
+			b.config.Log.Debug("failed to parse ancestor from %s: %s", nodeID, err)
+			break
+		}
+		// Each block must be the parent of the one before, which ties the
+		// whole chain to the target the beacons agreed on
+		if blk.ID() != b.next {
+			b.config.Log.Debug("%s sent block %s instead of %s", nodeID, blk.ID(), b.next)
+			break
+		}
+		if err := b.config.DB.Put(bootstrapBlockKey(blk.ID()), bytes); err != nil {
+			b.fail(err)
+			return
+		}
+		fetched++
+
+		done, err := b.reachedAccepted(blk)
+		if err != nil {
+			b.fail(err)
+			return
+		}
+		if done {
+			b.execute()
+			return
+		}
+		b.next = blk.Parent()
+	}
+	if fetched == 0 {
+		b.config.Log.Debug("%s sent no usable ancestors of %s", nodeID, b.next)
+	} else {
+		b.config.Log.Verbo("fetched %d blocks from %s", fetched, nodeID)
+	}
+	b.fetch()
+}
+
+// reachedAccepted returns true if [blk] builds on the last accepted block,
+// which means every block to execute is fetched
+func (b *Bootstrapper) reachedAccepted(blk Block) (bool, error) {
+	lastAcceptedID := b.config.VM.LastAccepted()
+	if blk.Parent() == lastAcceptedID {
+		return true, nil
+	}
+	lastAccepted, err := b.config.VM.GetBlock(lastAcceptedID)
+	if err != nil {
+		return false, err
+	}
+	if blk.Height() <= lastAccepted.Height()+1 {
+		return false, fmt.Errorf("%w: block %s at height %d", errChainDiverged, blk.ID(), blk.Height())
+	}
+	return false, nil
+}
+
+// execute verifies and accepts the fetched blocks, oldest first
+func (b *Bootstrapper) execute() {
+	var blocks []Block
+	for blkID := b.target; blkID != b.config.VM.LastAccepted(); {
+		bytes, err := b.config.DB.Get(bootstrapBlockKey(blkID))
+		if err != nil {
+			b.fail(fmt.Errorf("couldn't load fetched block %s: %w", blkID, err))
+			return
+		}
+		blk, err := b.config.VM.ParseBlock(bytes)
+		if err != nil {
+			b.fail(fmt.Errorf("couldn't parse fetched block %s: %w", blkID, err))
+			return
+		}
+		blocks = append(blocks, blk)
+		blkID = blk.Parent()
+	}
+
+	b.config.Log.Info("executing %d blocks", len(blocks))
+	for i := len(blocks) - 1; i >= 0; i-- {
+		blk := blocks[i]
+		if err := blk.Verify(); err != nil {
+			b.fail(fmt.Errorf("fetched block %s is invalid: %w", blk.ID(), err))
+			return
+		}
+		if err := blk.Accept(); err != nil {
+			b.fail(fmt.Errorf("couldn't accept block %s: %w", blk.ID(), err))
+			return
+		}
+		if err := b.config.DB.Delete(bootstrapBlockKey(blk.ID())); err != nil {
+			b.fail(err)
+			return
+		}
+	}
+	b.executed()
+}
+
+// executed clears the bootstrap progress and asks the beacons again, as they
+// accepted more blocks in the meantime
+func (b *Bootstrapper) executed() {
+	if err := b.config.DB.Delete(bootstrapTargetKey); err != nil {
+		b.fail(err)
+		return
+	}
+	b.config.Log.Info("bootstrapped to block %s", b.target)
+	b.target = ids.Empty
+	b.getFrontier()
+}
+
+// isAccepted returns true if [blkID] is accepted locally
+func (b *Bootstrapper) isAccepted(blkID ids.ID) bool {
+	blk, err := b.config.VM.GetBlock(blkID)
+	if err != nil {
+		return false
+	}
+	acceptedID, err := b.config.VM.GetBlockIDAtHeight(blk.Height())
+	return err == nil && acceptedID == blkID
+}
+
+// finish hands the node over to consensus
+func (b *Bootstrapper) finish() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	if err := b.config.OnFinished(); err != nil {
+		b.fail(err)
+		return
+	}
+	b.finished = true
+	b.config.Log.Info("bootstrapped, last accepted block is %s", b.config.VM.LastAccepted())
+
+	// The engine missed the connections made while bootstrapping
+	for nodeID := range b.connected {
+		b.config.Engine.Connected(nodeID)
+	}
+}
+
+// fail stops bootstrapping. The node keeps serving its accepted chain but
+// doesn't take part in consensus.
+func (b *Bootstrapper) fail(err error) {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.failed = true
+	b.config.Log.Fatal("bootstrapping failed: %s", err)
+}
+
+// sendAcceptedFrontier answers a GetAcceptedFrontier with the last accepted
+// block
+func sendAcceptedFrontier(vm VM, sender common.Sender, nodeID ids.ShortID, msg *network.Message) {
+	lastAcceptedID := vm.LastAccepted()
+	reply := &network.Message{
+		Op:        network.AcceptedFrontier,
+		RequestID: msg.RequestID,
+		BlockID:   lastAcceptedID,
+	}
+	if blk, err := vm.GetBlock(lastAcceptedID); err == nil {
+		reply.Height = blk.Height()
+	}
+	sender.Send(reply, nodeID)
+}
+
+// sendAncestors answers a GetAncestors with the requested block and as many
+// of its ancestors as fit in a message
+func sendAncestors(vm VM, sender common.Sender, log logging.Logger, nodeID ids.ShortID, msg *network.Message) {
+	blk, err := vm.GetBlock(msg.BlockID)
+	if err != nil {
+		log.Verbo("%s asked for ancestors of unknown block %s", nodeID, msg.BlockID)
+		return
+	}
+	blocks := [][]byte{blk.Bytes()}
+	size := len(blk.Bytes())
+	for len(blocks) < maxAncestors && blk.Height() > 0 {
+		parent, err := vm.GetBlock(blk.Parent())
+		if err != nil || size+len(parent.Bytes()) > maxAncestorsSize {
+			break
+		}
+		blocks = append(blocks, parent.Bytes())
+		size += len(parent.Bytes())
+		blk = parent
+	}
+	sender.Send(&network.Message{
+		Op:        network.Ancestors,
+		RequestID: msg.RequestID,
+		Blocks:    blocks,
+	}, nodeID)
+}
+
+var _ network.Handler = &Bootstrapper{}
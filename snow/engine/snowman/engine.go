@@ -0,0 +1,505 @@
+package snowman
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+	"ticketsystem/main/snow/consensus/metrics"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/snow/validators"
+	"ticketsystem/main/utils/logging"
+
+	snowball "ticketsystem/main/snow"
+)
+
+var (
+	errWrongHeight = errors.New("block is not at the height being decided")
+	errShutdown    = errors.New("engine is shut down")
+)
+
+// Config of the engine
+type Config struct {
+	// NodeID of this node, which is never polled
+	NodeID ids.ShortID
+	Params snowball.Parameters
+	VM     VM
+	Sender common.Sender
+	// Validators are sampled by stake for polls. If the VM is a
+	// ValidatorVM, the set is loaded from the chain.
+	Validators validators.Set
+	// Monitor, if set, alerts when one operator holds too much of the
+	// validator set or of the sampled weight
+	Monitor *validators.Monitor
+	// Sampler picks the peers polled. If nil, validators are sampled by
+	// stake or, without a validator set, connected peers uniformly.
+	Sampler common.Sampler
+	Log     logging.Logger
+	// QueryTimeout is how long a poll waits for chits. Peers that haven't
+	// answered by then are counted as not voting.
+	QueryTimeout time.Duration
+	Registerer   prometheus.Registerer
+}
+
+// Decision is the snowball state of a block being decided
+type Decision struct {
+	BlockID    ids.ID
+	Height     uint64
+	Preference ids.ID
+	Confidence int
+	Finalized  bool
+}
+
+// Engine runs snowball over the next block of the chain. Only one height is
+// decided at a time: once a block is finalized its conflicts are rejected
+// and the engine moves on to the next height.
+type Engine struct {
+	config  Config
+	uniform *common.UniformSampler
+
+	lock sync.Mutex
+	// height being decided
+	height uint64
+	// candidates are the verified blocks at [height]
+	candidates map[ids.ID]Block
+	// consensus is nil until a candidate is known
+	consensus *snowball.Flat
+	// polls in flight, by request ID. At most one is outstanding at a time.
+	polls     map[uint32]*poll
+	requestID uint32
+	// shutdown is set once the engine stops taking part in consensus
+	shutdown bool
+
+	lastAcceptedTime time.Time
+	pollMetrics      metrics.Polls
+	latency          metrics.Latency
+}
+
+type poll struct {
+	height  uint64
+	pending map[ids.ShortID]struct{}
+	votes   ids.Bag
+	timer   *time.Timer
+}
+
+// Initialize the engine. It must be called before the network is dispatched.
+func (e *Engine) Initialize(config Config) error {
+	if err := config.Params.Verify(); err != nil {
+		return err
+	}
+	lastAccepted, err := config.VM.GetBlock(config.VM.LastAccepted())
+	if err != nil {
+		return fmt.Errorf("couldn't load last accepted block: %w", err)
+	}
+	pollMetrics, err := metrics.NewPolls(config.Registerer)
+	if err != nil {
+		return err
+	}
+	latency, err := metrics.NewLatency(config.Registerer)
+	if err != nil {
+		return err
+	}
+
+	e.config = config
+	switch {
+	case config.Sampler != nil:
+	case config.Validators != nil:
+		e.config.Sampler = common.NewValidatorSampler(config.Validators, config.NodeID, config.Monitor)
+	default:
+		e.uniform = common.NewUniformSampler()
+		e.config.Sampler = e.uniform
+	}
+	if err := e.loadValidators(); err != nil {
+		return err
+	}
+	e.height = lastAccepted.Height() + 1
+	e.candidates = make(map[ids.ID]Block)
+	e.polls = make(map[uint32]*poll)
+	e.lastAcceptedTime = time.Now()
+	e.pollMetrics = pollMetrics
+	e.latency = latency
+	return nil
+}
+
+// Issue adds a block built by this node and gossips it to peers
+func (e *Engine) Issue(blk Block) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.shutdown {
+		return errShutdown
+	}
+	if err := e.add(blk); err != nil {
+		return err
+	}
+	e.config.Sender.Gossip(&network.Message{
+		Op:      network.Put,
+		BlockID: blk.ID(),
+		Height:  blk.Height(),
+		Block:   blk.Bytes(),
+	})
+	return nil
+}
+
+// Connected implements the network.Handler interface
+func (e *Engine) Connected(nodeID ids.ShortID) {
+	if e.uniform != nil {
+		e.uniform.Connected(nodeID)
+	}
+}
+
+// Disconnected implements the network.Handler interface
+func (e *Engine) Disconnected(nodeID ids.ShortID) {
+	if e.uniform != nil {
+		e.uniform.Disconnected(nodeID)
+	}
+}
+
+// HandleInbound implements the network.Handler interface
+func (e *Engine) HandleInbound(nodeID ids.ShortID, msg *network.Message) {
+	// App messages don't touch consensus, so they are handled without
+	// holding the engine lock
+	if msg.Op == network.AppGossip {
+		e.appGossip(nodeID, msg)
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.shutdown {
+		return
+	}
+	switch msg.Op {
+	case network.Get:
+		e.get(nodeID, msg)
+	case network.Put:
+		e.put(nodeID, msg)
+	case network.PushQuery:
+		e.put(nodeID, msg)
+		e.chits(nodeID, msg)
+	case network.PullQuery:
+		e.chits(nodeID, msg)
+	case network.Chits:
+		e.vote(nodeID, msg)
+	case network.GetAcceptedFrontier:
+		sendAcceptedFrontier(e.config.VM, e.config.Sender, nodeID, msg)
+	case network.GetAncestors:
+		sendAncestors(e.config.VM, e.config.Sender, e.config.Log, nodeID, msg)
+	default:
+		e.config.Log.Debug("dropping unexpected %s from %s", msg.Op, nodeID)
+	}
+}
+
+func (e *Engine) appGossip(nodeID ids.ShortID, msg *network.Message) {
+	vm, ok := e.config.VM.(GossipVM)
+	if !ok {
+		e.config.Log.Debug("dropping %s from %s: the VM doesn't gossip", msg.Op, nodeID)
+		return
+	}
+	if err := vm.AppGossip(nodeID, msg.Payload); err != nil {
+		e.config.Log.Verbo("dropping %s from %s: %s", msg.Op, nodeID, err)
+	}
+}
+
+func (e *Engine) get(nodeID ids.ShortID, msg *network.Message) {
+	blk, err := e.config.VM.GetBlock(msg.BlockID)
+	if err != nil {
+		e.config.Log.Verbo("%s asked for unknown block %s", nodeID, msg.BlockID)
+		return
+	}
+	e.config.Sender.Send(&network.Message{
+		Op:        network.Put,
+		RequestID: msg.RequestID,
+		BlockID:   blk.ID(),
+		Height:    blk.Height(),
+		Block:     blk.Bytes(),
+	}, nodeID)
+}
+
+func (e *Engine) put(nodeID ids.ShortID, msg *network.Message) {
+	if len(msg.Block) == 0 {
+		return
+	}
+	blk, err := e.config.VM.ParseBlock(msg.Block)
+	if err != nil {
+		e.config.Log.Debug("failed to parse block from %s: %s", nodeID, err)
+		return
+	}
+	if _, ok := e.candidates[blk.ID()]; ok || blk.Height() != e.height {
+		return
+	}
+	if err := e.add(blk); err != nil {
+		e.config.Log.Debug("dropping block %s from %s: %s", blk.ID(), nodeID, err)
+	}
+}
+
+// chits answers a query with this node's preference at the queried height
+func (e *Engine) chits(nodeID ids.ShortID, msg *network.Message) {
+	reply := &network.Message{
+		Op:        network.Chits,
+		RequestID: msg.RequestID,
+		Height:    msg.Height,
+	}
+	switch {
+	case msg.Height < e.height:
+		if blkID, err := e.config.VM.GetBlockIDAtHeight(msg.Height); err == nil {
+			reply.Votes = []ids.ID{blkID}
+		}
+	case msg.Height == e.height && e.consensus != nil:
+		reply.Votes = []ids.ID{e.consensus.Preference()}
+	}
+	e.config.Sender.Send(reply, nodeID)
+}
+
+// add verifies [blk] and makes it a candidate at the current height. If no
+// poll is running, one is started.
+func (e *Engine) add(blk Block) error {
+	blkID := blk.ID()
+	if blk.Height() != e.height {
+		return fmt.Errorf("%w: %d, deciding %d", errWrongHeight, blk.Height(), e.height)
+	}
+	if _, ok := e.candidates[blkID]; ok {
+		return nil
+	}
+	if err := blk.Verify(); err != nil {
+		return err
+	}
+
+	e.candidates[blkID] = blk
+	e.latency.Issued(blkID.String(), time.Now())
+	if e.consensus == nil {
+		e.consensus = &snowball.Flat{}
+		e.consensus.Initialize(e.config.Params, blkID)
+	} else {
+		e.consensus.Add(blkID)
+	}
+	if len(e.polls) == 0 {
+		e.issuePoll()
+	}
+	return nil
+}
+
+// issuePoll sends the preference to a sample of peers
+func (e *Engine) issuePoll() {
+	if e.shutdown {
+		return
+	}
+	pref, ok := e.candidates[e.consensus.Preference()]
+	if !ok {
+		return
+	}
+	nodeIDs, err := e.config.Sampler.Sample(e.config.Params.K)
+	if err != nil {
+		e.config.Log.Verbo("delaying poll at height %d: %s", e.height, err)
+		e.retryPoll()
+		return
+	}
+
+	e.requestID++
+	requestID := e.requestID
+	sent := e.config.Sender.Send(&network.Message{
+		Op:        network.PushQuery,
+		RequestID: requestID,
+		BlockID:   pref.ID(),
+		Height:    pref.Height(),
+		Block:     pref.Bytes(),
+	}, nodeIDs...)
+	if len(sent) == 0 {
+		e.config.Log.Verbo("delaying poll at height %d: no sampled peer could be queried", e.height)
+		e.retryPoll()
+		return
+	}
+
+	p := &poll{
+		height:  e.height,
+		pending: make(map[ids.ShortID]struct{}, len(sent)),
+	}
+	for _, nodeID := range sent {
+		p.pending[nodeID] = struct{}{}
+	}
+	p.timer = time.AfterFunc(e.config.QueryTimeout, func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		if _, ok := e.polls[requestID]; ok {
+			e.finishPoll(requestID)
+		}
+	})
+	e.polls[requestID] = p
+	e.pollMetrics.Issued()
+}
+
+// retryPoll issues a poll after the query timeout, unless one was issued or
+// the height was decided in the meantime
+func (e *Engine) retryPoll() {
+	height := e.height
+	time.AfterFunc(e.config.QueryTimeout, func() {
+		e.lock.Lock()
+		defer e.lock.Unlock()
+
+		if e.height == height && len(e.polls) == 0 && e.consensus != nil {
+			e.issuePoll()
+		}
+	})
+}
+
+// vote records the chits of [nodeID]
+func (e *Engine) vote(nodeID ids.ShortID, msg *network.Message) {
+	p, ok := e.polls[msg.RequestID]
+	if !ok {
+		return
+	}
+	if _, ok := p.pending[nodeID]; !ok {
+		return
+	}
+	delete(p.pending, nodeID)
+
+	if len(msg.Votes) > 0 {
+		blkID := msg.Votes[0]
+		if _, ok := e.candidates[blkID]; ok {
+			p.votes.Add(blkID)
+		} else if p.height == e.height {
+			// The voter prefers a block we haven't seen yet. The vote
+			// is dropped from this poll, but the block is fetched so
+			// later polls can count it.
+			e.config.Sender.Send(&network.Message{
+				Op:      network.Get,
+				BlockID: blkID,
+				Height:  p.height,
+			}, nodeID)
+		}
+	}
+	if len(p.pending) == 0 {
+		e.finishPoll(msg.RequestID)
+	}
+}
+
+// finishPoll applies the votes of a poll and decides the height once
+// snowball finalizes
+func (e *Engine) finishPoll(requestID uint32) {
+	p := e.polls[requestID]
+	delete(e.polls, requestID)
+	p.timer.Stop()
+
+	if p.height != e.height || e.consensus == nil {
+		return
+	}
+
+	if _, numVotes := p.votes.Mode(); numVotes >= e.config.Params.Alpha {
+		e.pollMetrics.Successful()
+	} else {
+		e.pollMetrics.Failed()
+	}
+	e.consensus.RecordPoll(p.votes)
+
+	if !e.consensus.Finalized() {
+		e.issuePoll()
+		return
+	}
+	if err := e.decide(e.consensus.Preference()); err != nil {
+		e.config.Log.Fatal("failed to decide height %d: %s", e.height, err)
+	}
+}
+
+// decide accepts [accepted], rejects its conflicts and moves on to the next
+// height
+func (e *Engine) decide(accepted ids.ID) error {
+	if err := e.candidates[accepted].Accept(); err != nil {
+		return err
+	}
+	e.latency.Accepted(accepted.String())
+	e.config.Log.Info("accepted block %s at height %d", accepted, e.height)
+
+	for blkID, blk := range e.candidates {
+		if blkID == accepted {
+			continue
+		}
+		if err := blk.Reject(); err != nil {
+			return err
+		}
+		e.latency.Rejected(blkID.String())
+	}
+
+	e.height++
+	e.candidates = make(map[ids.ID]Block)
+	e.consensus = nil
+	e.lastAcceptedTime = time.Now()
+	return e.loadValidators()
+}
+
+// loadValidators replaces the validator set with the one on chain
+func (e *Engine) loadValidators() error {
+	vm, ok := e.config.VM.(ValidatorVM)
+	if !ok || e.config.Validators == nil {
+		return nil
+	}
+	vdrs, err := vm.Validators()
+	if err != nil {
+		return fmt.Errorf("couldn't load validators: %w", err)
+	}
+	if err := e.config.Validators.Set(vdrs); err != nil {
+		return err
+	}
+	if e.config.Monitor != nil {
+		e.config.Monitor.CheckSet(vdrs)
+	}
+	return nil
+}
+
+// Shutdown stops the engine. Polls in flight are dropped and messages
+// received afterwards are ignored, so nothing is accepted once it returns.
+func (e *Engine) Shutdown() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.shutdown = true
+	for requestID, p := range e.polls {
+		p.timer.Stop()
+		delete(e.polls, requestID)
+	}
+}
+
+// NumProcessing returns the number of blocks being decided
+func (e *Engine) NumProcessing() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return len(e.candidates)
+}
+
+// LastAccepted returns when the last block was accepted
+func (e *Engine) LastAccepted() time.Time {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.lastAcceptedTime
+}
+
+// Processing returns the snowball state of every block being decided
+func (e *Engine) Processing() []Decision {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.consensus == nil {
+		return nil
+	}
+	decisions := make([]Decision, 0, len(e.candidates))
+	for blkID := range e.candidates {
+		decisions = append(decisions, Decision{
+			BlockID:    blkID,
+			Height:     e.height,
+			Preference: e.consensus.Preference(),
+			Confidence: e.consensus.Confidence(),
+			Finalized:  e.consensus.Finalized(),
+		})
+	}
+	return decisions
+}
+
+var _ network.Handler = &Engine{}
@@ -0,0 +1,36 @@
+package snowball
+
+import (
+	"ticketsystem/main/ids"
+)
+
+// Flat is a Consensus instance deciding between all of its choices at once
+type Flat struct {
+	// wraps the n-nary snowball logic
+	nnarySnowball
+
+	// params contains all the configurations of a snowball instance
+	params Parameters
+}
+
+// Initialize implements the Consensus interface
+func (f *Flat) Initialize(params Parameters, choice ids.ID) {
+	f.nnarySnowball.Initialize(params.BetaVirtuous, params.BetaRogue, choice)
+	f.params = params
+}
+
+// Parameters implements the Consensus interface
+func (f *Flat) Parameters() Parameters {
+	return f.params
+}
+
+// RecordPoll implements the Consensus interface
+func (f *Flat) RecordPoll(votes ids.Bag) {
+	if pollMode, numVotes := votes.Mode(); numVotes >= f.params.Alpha {
+		f.RecordSuccessfulPoll(pollMode)
+	} else {
+		f.RecordUnsuccessfulPoll()
+	}
+}
+
+var _ Consensus = &Flat{}
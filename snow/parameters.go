@@ -0,0 +1,46 @@
+package snowball
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errInvalidParameters = errors.New("invalid parameters")
+
+// Parameters required for snowball consensus
+type Parameters struct {
+	// K is the number of nodes sampled in each poll
+	K int `json:"k"`
+	// Alpha is the number of votes a choice needs in a poll for the poll to
+	// count towards it
+	Alpha int `json:"alpha"`
+	// BetaVirtuous is the number of consecutive successful polls needed to
+	// finalize a choice that was never in conflict
+	BetaVirtuous int `json:"betaVirtuous"`
+	// BetaRogue is the number of consecutive successful polls needed to
+	// finalize a choice that was in conflict
+	BetaRogue int `json:"betaRogue"`
+}
+
+// DefaultParameters are the parameters used when none are configured
+var DefaultParameters = Parameters{
+	K:            20,
+	Alpha:        15,
+	BetaVirtuous: 15,
+	BetaRogue:    20,
+}
+
+// Verify returns nil if the parameters describe a valid snowball instance
+func (p Parameters) Verify() error {
+	switch {
+	case p.Alpha <= p.K/2:
+		return fmt.Errorf("%w: k = %d, alpha = %d: fails the condition that: k/2 < alpha", errInvalidParameters, p.K, p.Alpha)
+	case p.K < p.Alpha:
+		return fmt.Errorf("%w: k = %d, alpha = %d: fails the condition that: alpha <= k", errInvalidParameters, p.K, p.Alpha)
+	case p.BetaVirtuous <= 0:
+		return fmt.Errorf("%w: betaVirtuous = %d: fails the condition that: 0 < betaVirtuous", errInvalidParameters, p.BetaVirtuous)
+	case p.BetaRogue < p.BetaVirtuous:
+		return fmt.Errorf("%w: betaVirtuous = %d, betaRogue = %d: fails the condition that: betaVirtuous <= betaRogue", errInvalidParameters, p.BetaVirtuous, p.BetaRogue)
+	}
+	return nil
+}
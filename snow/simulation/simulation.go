@@ -0,0 +1,201 @@
+// Package simulation runs snowball among simulated validators, some of which
+// are controlled by an adversary, to show how finality holds up as the
+// adversary's share of the weight grows.
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/validators"
+
+	snowball "ticketsystem/main/snow"
+)
+
+// Strategy is how the adversary votes
+type Strategy string
+
+const (
+	// Balance starts honest validators split between two conflicting
+	// blocks and votes for whichever block fewer honest validators prefer,
+	// trying to stop either from being finalized
+	Balance Strategy = "balance"
+	// Flip starts every honest validator on the honest block and always
+	// votes for the conflicting block, trying to get it finalized instead
+	Flip Strategy = "flip"
+)
+
+const adversary = "adversary"
+
+var (
+	errBadShare     = errors.New("adversary share must be in [0, 1)")
+	errBadStrategy  = errors.New("unknown strategy")
+	errTooFewHonest = errors.New("not enough honest validators")
+)
+
+var (
+	honestBlock      = ids.ID{1}
+	conflictingBlock = ids.ID{2}
+)
+
+// Config of one simulation run
+type Config struct {
+	Params snowball.Parameters `json:"params"`
+	// Validators is the number of validators, all with the same weight
+	Validators int `json:"validators"`
+	// AdversaryShare is the share of the weight held by the adversary
+	AdversaryShare float64  `json:"adversaryShare"`
+	Strategy       Strategy `json:"strategy"`
+	// MaxRounds is the number of polling rounds after which the run stops
+	// even if some honest validators haven't finalized
+	MaxRounds int   `json:"maxRounds"`
+	Seed      int64 `json:"seed"`
+}
+
+// Result of one simulation run
+type Result struct {
+	HonestValidators int `json:"honestValidators"`
+	// Finalized is the number of honest validators that finalized a block
+	Finalized int `json:"finalized"`
+	// FinalizedConflicting is the number of honest validators that
+	// finalized the adversary's block under the Flip strategy
+	FinalizedConflicting int `json:"finalizedConflicting"`
+	// SafetyViolated is true if honest validators finalized different blocks
+	SafetyViolated bool `json:"safetyViolated"`
+	// Rounds until every honest validator finalized, or MaxRounds
+	Rounds int `json:"rounds"`
+}
+
+type honestNode struct {
+	nodeID    ids.ShortID
+	consensus *snowball.Flat
+}
+
+// Run simulates polling rounds until every honest validator has finalized
+// or MaxRounds is reached. In each round every honest validator polls K
+// validators sampled by weight.
+func Run(config Config) (Result, error) {
+	if err := config.Params.Verify(); err != nil {
+		return Result{}, err
+	}
+	if config.AdversaryShare < 0 || config.AdversaryShare >= 1 {
+		return Result{}, fmt.Errorf("%w: %f", errBadShare, config.AdversaryShare)
+	}
+	if config.Strategy != Balance && config.Strategy != Flip {
+		return Result{}, fmt.Errorf("%w: %q", errBadStrategy, config.Strategy)
+	}
+
+	numAdversarial := int(math.Round(config.AdversaryShare * float64(config.Validators)))
+	numHonest := config.Validators - numAdversarial
+	if numHonest < 2 {
+		return Result{}, fmt.Errorf("%w: %d", errTooFewHonest, numHonest)
+	}
+
+	vdrs := validators.NewSeededSet(config.Seed)
+	honest := make([]*honestNode, numHonest)
+	for i := 0; i < config.Validators; i++ {
+		vdr := validators.Validator{
+			NodeID:   nodeID(i),
+			Operator: "honest",
+			Weight:   1,
+		}
+		if i >= numHonest {
+			vdr.Operator = adversary
+		}
+		if err := vdrs.Add(vdr); err != nil {
+			return Result{}, err
+		}
+		if i >= numHonest {
+			continue
+		}
+
+		initial, other := honestBlock, conflictingBlock
+		if config.Strategy == Balance && i%2 == 1 {
+			initial, other = other, initial
+		}
+		node := &honestNode{nodeID: vdr.NodeID, consensus: &snowball.Flat{}}
+		node.consensus.Initialize(config.Params, initial)
+		node.consensus.Add(other)
+		honest[i] = node
+	}
+
+	result := Result{HonestValidators: numHonest}
+	for result.Rounds < config.MaxRounds && !allFinalized(honest) {
+		result.Rounds++
+
+		// Every validator answers with its preference at the start of the
+		// round
+		preferences := make(map[ids.ShortID]ids.ID, numHonest)
+		preferHonest := 0
+		for _, node := range honest {
+			pref := node.consensus.Preference()
+			preferences[node.nodeID] = pref
+			if pref == honestBlock {
+				preferHonest++
+			}
+		}
+		adversarialVote := conflictingBlock
+		if config.Strategy == Balance && preferHonest < numHonest-preferHonest {
+			adversarialVote = honestBlock
+		}
+
+		for _, node := range honest {
+			if node.consensus.Finalized() {
+				continue
+			}
+			sample, err := vdrs.Sample(config.Params.K + 1)
+			if err != nil {
+				return Result{}, err
+			}
+			var votes ids.Bag
+			polled := 0
+			for _, vdr := range sample {
+				if vdr.NodeID == node.nodeID || polled == config.Params.K {
+					continue
+				}
+				polled++
+				if vdr.Operator == adversary {
+					votes.Add(adversarialVote)
+				} else {
+					votes.Add(preferences[vdr.NodeID])
+				}
+			}
+			node.consensus.RecordPoll(votes)
+		}
+	}
+
+	decided := make(map[ids.ID]struct{})
+	for _, node := range honest {
+		if !node.consensus.Finalized() {
+			continue
+		}
+		result.Finalized++
+		pref := node.consensus.Preference()
+		decided[pref] = struct{}{}
+		if pref == conflictingBlock && config.Strategy == Flip {
+			result.FinalizedConflicting++
+		}
+	}
+	result.SafetyViolated = len(decided) > 1
+	return result, nil
+}
+
+func allFinalized(nodes []*honestNode) bool {
+	for _, node := range nodes {
+		if !node.consensus.Finalized() {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeID(i int) ids.ShortID {
+	var id ids.ShortID
+	id[0] = byte(i >> 24)
+	id[1] = byte(i >> 16)
+	id[2] = byte(i >> 8)
+	id[3] = byte(i)
+	return id
+}
@@ -0,0 +1,192 @@
+package validators
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"ticketsystem/main/utils/logging"
+)
+
+// MonitorConfig configures when an operator is reported as dominant
+type MonitorConfig struct {
+	// Threshold is the share of weight, in (0, 1], above which a single
+	// operator is reported
+	Threshold float64 `json:"threshold"`
+	// Window is the number of polls the sampled share is computed over
+	Window int `json:"window"`
+}
+
+// DefaultMonitorConfig alerts once an operator holds a third of the weight,
+// the point at which it can stall finality
+func DefaultMonitorConfig() MonitorConfig {
+	return MonitorConfig{
+		Threshold: 1.0 / 3,
+		Window:    100,
+	}
+}
+
+// Alert reports an operator holding more than the threshold share of weight
+type Alert struct {
+	Operator string  `json:"operator"`
+	Share    float64 `json:"share"`
+	// Sampled is true if the share was measured over sampled polls, and
+	// false if it was measured over the whole validator set
+	Sampled bool `json:"sampled"`
+}
+
+// Monitor watches how much weight each operator holds, both in the
+// validator set and in the validators actually sampled for polls
+type Monitor struct {
+	config  MonitorConfig
+	log     logging.Logger
+	onAlert func(Alert)
+
+	lock sync.Mutex
+	// polls is a ring of the per operator weight of the last Window polls
+	polls  []map[string]uint64
+	next   int
+	totals map[string]uint64
+	total  uint64
+	// alerting holds the operators currently over the threshold, so each
+	// crossing is reported once
+	alerting map[Alert]struct{}
+
+	sampledShare *prometheus.GaugeVec
+	stakedShare  *prometheus.GaugeVec
+	alerts       prometheus.Counter
+}
+
+// NewMonitor returns a monitor that logs alerts and passes them to
+// [onAlert], which may be nil
+func NewMonitor(config MonitorConfig, log logging.Logger, reg prometheus.Registerer, onAlert func(Alert)) (*Monitor, error) {
+	m := &Monitor{
+		config:   config,
+		log:      log,
+		onAlert:  onAlert,
+		polls:    make([]map[string]uint64, 0, config.Window),
+		totals:   make(map[string]uint64),
+		alerting: make(map[Alert]struct{}),
+		sampledShare: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "operator_sampled_weight_share",
+			Help: "Share of the weight sampled over recent polls held by each operator",
+		}, []string{"operator"}),
+		stakedShare: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "operator_staked_weight_share",
+			Help: "Share of the validator set's weight held by each operator",
+		}, []string{"operator"}),
+		alerts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "operator_dominance_alerts",
+			Help: "Number of times an operator crossed the dominance threshold",
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.sampledShare, m.stakedShare, m.alerts} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Observe records the validators sampled for a poll
+func (m *Monitor) Observe(sample []Validator) {
+	weights := byOperator(sample)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if len(m.polls) < m.config.Window {
+		m.polls = append(m.polls, weights)
+	} else {
+		for operator, weight := range m.polls[m.next] {
+			m.totals[operator] -= weight
+			m.total -= weight
+			if m.totals[operator] == 0 {
+				delete(m.totals, operator)
+				m.sampledShare.DeleteLabelValues(operator)
+			}
+		}
+		m.polls[m.next] = weights
+		m.next = (m.next + 1) % m.config.Window
+	}
+	for operator, weight := range weights {
+		m.totals[operator] += weight
+		m.total += weight
+	}
+
+	// Shares over a partial window are too noisy to alert on
+	if len(m.polls) < m.config.Window {
+		return
+	}
+	for operator, weight := range m.totals {
+		share := float64(weight) / float64(m.total)
+		m.sampledShare.WithLabelValues(operator).Set(share)
+		m.check(operator, share, true)
+	}
+}
+
+// CheckSet reports operators holding more than the threshold share of the
+// validator set. It is called whenever the set changes.
+func (m *Monitor) CheckSet(vdrs []Validator) {
+	weights := byOperator(vdrs)
+	var total uint64
+	for _, weight := range weights {
+		total += weight
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.stakedShare.Reset()
+	for alert := range m.alerting {
+		if !alert.Sampled {
+			delete(m.alerting, alert)
+		}
+	}
+	for operator, weight := range weights {
+		share := float64(weight) / float64(total)
+		m.stakedShare.WithLabelValues(operator).Set(share)
+		m.check(operator, share, false)
+	}
+}
+
+// check alerts if [operator] just crossed the threshold. The caller holds
+// the lock.
+func (m *Monitor) check(operator string, share float64, sampled bool) {
+	key := Alert{Operator: operator, Sampled: sampled}
+	if share <= m.config.Threshold {
+		delete(m.alerting, key)
+		return
+	}
+	if _, ok := m.alerting[key]; ok {
+		return
+	}
+	m.alerting[key] = struct{}{}
+	m.alerts.Inc()
+
+	alert := Alert{Operator: operator, Share: share, Sampled: sampled}
+	if sampled {
+		m.log.Warn("operator %s held %.1f%% of the weight sampled over the last %d polls, above the %.1f%% threshold",
+			operator, 100*share, m.config.Window, 100*m.config.Threshold)
+	} else {
+		m.log.Warn("operator %s holds %.1f%% of the validator weight, above the %.1f%% threshold",
+			operator, 100*share, 100*m.config.Threshold)
+	}
+	if m.onAlert != nil {
+		m.onAlert(alert)
+	}
+}
+
+// byOperator sums the weight of [vdrs] per operator. Validators without an
+// operator are counted as their own operator.
+func byOperator(vdrs []Validator) map[string]uint64 {
+	weights := make(map[string]uint64)
+	for _, vdr := range vdrs {
+		operator := vdr.Operator
+		if operator == "" {
+			operator = vdr.NodeID.String()
+		}
+		weights[operator] += vdr.Weight
+	}
+	return weights
+}
@@ -0,0 +1,203 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"ticketsystem/main/ids"
+)
+
+var (
+	errZeroWeight       = errors.New("validator weight must be positive")
+	errWeightOverflow   = errors.New("total validator weight overflows")
+	errMissingValidator = errors.New("not a validator")
+	errEmptySet         = errors.New("validator set is empty")
+)
+
+// Validator is a node that votes in polls with probability proportional to
+// its weight. [Operator] is the entity running the node, if known.
+type Validator struct {
+	NodeID   ids.ShortID `json:"nodeID"`
+	Operator string      `json:"operator,omitempty"`
+	Weight   uint64      `json:"weight"`
+}
+
+// Set is a set of validators. It is safe for concurrent use.
+type Set interface {
+	// Set replaces the validators in the set with [vdrs]
+	Set(vdrs []Validator) error
+	// Add adds [vdr] or, if it is already a validator, replaces it
+	Add(vdr Validator) error
+	Remove(nodeID ids.ShortID) error
+
+	Contains(nodeID ids.ShortID) bool
+	Get(nodeID ids.ShortID) (Validator, bool)
+	// Weight returns the total weight of the set
+	Weight() uint64
+	Len() int
+	// List returns the validators ordered by node ID
+	List() []Validator
+
+	// Sample returns [size] distinct validators, each picked with probability
+	// proportional to its weight among those not yet picked. If the set has
+	// fewer than [size] validators, all of them are returned.
+	Sample(size int) ([]Validator, error)
+}
+
+type set struct {
+	lock  sync.RWMutex
+	vdrs  map[ids.ShortID]Validator
+	total uint64
+	rng   *rand.Rand
+}
+
+// NewSet returns an empty validator set
+func NewSet() Set {
+	return NewSeededSet(rand.Int63()) //#nosec G404 sampling doesn't need to be unpredictable
+}
+
+// NewSeededSet returns an empty validator set whose samples are determined
+// by [seed]. It is used to reproduce simulations.
+func NewSeededSet(seed int64) Set {
+	return &set{
+		vdrs: make(map[ids.ShortID]Validator),
+		rng:  rand.New(rand.NewSource(seed)), //#nosec G404 sampling doesn't need to be unpredictable
+	}
+}
+
+func (s *set) Set(vdrs []Validator) error {
+	byID := make(map[ids.ShortID]Validator, len(vdrs))
+	var total uint64
+	for _, vdr := range vdrs {
+		if vdr.Weight == 0 {
+			return fmt.Errorf("%w: %s", errZeroWeight, vdr.NodeID)
+		}
+		total -= byID[vdr.NodeID].Weight
+		if vdr.Weight > math.MaxInt64-total {
+			return errWeightOverflow
+		}
+		byID[vdr.NodeID] = vdr
+		total += vdr.Weight
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.vdrs = byID
+	s.total = total
+	return nil
+}
+
+func (s *set) Add(vdr Validator) error {
+	if vdr.Weight == 0 {
+		return fmt.Errorf("%w: %s", errZeroWeight, vdr.NodeID)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	total := s.total - s.vdrs[vdr.NodeID].Weight
+	if vdr.Weight > math.MaxInt64-total {
+		return errWeightOverflow
+	}
+	s.vdrs[vdr.NodeID] = vdr
+	s.total = total + vdr.Weight
+	return nil
+}
+
+func (s *set) Remove(nodeID ids.ShortID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	vdr, ok := s.vdrs[nodeID]
+	if !ok {
+		return fmt.Errorf("%w: %s", errMissingValidator, nodeID)
+	}
+	delete(s.vdrs, nodeID)
+	s.total -= vdr.Weight
+	return nil
+}
+
+func (s *set) Contains(nodeID ids.ShortID) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.vdrs[nodeID]
+	return ok
+}
+
+func (s *set) Get(nodeID ids.ShortID) (Validator, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	vdr, ok := s.vdrs[nodeID]
+	return vdr, ok
+}
+
+func (s *set) Weight() uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.total
+}
+
+func (s *set) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.vdrs)
+}
+
+func (s *set) List() []Validator {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.list()
+}
+
+func (s *set) list() []Validator {
+	vdrs := make([]Validator, 0, len(s.vdrs))
+	for _, vdr := range s.vdrs {
+		vdrs = append(vdrs, vdr)
+	}
+	sort.Slice(vdrs, func(i, j int) bool {
+		return string(vdrs[i].NodeID[:]) < string(vdrs[j].NodeID[:])
+	})
+	return vdrs
+}
+
+func (s *set) Sample(size int) ([]Validator, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.vdrs) == 0 {
+		return nil, errEmptySet
+	}
+
+	// The list is sorted so that a seeded sampler is reproducible
+	remaining := s.list()
+	total := s.total
+	if size > len(remaining) {
+		size = len(remaining)
+	}
+	sampled := make([]Validator, 0, size)
+	for len(sampled) < size {
+		// Pick a point in the remaining weight and take the validator
+		// covering it, then remove that validator's weight
+		point := uint64(s.rng.Int63n(int64(total)))
+		for i, vdr := range remaining {
+			if point < vdr.Weight {
+				sampled = append(sampled, vdr)
+				total -= vdr.Weight
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			point -= vdr.Weight
+		}
+	}
+	return sampled, nil
+}
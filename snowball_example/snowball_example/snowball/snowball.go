@@ -41,12 +41,15 @@ type Ticket struct {
 }
 
 type Node struct {
-	ID     int
+	ID int
+	// Weight is the node's stake. Nodes are sampled for polls with
+	// probability proportional to it.
+	Weight uint64
 	Blocks []*TicketBlock
 }
 
 func NewNode(id int) *Node {
-	return &Node{ID: id}
+	return &Node{ID: id, Weight: 1}
 }
 
 func (n *Node) Vote(block *TicketBlock) {
@@ -91,9 +94,32 @@ func (s *Snowball) Run(block *TicketBlock, nodes []*Node) {
 }
 
 func (s *Snowball) voteRound(block *TicketBlock, nodes []*Node) {
-	nodeCount := len(nodes)
-	for i := 0; i < s.k; i++ {
-		nodeIdx := rand.Intn(nodeCount)
-		nodes[nodeIdx].Vote(block)
+	for _, node := range sampleByWeight(nodes, s.k) {
+		node.Vote(block)
+	}
+}
+
+// sampleByWeight picks k distinct nodes, each with probability proportional
+// to its weight among the nodes not yet picked
+func sampleByWeight(nodes []*Node, k int) []*Node {
+	remaining := append([]*Node(nil), nodes...)
+	var total uint64
+	for _, node := range remaining {
+		total += node.Weight
+	}
+
+	sampled := make([]*Node, 0, k)
+	for len(sampled) < k && total > 0 {
+		point := uint64(rand.Int63n(int64(total)))
+		for i, node := range remaining {
+			if point < node.Weight {
+				sampled = append(sampled, node)
+				total -= node.Weight
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			point -= node.Weight
+		}
 	}
+	return sampled
 }
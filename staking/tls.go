@@ -0,0 +1,136 @@
+package staking
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ticketsystem/main/ids"
+)
+
+// certValidity is long enough that a staking certificate never needs to be
+// renewed. Peers identify each other by the certificate's hash, not its
+// expiry.
+const certValidity = 100 * 365 * 24 * time.Hour
+
+var errFileExists = errors.New("staking file already exists")
+
+// NewCertAndKeyBytes returns a new self-signed certificate and its ECDSA
+// P-256 key, both PEM encoded
+func NewCertAndKeyBytes() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "ticketsystem staker"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't marshal key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// InitNodeStakingKeyPair writes a new staking key to [keyPath] and its
+// certificate to [certPath]. Existing files are never overwritten, since
+// replacing them changes the node ID.
+func InitNodeStakingKeyPair(keyPath, certPath string) error {
+	for _, path := range []string{keyPath, certPath} {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%w: %s", errFileExists, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	certPEM, keyPEM, err := NewCertAndKeyBytes()
+	if err != nil {
+		return err
+	}
+	if err := writeFile(keyPath, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("couldn't write key: %w", err)
+	}
+	if err := writeFile(certPath, certPEM, 0o644); err != nil {
+		return fmt.Errorf("couldn't write certificate: %w", err)
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadTLSCert reads the staking key and certificate. If neither file exists
+// and [create] is set, a new pair is generated first.
+func LoadTLSCert(keyPath, certPath string, create bool) (*tls.Certificate, error) {
+	if create {
+		_, keyErr := os.Stat(keyPath)
+		_, certErr := os.Stat(certPath)
+		if errors.Is(keyErr, os.ErrNotExist) && errors.Is(certErr, os.ErrNotExist) {
+			if err := InitNodeStakingKeyPair(keyPath, certPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// NodeID returns the ID of the node holding [cert]: the first 20 bytes of
+// the SHA-256 hash of the DER encoded certificate
+func NodeID(cert *x509.Certificate) ids.ShortID {
+	hash := sha256.Sum256(cert.Raw)
+	var nodeID ids.ShortID
+	copy(nodeID[:], hash[:ids.ShortIDLen])
+	return nodeID
+}
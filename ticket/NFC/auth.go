@@ -0,0 +1,113 @@
+package nfc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// ChallengeSize is the bytes of a gate's challenge
+	ChallengeSize = 16
+	// challengeDomain separates tag responses from other signatures
+	challengeDomain = "ticket-nfc-challenge"
+)
+
+// TicketAID is the ISO 7816 application ID of the ticket app on smart tags
+// and on phones emulating a tag (HCE). It is a proprietary AID: F0 then
+// "TICKET".
+var TicketAID = []byte{0xf0, 'T', 'I', 'C', 'K', 'E', 'T'}
+
+var (
+	errBadChallenge = errors.New("challenge has the wrong length")
+	errBadResponse  = errors.New("tag's response has the wrong length")
+)
+
+// Authenticator signs a gate's challenge with the key bound to a ticket on
+// the chain. Copying a tag's payload doesn't copy the key, so a clone can't
+// answer.
+type Authenticator interface {
+	Authenticate(challenge []byte) ([]byte, error)
+}
+
+// NewChallenge returns a random challenge
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// challengeMessage returns what the tag of [ticketID] signs in answer to
+// [challenge]
+func challengeMessage(ticketID string, challenge []byte) []byte {
+	msg := append([]byte(challengeDomain), 0)
+	msg = binary.AppendUvarint(msg, uint64(len(ticketID)))
+	msg = append(msg, ticketID...)
+	return append(msg, challenge...)
+}
+
+// SignChallenge returns the response of the tag of [ticketID], holding
+// [key], to [challenge]
+func SignChallenge(key ed25519.PrivateKey, ticketID string, challenge []byte) []byte {
+	return ed25519.Sign(key, challengeMessage(ticketID, challenge))
+}
+
+// VerifyResponse returns true if [response] is the answer to [challenge] of
+// the tag of [ticketID] holding the private half of [key]
+func VerifyResponse(key ed25519.PublicKey, ticketID string, challenge, response []byte) bool {
+	if len(key) != ed25519.PublicKeySize || len(challenge) != ChallengeSize || len(response) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(key, challengeMessage(ticketID, challenge), response)
+}
+
+// KeyAuthenticator answers challenges with a key held in software, such as
+// the ticket app emulating a tag on the holder's phone
+type KeyAuthenticator struct {
+	TicketID string
+	Key      ed25519.PrivateKey
+}
+
+// Authenticate implements Authenticator
+func (a *KeyAuthenticator) Authenticate(challenge []byte) ([]byte, error) {
+	if len(challenge) != ChallengeSize {
+		return nil, errBadChallenge
+	}
+	return SignChallenge(a.Key, a.TicketID, challenge), nil
+}
+
+// CardAuthenticator asks a smart tag or a phone emulating one to answer
+// challenges. It selects TicketAID, then sends the challenge in an INTERNAL
+// AUTHENTICATE command; the app answers with SignChallenge.
+type CardAuthenticator struct {
+	card Card
+}
+
+// NewCardAuthenticator returns an Authenticator using [card]
+func NewCardAuthenticator(card Card) *CardAuthenticator {
+	return &CardAuthenticator{card: card}
+}
+
+// Authenticate implements Authenticator
+func (a *CardAuthenticator) Authenticate(challenge []byte) ([]byte, error) {
+	if len(challenge) != ChallengeSize {
+		return nil, errBadChallenge
+	}
+	selectApp := append([]byte{0x00, 0xa4, 0x04, 0x00, byte(len(TicketAID))}, TicketAID...)
+	if _, err := transmit(a.card, append(selectApp, 0x00)); err != nil {
+		return nil, fmt.Errorf("couldn't select the ticket app: %w", err)
+	}
+	authenticate := append([]byte{0x00, 0x88, 0x00, 0x00, ChallengeSize}, challenge...)
+	response, err := transmit(a.card, append(authenticate, 0x00))
+	if err != nil {
+		return nil, err
+	}
+	if len(response) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%w: %d bytes", errBadResponse, len(response))
+	}
+	return response, nil
+}
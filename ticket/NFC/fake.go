@@ -0,0 +1,103 @@
+package nfc
+
+import (
+	"errors"
+	"sync"
+)
+
+var errUnknownCapacity = errors.New("tag type has no known capacity")
+
+// Fake is an in-memory TagReaderWriter. Tags are placed on and removed from
+// it like on a real reader, and their data areas hold the same bytes a real
+// tag would.
+type Fake struct {
+	lock sync.Mutex
+	tag  *Tag
+	data []byte
+}
+
+// NewFake returns a reader with no tag in its field
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Place puts a blank tag of type [tagType] in the reader's field, in place of
+// the tag already there
+func (f *Fake) Place(uid []byte, tagType TagType) error {
+	capacity := tagType.Capacity()
+	if capacity == 0 {
+		return errUnknownCapacity
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.tag = &Tag{
+		UID:      append([]byte(nil), uid...),
+		Type:     tagType,
+		Capacity: capacity,
+	}
+	f.data = make([]byte, capacity)
+	// A blank tag holds an empty NDEF TLV
+	copy(f.data, []byte{tlvNDEF, 0, tlvTerminator})
+	return nil
+}
+
+// Remove takes the tag out of the reader's field
+func (f *Fake) Remove() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.tag = nil
+	f.data = nil
+}
+
+// Tag implements TagReaderWriter
+func (f *Fake) Tag() (*Tag, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tag == nil {
+		return nil, errNoTag
+	}
+	tag := *f.tag
+	tag.UID = append([]byte(nil), f.tag.UID...)
+	return &tag, nil
+}
+
+// ReadMessage implements TagReaderWriter
+func (f *Fake) ReadMessage() (Message, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tag == nil {
+		return nil, errNoTag
+	}
+	b, err := parseTLV(f.data)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errNoNDEF
+	}
+	return ParseMessage(append([]byte(nil), b...))
+}
+
+// WriteMessage implements TagReaderWriter
+func (f *Fake) WriteMessage(m Message) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.tag == nil {
+		return errNoTag
+	}
+	if err := CheckSize(m, f.tag.Capacity); err != nil {
+		return err
+	}
+	b, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	copy(f.data, encodeTLV(b))
+	return nil
+}
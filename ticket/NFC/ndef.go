@@ -0,0 +1,184 @@
+// Package nfc stores tickets on NFC tags as NDEF messages and reads them back
+// through a TagReaderWriter.
+package nfc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TNF is an NDEF record's type name format, which says how its type is to be
+// read
+type TNF byte
+
+const (
+	TNFEmpty       TNF = 0x00
+	TNFWellKnown   TNF = 0x01
+	TNFMIME        TNF = 0x02
+	TNFAbsoluteURI TNF = 0x03
+	TNFExternal    TNF = 0x04
+	TNFUnknown     TNF = 0x05
+	TNFUnchanged   TNF = 0x06
+)
+
+// Flags of a record's header byte
+const (
+	flagMB  = 0x80 // message begin
+	flagME  = 0x40 // message end
+	flagCF  = 0x20 // chunk
+	flagSR  = 0x10 // short record, with a one byte payload length
+	flagIL  = 0x08 // ID length present
+	tnfMask = 0x07
+)
+
+// maxFieldLen is the most bytes a record's type or ID can have
+const maxFieldLen = 255
+
+var (
+	errEmptyMessage  = errors.New("NDEF message has no records")
+	errFieldTooLong  = errors.New("NDEF record type or ID is too long")
+	errBadTNF        = errors.New("invalid NDEF type name format")
+	errBadEmpty      = errors.New("empty NDEF record has a type, ID or payload")
+	errChunked       = errors.New("chunked NDEF records aren't supported")
+	errShortRecord   = errors.New("NDEF record is cut short")
+	errBadBoundaries = errors.New("NDEF message begin and end flags are misplaced")
+	errTrailingData  = errors.New("bytes after the NDEF message end")
+)
+
+// Record is one record of an NDEF message
+type Record struct {
+	TNF     TNF
+	Type    []byte
+	ID      []byte
+	Payload []byte
+}
+
+// NewExternalRecord returns a record of the NFC Forum external type
+// [domainType], written "domain:type"
+func NewExternalRecord(domainType string, payload []byte) Record {
+	return Record{
+		TNF:     TNFExternal,
+		Type:    []byte(domainType),
+		Payload: payload,
+	}
+}
+
+func (r *Record) verify() error {
+	switch {
+	case r.TNF > TNFUnchanged:
+		return fmt.Errorf("%w: %d", errBadTNF, r.TNF)
+	case r.TNF == TNFEmpty && (len(r.Type) != 0 || len(r.ID) != 0 || len(r.Payload) != 0):
+		return errBadEmpty
+	case len(r.Type) > maxFieldLen || len(r.ID) > maxFieldLen:
+		return errFieldTooLong
+	}
+	return nil
+}
+
+// Message is an NDEF message, the records written to a tag
+type Message []Record
+
+// Bytes returns the message's binary encoding
+func (m Message) Bytes() ([]byte, error) {
+	if len(m) == 0 {
+		return nil, errEmptyMessage
+	}
+	var buf bytes.Buffer
+	for i := range m {
+		r := &m[i]
+		if err := r.verify(); err != nil {
+			return nil, err
+		}
+		header := byte(r.TNF)
+		if i == 0 {
+			header |= flagMB
+		}
+		if i == len(m)-1 {
+			header |= flagME
+		}
+		if len(r.Payload) <= 0xff {
+			header |= flagSR
+		}
+		if len(r.ID) != 0 {
+			header |= flagIL
+		}
+
+		buf.WriteByte(header)
+		buf.WriteByte(byte(len(r.Type)))
+		if header&flagSR != 0 {
+			buf.WriteByte(byte(len(r.Payload)))
+		} else {
+			_ = binary.Write(&buf, binary.BigEndian, uint32(len(r.Payload)))
+		}
+		if header&flagIL != 0 {
+			buf.WriteByte(byte(len(r.ID)))
+		}
+		buf.Write(r.Type)
+		buf.Write(r.ID)
+		buf.Write(r.Payload)
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseMessage parses the binary encoding of an NDEF message
+func ParseMessage(b []byte) (Message, error) {
+	var m Message
+	for end := false; !end; {
+		if len(b) == 0 {
+			return nil, errShortRecord
+		}
+		header := b[0]
+		b = b[1:]
+		if (header&flagMB != 0) != (len(m) == 0) {
+			return nil, errBadBoundaries
+		}
+		if header&flagCF != 0 {
+			return nil, errChunked
+		}
+		end = header&flagME != 0
+
+		lengths := 2
+		if header&flagSR == 0 {
+			lengths += 3
+		}
+		if header&flagIL != 0 {
+			lengths++
+		}
+		if len(b) < lengths {
+			return nil, errShortRecord
+		}
+		typeLen := int(b[0])
+		var payloadLen uint64
+		if header&flagSR != 0 {
+			payloadLen = uint64(b[1])
+		} else {
+			payloadLen = uint64(binary.BigEndian.Uint32(b[1:5]))
+		}
+		idLen := 0
+		if header&flagIL != 0 {
+			idLen = int(b[lengths-1])
+		}
+		b = b[lengths:]
+		if uint64(len(b)) < uint64(typeLen)+uint64(idLen)+payloadLen {
+			return nil, errShortRecord
+		}
+
+		r := Record{
+			TNF:     TNF(header & tnfMask),
+			Type:    b[:typeLen:typeLen],
+			ID:      b[typeLen : typeLen+idLen : typeLen+idLen],
+			Payload: b[typeLen+idLen : typeLen+idLen+int(payloadLen) : typeLen+idLen+int(payloadLen)],
+		}
+		if err := r.verify(); err != nil {
+			return nil, err
+		}
+		m = append(m, r)
+		b = b[typeLen+idLen+int(payloadLen):]
+	}
+	if len(b) != 0 {
+		return nil, fmt.Errorf("%w: %d", errTrailingData, len(b))
+	}
+	return m, nil
+}
@@ -0,0 +1,184 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Card sends APDUs to the card in a PC/SC reader's field and returns its
+// responses, status word included. *scard.Card from github.com/ebfe/scard
+// satisfies it.
+type Card interface {
+	Transmit(apdu []byte) ([]byte, error)
+}
+
+const (
+	// pageSize is the bytes in a page, the unit type 2 tags are written in
+	pageSize = 4
+	// readSize is the bytes returned by a read, four pages
+	readSize = 16
+	// ccPage holds the capability container, and dataPage is where the NDEF
+	// data area starts
+	ccPage   = 3
+	dataPage = 4
+	// ccMagic starts the capability container of an NDEF formatted tag
+	ccMagic = 0xe1
+	// ccReadOnly is the access byte of a tag that can't be written
+	ccReadOnly = 0x0f
+)
+
+var (
+	errNotFormatted = errors.New("tag isn't NDEF formatted")
+	errReadOnly     = errors.New("tag is read only")
+	errAPDU         = errors.New("reader rejected the command")
+)
+
+// PCSC is a TagReaderWriter for type 2 tags on a PC/SC reader, using the
+// pseudo APDUs of the PC/SC storage card specification that most USB readers
+// understand
+type PCSC struct {
+	card Card
+}
+
+// NewPCSC returns a TagReaderWriter using [card], the connection to a tag
+func NewPCSC(card Card) *PCSC {
+	return &PCSC{card: card}
+}
+
+// transmit sends [apdu] to [card] and returns the response without its
+// status word
+func transmit(card Card, apdu []byte) ([]byte, error) {
+	resp, err := card.Transmit(apdu)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 {
+		return nil, fmt.Errorf("%w: short response", errAPDU)
+	}
+	body, sw := resp[:len(resp)-2], resp[len(resp)-2:]
+	if sw[0] != 0x90 || sw[1] != 0x00 {
+		return nil, fmt.Errorf("%w: status %02X%02X", errAPDU, sw[0], sw[1])
+	}
+	return body, nil
+}
+
+// read returns the four pages from [page]
+func (p *PCSC) read(page int) ([]byte, error) {
+	b, err := transmit(p.card, []byte{0xff, 0xb0, 0x00, byte(page), readSize})
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != readSize {
+		return nil, fmt.Errorf("%w: read %d bytes of page %d", errAPDU, len(b), page)
+	}
+	return b, nil
+}
+
+// write writes [data], a page, to [page]
+func (p *PCSC) write(page int, data []byte) error {
+	apdu := append([]byte{0xff, 0xd6, 0x00, byte(page), pageSize}, data...)
+	_, err := transmit(p.card, apdu)
+	return err
+}
+
+// capabilities returns the capability container of the tag
+func (p *PCSC) capabilities() ([]byte, error) {
+	b, err := p.read(ccPage)
+	if err != nil {
+		return nil, err
+	}
+	cc := b[:pageSize]
+	if cc[0] != ccMagic {
+		return nil, errNotFormatted
+	}
+	return cc, nil
+}
+
+// Tag implements TagReaderWriter
+func (p *PCSC) Tag() (*Tag, error) {
+	uid, err := transmit(p.card, []byte{0xff, 0xca, 0x00, 0x00, 0x00})
+	if err != nil {
+		return nil, err
+	}
+	cc, err := p.capabilities()
+	if err != nil {
+		return nil, err
+	}
+	capacity := int(cc[2]) * 8
+	return &Tag{
+		UID:      uid,
+		Type:     tagTypeOf(capacity),
+		Capacity: capacity,
+	}, nil
+}
+
+// ReadMessage implements TagReaderWriter. The data area is read until the
+// NDEF TLV is complete, so small messages on large tags read quickly.
+func (p *PCSC) ReadMessage() (Message, error) {
+	cc, err := p.capabilities()
+	if err != nil {
+		return nil, err
+	}
+	capacity := int(cc[2]) * 8
+
+	var data []byte
+	for page := dataPage; len(data) < capacity; page += readSize / pageSize {
+		b, err := p.read(page)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+
+		message, err := parseTLV(data)
+		switch {
+		case errors.Is(err, errShortTLV):
+			continue
+		case err != nil:
+			return nil, err
+		case len(message) == 0:
+			return nil, errNoNDEF
+		}
+		return ParseMessage(message)
+	}
+	return nil, errShortTLV
+}
+
+// WriteMessage implements TagReaderWriter. The TLV's length is written as
+// zero first and set last, so a tag pulled away mid write holds an empty
+// message rather than a mix of the old and new ones.
+func (p *PCSC) WriteMessage(m Message) error {
+	cc, err := p.capabilities()
+	if err != nil {
+		return err
+	}
+	if cc[3] == ccReadOnly {
+		return errReadOnly
+	}
+	if err := CheckSize(m, int(cc[2])*8); err != nil {
+		return err
+	}
+	b, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	tlv := encodeTLV(b)
+	for len(tlv)%pageSize != 0 {
+		tlv = append(tlv, tlvNull)
+	}
+
+	first := append([]byte(nil), tlv[:pageSize]...)
+	if first[1] == tlvLongLen {
+		first[2], first[3] = 0, 0
+	} else {
+		first[1] = 0
+	}
+	if err := p.write(dataPage, first); err != nil {
+		return err
+	}
+	for i := pageSize; i < len(tlv); i += pageSize {
+		if err := p.write(dataPage+i/pageSize, tlv[i:i+pageSize]); err != nil {
+			return err
+		}
+	}
+	return p.write(dataPage, tlv[:pageSize])
+}
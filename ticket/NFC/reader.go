@@ -0,0 +1,17 @@
+package nfc
+
+import "errors"
+
+var errNoTag = errors.New("no tag in the reader's field")
+
+// TagReaderWriter reads and writes the NDEF message of the tag held to a
+// reader. The fake in this package stands in for a reader in development;
+// PCSC drives USB readers through the PC/SC API.
+type TagReaderWriter interface {
+	// Tag returns the tag in the reader's field
+	Tag() (*Tag, error)
+	// ReadMessage returns the NDEF message on the tag
+	ReadMessage() (Message, error)
+	// WriteMessage replaces the NDEF message on the tag
+	WriteMessage(m Message) error
+}
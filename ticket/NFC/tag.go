@@ -0,0 +1,144 @@
+package nfc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TagType is a model of NFC Forum type 2 tag
+type TagType int
+
+const (
+	UnknownTag TagType = iota
+	NTAG213
+	NTAG215
+	NTAG216
+)
+
+// Bytes of a type 2 tag's TLV blocks. The NDEF message is written in an NDEF
+// TLV, followed by a terminator.
+const (
+	tlvNull       = 0x00
+	tlvNDEF       = 0x03
+	tlvTerminator = 0xfe
+	// tlvLongLen introduces a three byte length, for values of 255 bytes or
+	// more
+	tlvLongLen = 0xff
+)
+
+var (
+	errMessageTooLarge = errors.New("NDEF message doesn't fit on the tag")
+	errNoNDEF          = errors.New("tag holds no NDEF message")
+	errShortTLV        = errors.New("NDEF TLV is cut short")
+)
+
+func (t TagType) String() string {
+	switch t {
+	case NTAG213:
+		return "NTAG213"
+	case NTAG215:
+		return "NTAG215"
+	case NTAG216:
+		return "NTAG216"
+	default:
+		return "unknown tag"
+	}
+}
+
+// Capacity returns the bytes of the tag's NDEF data area, as advertised in
+// its capability container. The NDEF TLV around the message takes 3 to 5 of
+// them.
+func (t TagType) Capacity() int {
+	switch t {
+	case NTAG213:
+		return 144
+	case NTAG215:
+		return 496
+	case NTAG216:
+		return 872
+	default:
+		return 0
+	}
+}
+
+// tagTypeOf returns the model with a data area of [capacity] bytes
+func tagTypeOf(capacity int) TagType {
+	for _, t := range []TagType{NTAG213, NTAG215, NTAG216} {
+		if t.Capacity() == capacity {
+			return t
+		}
+	}
+	return UnknownTag
+}
+
+// Tag is a tag in the field of a reader
+type Tag struct {
+	UID  []byte
+	Type TagType
+	// Capacity is the bytes of the tag's NDEF data area
+	Capacity int
+}
+
+// CheckSize returns an error if [m] doesn't fit in [capacity] bytes of NDEF
+// data area
+func CheckSize(m Message, capacity int) error {
+	b, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+	if size := len(encodeTLV(b)); size > capacity {
+		return fmt.Errorf("%w: %d bytes, tag holds %d", errMessageTooLarge, size, capacity)
+	}
+	return nil
+}
+
+// encodeTLV returns [message] in an NDEF TLV followed by a terminator, as
+// written to a tag's data area
+func encodeTLV(message []byte) []byte {
+	var tlv []byte
+	if len(message) < tlvLongLen {
+		tlv = []byte{tlvNDEF, byte(len(message))}
+	} else {
+		tlv = []byte{tlvNDEF, tlvLongLen, byte(len(message) >> 8), byte(len(message))}
+	}
+	tlv = append(tlv, message...)
+	return append(tlv, tlvTerminator)
+}
+
+// parseTLV returns the message in the first NDEF TLV of [data], the start of
+// a tag's data area. It returns errShortTLV if [data] ends before the
+// message does, so more of the tag can be read.
+func parseTLV(data []byte) ([]byte, error) {
+	for len(data) != 0 {
+		t := data[0]
+		data = data[1:]
+		switch t {
+		case tlvNull:
+			continue
+		case tlvTerminator:
+			return nil, errNoNDEF
+		}
+
+		if len(data) == 0 {
+			return nil, errShortTLV
+		}
+		length := int(data[0])
+		data = data[1:]
+		if length == tlvLongLen {
+			if len(data) < 2 {
+				return nil, errShortTLV
+			}
+			length = int(data[0])<<8 | int(data[1])
+			data = data[2:]
+		}
+		if len(data) < length {
+			return nil, errShortTLV
+		}
+		if t == tlvNDEF {
+			return data[:length], nil
+		}
+		// Lock and memory control TLVs say nothing about the message
+		data = data[length:]
+	}
+	return nil, errShortTLV
+}
@@ -0,0 +1,53 @@
+package nfc
+
+import (
+	"errors"
+	"strings"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+// TicketType is the NFC Forum external type of the record holding a ticket's
+// signed payload, the same one a QR code holds in base45
+const TicketType = "ticketsystem:ticket"
+
+var errNoTicket = errors.New("NDEF message holds no ticket")
+
+// TicketMessage returns the NDEF message written to a ticket's tag
+func TicketMessage(p *qr.Payload) (Message, error) {
+	b, err := p.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return Message{NewExternalRecord(TicketType, b)}, nil
+}
+
+// ParseTicket returns the payload in the first ticket record of [m]. The
+// signature isn't checked; pass the payload to qr.Verifier.VerifyPayload.
+func ParseTicket(m Message) (*qr.Payload, error) {
+	for _, r := range m {
+		// External type names are case insensitive
+		if r.TNF == TNFExternal && strings.EqualFold(string(r.Type), TicketType) {
+			return qr.ParsePayload(r.Payload)
+		}
+	}
+	return nil, errNoTicket
+}
+
+// WriteTicket writes [p] to the tag held to [rw]
+func WriteTicket(rw TagReaderWriter, p *qr.Payload) error {
+	m, err := TicketMessage(p)
+	if err != nil {
+		return err
+	}
+	return rw.WriteMessage(m)
+}
+
+// ReadTicket returns the payload on the tag held to [rw]
+func ReadTicket(rw TagReaderWriter) (*qr.Payload, error) {
+	m, err := rw.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return ParseTicket(m)
+}
@@ -19,16 +19,20 @@ import (
 type Ticket struct {
 	ID     int32
 	Seller string
-	Price  float64
-	Status int32
+	// PriceAmount is in the minor unit of the ISO 4217 PriceCurrency, so
+	// the serialized ticket, and its hash, are exact
+	PriceAmount   int64
+	PriceCurrency string
+	Status        int32
 }
 
 func (t *Ticket) Serialize() ([]byte, error) {
 	pb := &ticketpb.Ticket{
-		Id:     t.ID,
-		Seller: t.Seller,
-		Price:  t.Price,
-		Status: t.Status,
+		Id:            t.ID,
+		Seller:        t.Seller,
+		PriceAmount:   t.PriceAmount,
+		PriceCurrency: t.PriceCurrency,
+		Status:        t.Status,
 	}
 	return proto.Marshal(pb)
 }
@@ -116,10 +120,11 @@ func main() {
 
 	// Call the smart contract function to add a new ticket
 	newTicket := &Ticket{
-		ID:     1,
-		Seller: "John",
-		Price:  10.5,
-		Status: 0,
+		ID:            1,
+		Seller:        "John",
+		PriceAmount:   1050,
+		PriceCurrency: "EUR",
+		Status:        0,
 	}
 	data, err := newTicket.Serialize()
 	if err != nil {
@@ -148,10 +153,11 @@ func main() {
 // Serialize converts Ticket object to Protobuf byte slice
 func (t *Ticket) Serialize() ([]byte, error) {
 	pb := &ticketpb.Ticket{
-		Id:     t.ID,
-		Seller: t.Seller,
-		Price:  t.Price,
-		Status: t.Status,
+		Id:            t.ID,
+		Seller:        t.Seller,
+		PriceAmount:   t.PriceAmount,
+		PriceCurrency: t.PriceCurrency,
+		Status:        t.Status,
 	}
 	return proto.Marshal(pb)
 }
@@ -13,14 +13,18 @@ import (
 type Ticket struct {
 	ID     int32
 	Seller string
-	Price  float64
+	// PriceAmount is in the minor unit of the ISO 4217 PriceCurrency, so
+	// the serialized ticket, and its hash, are exact
+	PriceAmount   int64
+	PriceCurrency string
 }
 
 func (t *Ticket) serialize() ([]byte, error) {
 	pb := &ticketpb.Ticket{
-		Id:     t.ID,
-		Seller: t.Seller,
-		Price:  t.Price,
+		Id:            t.ID,
+		Seller:        t.Seller,
+		PriceAmount:   t.PriceAmount,
+		PriceCurrency: t.PriceCurrency,
 	}
 	return proto.Marshal(pb)
 }
@@ -1,19 +1,23 @@
+// Package qr encodes tickets as signed QR codes that gates verify offline.
 package qr
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
+	"fmt"
 	"image/png"
+
+	"github.com/skip2/go-qrcode"
 )
 
+// GenerateQRCode returns a PNG of the QR code holding [data], [size] pixels
+// wide
 func GenerateQRCode(data string, level qrcode.RecoveryLevel, size int) ([]byte, error) {
 	qr, err := qrcode.New(data, level)
 	if err != nil {
 		return nil, err
 	}
 	qr.DisableBorder = true
-	img := qr.ToImage(size)
+	img := qr.Image(size)
 	var buf bytes.Buffer
 	err = png.Encode(&buf, img)
 	if err != nil {
@@ -22,25 +26,44 @@ func GenerateQRCode(data string, level qrcode.RecoveryLevel, size int) ([]byte,
 	return buf.Bytes(), nil
 }
 
-func (t *Ticket) WriteToQR() ([]byte, error) {
-	// Write the ticket information to a QR code
-	data := t.serialize()
-	qrBytes, err := GenerateQRCode(data, qrcode.Medium, 256)
+// GenerateSVG returns an SVG of the QR code holding [data]. Each row of dark
+// modules is drawn as runs of a single path, so the file stays small and
+// prints sharply at any size. Unlike GenerateQRCode it keeps the quiet zone,
+// as an SVG is usually placed on a page without one.
+func GenerateSVG(data string, level qrcode.RecoveryLevel) ([]byte, error) {
+	qr, err := qrcode.New(data, level)
 	if err != nil {
 		return nil, err
 	}
-	return qrBytes, nil
-}
+	bitmap := qr.Bitmap()
+	size := len(bitmap)
 
-func (t *Ticket) VerifyQR(qrData string) bool {
-	// Verify the integrity of the ticket information in the QR code
-	hash := t.calculateHash()
-	dataHash := calculateDataHash(qrData)
-	return hash == dataHash
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
 }
 
-func calculateDataHash(data string) string {
-	h := sha256.New()
-	h.Write([]byte(data))
-	return hex.EncodeToString(h.Sum(nil))
+// WriteToQR returns a PNG of the QR code holding the signed payload. Base45
+// text is only made of characters of the QR alphanumeric mode, so the code
+// stays small enough to scan from a phone screen.
+func (p *Payload) WriteToQR(level qrcode.RecoveryLevel, size int) ([]byte, error) {
+	code, err := p.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return GenerateQRCode(code, level, size)
 }
@@ -0,0 +1,64 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// base45Alphabet is the QR alphanumeric mode's character set, so base45 text
+// is encoded at 5.5 bits per character instead of 8 in byte mode (RFC 9285)
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+var errBadBase45 = errors.New("invalid base45")
+
+// EncodeBase45 encodes [b] as base45
+func EncodeBase45(b []byte) string {
+	var sb strings.Builder
+	sb.Grow((len(b)/2)*3 + (len(b)%2)*2)
+	for i := 0; i+1 < len(b); i += 2 {
+		n := int(b[i])<<8 | int(b[i+1])
+		sb.WriteByte(base45Alphabet[n%45])
+		sb.WriteByte(base45Alphabet[(n/45)%45])
+		sb.WriteByte(base45Alphabet[n/(45*45)])
+	}
+	if len(b)%2 == 1 {
+		n := int(b[len(b)-1])
+		sb.WriteByte(base45Alphabet[n%45])
+		sb.WriteByte(base45Alphabet[n/45])
+	}
+	return sb.String()
+}
+
+// DecodeBase45 decodes base45 text
+func DecodeBase45(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, fmt.Errorf("%w: length %d", errBadBase45, len(s))
+	}
+	digits := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(base45Alphabet, s[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("%w: character %q", errBadBase45, s[i])
+		}
+		digits[i] = digit
+	}
+
+	b := make([]byte, 0, (len(s)/3)*2+(len(s)%3)/2)
+	for i := 0; i+2 < len(digits); i += 3 {
+		n := digits[i] + digits[i+1]*45 + digits[i+2]*45*45
+		if n > 0xffff {
+			return nil, fmt.Errorf("%w: group %q overflows", errBadBase45, s[i:i+3])
+		}
+		b = append(b, byte(n>>8), byte(n))
+	}
+	if len(digits)%3 == 2 {
+		i := len(digits) - 2
+		n := digits[i] + digits[i+1]*45
+		if n > 0xff {
+			return nil, fmt.Errorf("%w: group %q overflows", errBadBase45, s[i:])
+		}
+		b = append(b, byte(n))
+	}
+	return b, nil
+}
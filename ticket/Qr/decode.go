@@ -0,0 +1,97 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	// Register the formats scanners upload
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+const (
+	// maxImageSize bounds the size of the images read. Base64 encoded, it
+	// still fits in the API's 1 MiB request bodies.
+	maxImageSize = 512 << 10 // 512 KiB
+	// maxImagePixels bounds the images decoded, so a small file can't make
+	// the decoder allocate a huge bitmap
+	maxImagePixels = 16 << 20
+)
+
+var (
+	errImageTooLarge = errors.New("image is too large")
+	errNoQRCode      = errors.New("no QR code found in image")
+)
+
+// DecodeImage returns the text of the QR code in the PNG or JPEG read from
+// [r], such as a photo uploaded by a scanner
+func DecodeImage(r io.Reader) (string, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxImageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxImageSize {
+		return "", fmt.Errorf("%w: more than %d bytes", errImageTooLarge, maxImageSize)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return "", fmt.Errorf("%w: %dx%d %s", errImageTooLarge, config.Width, config.Height, format)
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	return DecodeQRImage(img)
+}
+
+// DecodeQRImage returns the text of the QR code in [img]
+func DecodeQRImage(img image.Image) (string, error) {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(withQuietZone(img))
+	if err != nil {
+		return "", err
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	result, err := qrcode.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errNoQRCode, err)
+	}
+	return result.GetText(), nil
+}
+
+// withQuietZone returns [img] on a white margin. The finder patterns are only
+// found with some white around them, and GenerateQRCode leaves no border.
+func withQuietZone(img image.Image) image.Image {
+	bounds := img.Bounds()
+	margin := bounds.Dx() / 10
+	if margin < 8 {
+		margin = 8
+	}
+	padded := image.NewGray(image.Rect(0, 0, bounds.Dx()+2*margin, bounds.Dy()+2*margin))
+	draw.Draw(padded, padded.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	// Drawn over white, so transparent pixels read as light modules
+	draw.Draw(padded, bounds.Sub(bounds.Min).Add(image.Pt(margin, margin)), img, bounds.Min, draw.Over)
+	return padded
+}
+
+// VerifyImage decodes the QR code in the PNG or JPEG read from [r] and checks
+// its signed payload
+func (v *Verifier) VerifyImage(r io.Reader) (*Payload, error) {
+	code, err := DecodeImage(r)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(code)
+}
@@ -0,0 +1,44 @@
+package qr
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestDecodeImage(t *testing.T) {
+	p := &Payload{TicketID: "t1", EventID: "ev", Rotation: 3, NotBefore: 1, NotAfter: 2}
+	code, err := p.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := map[string]qrcode.RecoveryLevel{
+		"low":     qrcode.Low,
+		"medium":  qrcode.Medium,
+		"high":    qrcode.High,
+		"highest": qrcode.Highest,
+	}
+	for name, level := range levels {
+		t.Run(name, func(t *testing.T) {
+			png, err := GenerateQRCode(code, level, 512)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, err := DecodeImage(bytes.NewReader(png))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != code {
+				t.Fatalf("decoded %q, expected %q", text, code)
+			}
+		})
+	}
+}
+
+func TestDecodeImageTooLarge(t *testing.T) {
+	_, err := DecodeImage(bytes.NewReader(make([]byte, maxImageSize+1)))
+	if err == nil {
+		t.Fatal("expected an error for an oversized image")
+	}
+}
@@ -0,0 +1,169 @@
+package qr
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"ticketsystem/main/vms/ticketvm"
+)
+
+var (
+	errUnknownEvent     = errors.New("event isn't in the key cache")
+	errUnknownOrganizer = errors.New("organizer's key isn't in the key cache")
+	errBadKey           = errors.New("invalid organizer public key")
+	errNoRotationKey    = errors.New("event's rotation key isn't in the key cache")
+	errBadRotationKey   = errors.New("invalid rotation key")
+)
+
+// KeyCache holds the organizers' public keys and which organizer owns each
+// event. It is filled while the scanner is online and saved, so that codes
+// can be verified when the venue's network is down.
+type KeyCache struct {
+	lock sync.RWMutex
+	// organizers' keys, by address
+	organizers map[string]ed25519.PublicKey
+	// events' organizer addresses, by event ID
+	events map[string]string
+	// events' rotation keys, by event ID. Unlike the organizers' keys these
+	// are secret, so only the organizer's own scanners should hold them.
+	rotationKeys map[string][]byte
+}
+
+// NewKeyCache returns an empty cache
+func NewKeyCache() *KeyCache {
+	return &KeyCache{
+		organizers:   make(map[string]ed25519.PublicKey),
+		events:       make(map[string]string),
+		rotationKeys: make(map[string][]byte),
+	}
+}
+
+// AddOrganizer caches [key] and returns the organizer's address
+func (c *KeyCache) AddOrganizer(key ed25519.PublicKey) (string, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return "", errBadKey
+	}
+	address := ticketvm.Address(key)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.organizers[address] = append(ed25519.PublicKey(nil), key...)
+	return address, nil
+}
+
+// AddEvent records that [organizer], an address, owns [eventID]
+func (c *KeyCache) AddEvent(eventID, organizer string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.events[eventID] = organizer
+}
+
+// AddRotationKey caches the key rotating codes for [eventID] are derived from
+func (c *KeyCache) AddRotationKey(eventID string, key []byte) error {
+	if len(key) != RotationKeySize {
+		return errBadRotationKey
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.rotationKeys[eventID] = append([]byte(nil), key...)
+	return nil
+}
+
+// RotationKey returns the key rotating codes for [eventID] are derived from
+func (c *KeyCache) RotationKey(eventID string) ([]byte, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	key, ok := c.rotationKeys[eventID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errNoRotationKey, eventID)
+	}
+	return key, nil
+}
+
+// EventKey returns the key of the organizer of [eventID]
+func (c *KeyCache) EventKey(eventID string) (ed25519.PublicKey, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	organizer, ok := c.events[eventID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownEvent, eventID)
+	}
+	key, ok := c.organizers[organizer]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownOrganizer, organizer)
+	}
+	return key, nil
+}
+
+// keyCacheJSON is how a cache is saved. Keys are hex encoded.
+type keyCacheJSON struct {
+	Organizers   []string          `json:"organizers"`
+	Events       map[string]string `json:"events"`
+	RotationKeys map[string]string `json:"rotationKeys,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (c *KeyCache) MarshalJSON() ([]byte, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	saved := keyCacheJSON{
+		Organizers: make([]string, 0, len(c.organizers)),
+		Events:     c.events,
+	}
+	for _, key := range c.organizers {
+		saved.Organizers = append(saved.Organizers, hex.EncodeToString(key))
+	}
+	if len(c.rotationKeys) != 0 {
+		saved.RotationKeys = make(map[string]string, len(c.rotationKeys))
+		for eventID, key := range c.rotationKeys {
+			saved.RotationKeys[eventID] = hex.EncodeToString(key)
+		}
+	}
+	return json.Marshal(saved)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (c *KeyCache) UnmarshalJSON(b []byte) error {
+	saved := keyCacheJSON{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return err
+	}
+	organizers := make(map[string]ed25519.PublicKey, len(saved.Organizers))
+	for _, str := range saved.Organizers {
+		key, err := hex.DecodeString(str)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("%w: %s", errBadKey, str)
+		}
+		organizers[ticketvm.Address(key)] = key
+	}
+	if saved.Events == nil {
+		saved.Events = make(map[string]string)
+	}
+	rotationKeys := make(map[string][]byte, len(saved.RotationKeys))
+	for eventID, str := range saved.RotationKeys {
+		key, err := hex.DecodeString(str)
+		if err != nil || len(key) != RotationKeySize {
+			return fmt.Errorf("%w: event %s", errBadRotationKey, eventID)
+		}
+		rotationKeys[eventID] = key
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.organizers = organizers
+	c.events = saved.Events
+	c.rotationKeys = rotationKeys
+	return nil
+}
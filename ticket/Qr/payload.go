@@ -0,0 +1,232 @@
+package qr
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// PayloadVersion is the version of the payload encoding. It is the first
+// byte of every payload.
+const PayloadVersion = 1
+
+// maxIDLen bounds the ticket and event IDs so payloads fit low density codes
+const maxIDLen = 64
+
+// signingDomain separates payload signatures from any other message the
+// organizer's key signs, such as transactions
+const signingDomain = "ticket-qr-payload"
+
+var (
+	errIDTooLong          = errors.New("ID is too long for a QR payload")
+	errBadWindow          = errors.New("payload expires before it becomes valid")
+	errTruncatedPayload   = errors.New("truncated QR payload")
+	errTrailingBytes      = errors.New("trailing bytes after QR payload")
+	errUnsupportedVersion = errors.New("unsupported QR payload version")
+)
+
+// Commitment binds a payload to the ticket's holder without revealing the
+// holder's address to whoever reads the code
+type Commitment [16]byte
+
+// NewCommitment returns the commitment to [holder] holding [ticketID]. The
+// holder keeps [salt] and reveals it, together with its address, to prove
+// that the code was issued to it.
+func NewCommitment(ticketID, holder string, salt []byte) Commitment {
+	h := sha256.New()
+	writeField(h, []byte(ticketID))
+	writeField(h, []byte(holder))
+	writeField(h, salt)
+	var c Commitment
+	copy(c[:], h.Sum(nil))
+	return c
+}
+
+func writeField(h interface{ Write([]byte) (int, error) }, b []byte) {
+	var length [binary.MaxVarintLen64]byte
+	_, _ = h.Write(length[:binary.PutUvarint(length[:], uint64(len(b)))])
+	_, _ = h.Write(b)
+}
+
+// Payload is the content of a ticket's QR code. It is signed by the event's
+// organizer, so a gate can check it offline with the organizer's public key,
+// and nobody without the key can make a code for another ticket or extend
+// a code's validity.
+type Payload struct {
+	TicketID string
+	EventID  string
+	Holder   Commitment
+	// Rotation is the ticket's rotation when the code was issued. The chain
+	// bumps it whenever the ticket changes holder, so gates reject codes
+	// issued to a previous holder.
+	Rotation uint64
+	// NotBefore and NotAfter bound when the code is accepted, in Unix
+	// seconds
+	NotBefore int64
+	NotAfter  int64
+	Signature [ed25519.SignatureSize]byte
+}
+
+// unsignedBytes returns the encoding of everything but the signature:
+//
+//	version | uvarint len | ticket ID | uvarint len | event ID | holder
+//	| uvarint rotation | uvarint not before | uvarint validity
+func (p *Payload) unsignedBytes() ([]byte, error) {
+	switch {
+	case len(p.TicketID) > maxIDLen:
+		return nil, fmt.Errorf("%w: ticket %d bytes", errIDTooLong, len(p.TicketID))
+	case len(p.EventID) > maxIDLen:
+		return nil, fmt.Errorf("%w: event %d bytes", errIDTooLong, len(p.EventID))
+	case p.NotBefore < 0 || p.NotAfter < p.NotBefore:
+		return nil, errBadWindow
+	}
+	b := make([]byte, 0, 1+5*binary.MaxVarintLen64+len(p.TicketID)+len(p.EventID)+len(p.Holder)+ed25519.SignatureSize)
+	b = append(b, PayloadVersion)
+	b = binary.AppendUvarint(b, uint64(len(p.TicketID)))
+	b = append(b, p.TicketID...)
+	b = binary.AppendUvarint(b, uint64(len(p.EventID)))
+	b = append(b, p.EventID...)
+	b = append(b, p.Holder[:]...)
+	b = binary.AppendUvarint(b, p.Rotation)
+	b = binary.AppendUvarint(b, uint64(p.NotBefore))
+	// The window is stored as a duration, which is much shorter than a
+	// second timestamp
+	b = binary.AppendUvarint(b, uint64(p.NotAfter-p.NotBefore))
+	return b, nil
+}
+
+func signedMessage(unsigned []byte) []byte {
+	return append([]byte(signingDomain), unsigned...)
+}
+
+// Sign signs the payload with the organizer's [key]
+func (p *Payload) Sign(key ed25519.PrivateKey) error {
+	unsigned, err := p.unsignedBytes()
+	if err != nil {
+		return err
+	}
+	copy(p.Signature[:], ed25519.Sign(key, signedMessage(unsigned)))
+	return nil
+}
+
+// VerifySignature returns true if the payload was signed by [key]
+func (p *Payload) VerifySignature(key ed25519.PublicKey) bool {
+	unsigned, err := p.unsignedBytes()
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key, signedMessage(unsigned), p.Signature[:])
+}
+
+// VerifyHolder returns true if the payload was issued to [holder], as
+// proven by the [salt] the holder was given
+func (p *Payload) VerifyHolder(holder string, salt []byte) bool {
+	return NewCommitment(p.TicketID, holder, salt) == p.Holder
+}
+
+// Bytes returns the binary encoding of the signed payload
+func (p *Payload) Bytes() ([]byte, error) {
+	b, err := p.unsignedBytes()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, p.Signature[:]...), nil
+}
+
+// Encode returns the text put in the QR code: the payload's binary encoding
+// in base45, which QR codes store in their compact alphanumeric mode
+func (p *Payload) Encode() (string, error) {
+	b, err := p.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return EncodeBase45(b), nil
+}
+
+// ParsePayload parses the binary encoding of a payload. The signature isn't
+// checked.
+func ParsePayload(b []byte) (*Payload, error) {
+	r := reader{b: b}
+	if version := r.byte(); r.err == nil && version != PayloadVersion {
+		return nil, fmt.Errorf("%w: %d", errUnsupportedVersion, version)
+	}
+	p := &Payload{
+		TicketID: r.string(),
+		EventID:  r.string(),
+	}
+	copy(p.Holder[:], r.bytes(len(p.Holder)))
+	p.Rotation = r.uvarint()
+	notBefore, validity := r.uvarint(), r.uvarint()
+	copy(p.Signature[:], r.bytes(ed25519.SignatureSize))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.b) != 0 {
+		return nil, fmt.Errorf("%w: %d", errTrailingBytes, len(r.b))
+	}
+	if notBefore > 1<<62 || validity > 1<<62 {
+		return nil, errBadWindow
+	}
+	p.NotBefore = int64(notBefore)
+	p.NotAfter = p.NotBefore + int64(validity)
+	return p, nil
+}
+
+// Decode parses the text read from a QR code
+func Decode(code string) (*Payload, error) {
+	b, err := DecodeBase45(code)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePayload(b)
+}
+
+// reader reads the fields of a payload, remembering the first error
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.b) {
+		r.err = errTruncatedPayload
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errTruncatedPayload
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) string() string {
+	n := r.uvarint()
+	if r.err == nil && n > maxIDLen {
+		r.err = fmt.Errorf("%w: %d bytes", errIDTooLong, n)
+	}
+	return string(r.bytes(int(n)))
+}
@@ -0,0 +1,173 @@
+package qr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"ticketsystem/main/vms/ticketvm"
+)
+
+// RotatingVersion is the first byte of a rotating code. It differs from
+// PayloadVersion so a scanner can tell the two kinds of code apart.
+const RotatingVersion = 2
+
+// RotationKeySize is the length of an event's rotation key
+const RotationKeySize = 32
+
+const (
+	// RotationStep is how often the holder's device shows a new code
+	RotationStep = 15 * time.Second
+	// RotationDrift is how many steps a code may be ahead of or behind the
+	// scanner's clock, so a code shown just before it rotates still scans
+	RotationDrift = 1
+)
+
+// rotatingTagLen is the length of a code's truncated MAC. A guess has to be
+// made at a gate, in person, so 64 bits is plenty.
+const rotatingTagLen = 8
+
+// rotationDomain separates ticket secrets from anything else derived from an
+// event's rotation key
+const rotationDomain = "ticket-qr-rotation"
+
+var (
+	errStaleCode       = errors.New("rotating code is outside the drift window")
+	errBadRotatingCode = errors.New("rotating code wasn't derived from the ticket's current secret")
+	errWrongEvent      = errors.New("code is for another event")
+	errNoHolder        = errors.New("ticket has no holder")
+)
+
+// Tickets looks up the current holder of tickets. It is implemented by the
+// chain state.
+type Tickets interface {
+	Ticket(ticketID string) (ticketvm.Ticket, error)
+}
+
+// NewRotationKey returns a random rotation key for an event. The organizer
+// keeps it and gives it to its scanners only.
+func NewRotationKey() ([]byte, error) {
+	key := make([]byte, RotationKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RotationSecret returns the secret [holder] derives rotating codes for
+// [ticketID] from. The organizer hands it to the holder when the ticket is
+// bought or transferred. It depends on the ticket's [rotation], which changes
+// with every holder, so a previous holder's secret is useless.
+func RotationSecret(eventKey []byte, ticketID, holder string, rotation uint64) []byte {
+	mac := hmac.New(sha256.New, eventKey)
+	_, _ = mac.Write([]byte(rotationDomain))
+	writeField(mac, []byte(ticketID))
+	writeField(mac, []byte(holder))
+	_, _ = mac.Write(binary.AppendUvarint(nil, rotation))
+	return mac.Sum(nil)
+}
+
+// RotatingCode is the content of a QR code that changes every RotationStep.
+// A screenshot of it stops working shortly after it is taken.
+type RotatingCode struct {
+	TicketID string
+	EventID  string
+	// Step is the Unix time divided by RotationStep the code was made at
+	Step uint64
+	Tag  [rotatingTagLen]byte
+}
+
+// NewRotatingCode returns the code for [ticketID] at [now], derived from the
+// holder's [secret]
+func NewRotatingCode(secret []byte, ticketID, eventID string, now time.Time) (*RotatingCode, error) {
+	switch {
+	case len(ticketID) > maxIDLen:
+		return nil, fmt.Errorf("%w: ticket %d bytes", errIDTooLong, len(ticketID))
+	case len(eventID) > maxIDLen:
+		return nil, fmt.Errorf("%w: event %d bytes", errIDTooLong, len(eventID))
+	}
+	c := &RotatingCode{
+		TicketID: ticketID,
+		EventID:  eventID,
+		Step:     stepAt(now),
+	}
+	c.Tag = rotatingTag(secret, ticketID, c.Step)
+	return c, nil
+}
+
+func stepAt(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(RotationStep/time.Second))
+}
+
+func rotatingTag(secret []byte, ticketID string, step uint64) [rotatingTagLen]byte {
+	mac := hmac.New(sha256.New, secret)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], step)
+	_, _ = mac.Write(b[:])
+	_, _ = mac.Write([]byte(ticketID))
+	var tag [rotatingTagLen]byte
+	copy(tag[:], mac.Sum(nil))
+	return tag
+}
+
+// Bytes returns the binary encoding of the code:
+//
+//	version | uvarint len | ticket ID | uvarint len | event ID | uvarint step
+//	| tag
+func (c *RotatingCode) Bytes() []byte {
+	b := make([]byte, 0, 1+3*binary.MaxVarintLen64+len(c.TicketID)+len(c.EventID)+rotatingTagLen)
+	b = append(b, RotatingVersion)
+	b = binary.AppendUvarint(b, uint64(len(c.TicketID)))
+	b = append(b, c.TicketID...)
+	b = binary.AppendUvarint(b, uint64(len(c.EventID)))
+	b = append(b, c.EventID...)
+	b = binary.AppendUvarint(b, c.Step)
+	return append(b, c.Tag[:]...)
+}
+
+// Encode returns the text put in the QR code, in base45
+func (c *RotatingCode) Encode() string {
+	return EncodeBase45(c.Bytes())
+}
+
+// WriteToQR returns a PNG of the QR code holding the code
+func (c *RotatingCode) WriteToQR(level qrcode.RecoveryLevel, size int) ([]byte, error) {
+	return GenerateQRCode(c.Encode(), level, size)
+}
+
+// ParseRotatingCode parses the binary encoding of a rotating code. The tag
+// isn't checked.
+func ParseRotatingCode(b []byte) (*RotatingCode, error) {
+	r := reader{b: b}
+	if version := r.byte(); r.err == nil && version != RotatingVersion {
+		return nil, fmt.Errorf("%w: %d", errUnsupportedVersion, version)
+	}
+	c := &RotatingCode{
+		TicketID: r.string(),
+		EventID:  r.string(),
+		Step:     r.uvarint(),
+	}
+	copy(c.Tag[:], r.bytes(rotatingTagLen))
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.b) != 0 {
+		return nil, fmt.Errorf("%w: %d", errTrailingBytes, len(r.b))
+	}
+	return c, nil
+}
+
+// DecodeRotating parses the text read from a rotating QR code
+func DecodeRotating(code string) (*RotatingCode, error) {
+	b, err := DecodeBase45(code)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRotatingCode(b)
+}
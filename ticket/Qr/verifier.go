@@ -0,0 +1,115 @@
+package qr
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxClockSkew is how far a scanner's clock may be from the one the
+// validity window was set with by default
+const DefaultMaxClockSkew = 5 * time.Minute
+
+var (
+	errBadSignature = errors.New("QR payload isn't signed by the event's organizer")
+	errNotYetValid  = errors.New("QR code isn't valid yet")
+	errExpired      = errors.New("QR code has expired")
+)
+
+// Verifier checks scanned codes without contacting the chain, against the
+// organizer keys in its cache. It doesn't know whether the ticket was
+// already used; that is for the gate to check.
+type Verifier struct {
+	keys         *KeyCache
+	maxClockSkew time.Duration
+	clock        func() time.Time
+}
+
+// NewVerifier returns a verifier checking codes against [keys]
+func NewVerifier(keys *KeyCache, maxClockSkew time.Duration) *Verifier {
+	return &Verifier{
+		keys:         keys,
+		maxClockSkew: maxClockSkew,
+		clock:        time.Now,
+	}
+}
+
+// Verify decodes the text read from a QR code and checks it
+func (v *Verifier) Verify(code string) (*Payload, error) {
+	p, err := Decode(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.VerifyPayload(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// VerifyPayload checks that [p] is signed by the organizer of its event and
+// that the current time is within its validity window
+func (v *Verifier) VerifyPayload(p *Payload) error {
+	key, err := v.keys.EventKey(p.EventID)
+	if err != nil {
+		return err
+	}
+	if !p.VerifySignature(key) {
+		return fmt.Errorf("%w: ticket %s", errBadSignature, p.TicketID)
+	}
+
+	now := v.clock()
+	switch {
+	case now.Add(v.maxClockSkew).Before(time.Unix(p.NotBefore, 0)):
+		return fmt.Errorf("%w: ticket %s until %s", errNotYetValid, p.TicketID, time.Unix(p.NotBefore, 0).UTC())
+	case now.Add(-v.maxClockSkew).After(time.Unix(p.NotAfter, 0)):
+		return fmt.Errorf("%w: ticket %s at %s", errExpired, p.TicketID, time.Unix(p.NotAfter, 0).UTC())
+	}
+	return nil
+}
+
+// VerifyRotating decodes the text read from a rotating QR code and checks it
+// against the ticket's current holder in [tickets]. Codes derived from a
+// previous holder's secret are rejected.
+func (v *Verifier) VerifyRotating(code string, tickets Tickets) (*RotatingCode, error) {
+	c, err := DecodeRotating(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.VerifyRotatingCode(c, tickets); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// VerifyRotatingCode checks that [c] is within RotationDrift steps of the
+// current time and was derived from the current holder's secret
+func (v *Verifier) VerifyRotatingCode(c *RotatingCode, tickets Tickets) error {
+	ticket, err := tickets.Ticket(c.TicketID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ticket.EventID != c.EventID:
+		return fmt.Errorf("%w: ticket %s is for %s", errWrongEvent, c.TicketID, ticket.EventID)
+	case ticket.Holder == "":
+		return fmt.Errorf("%w: %s", errNoHolder, c.TicketID)
+	}
+
+	// Unlike signed payloads, the window here is a few seconds wide, so
+	// the scanner's clock has to be roughly right
+	now := stepAt(v.clock())
+	if c.Step+RotationDrift < now || c.Step > now+RotationDrift {
+		return fmt.Errorf("%w: ticket %s", errStaleCode, c.TicketID)
+	}
+
+	key, err := v.keys.RotationKey(c.EventID)
+	if err != nil {
+		return err
+	}
+	secret := RotationSecret(key, c.TicketID, ticket.Holder, ticket.Rotation)
+	if tag := rotatingTag(secret, c.TicketID, c.Step); !hmac.Equal(tag[:], c.Tag[:]) {
+		return fmt.Errorf("%w: ticket %s", errBadRotatingCode, c.TicketID)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package gate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ticketsystem/main/vms/ticketvm"
+
+	nfc "ticketsystem/main/ticket/NFC"
+)
+
+const (
+	// ChallengeTTL is how long a tag has to answer a challenge
+	ChallengeTTL = 30 * time.Second
+	// maxChallenges bounds the challenges awaiting an answer
+	maxChallenges = 4096
+)
+
+var (
+	errTooManyChallenges = errors.New("too many challenges awaiting an answer")
+	errTagRequired       = errors.New("ticket is bound to a tag and must be read from it")
+	errUnknownChallenge  = errors.New("challenge wasn't issued by this gate or has expired")
+	errBadTagResponse    = errors.New("tag's response doesn't match the ticket's tag key")
+)
+
+// TagResponse is a tag's answer to a gate's challenge
+type TagResponse struct {
+	Challenge []byte `json:"challenge"`
+	Response  []byte `json:"response"`
+}
+
+// challenges are the challenges a gate issued, each answered at most once.
+// The caller holds the gate's lock.
+type challenges map[string]time.Time
+
+// issue returns a new challenge, valid until [now] plus ChallengeTTL
+func (c challenges) issue(now time.Time) ([]byte, error) {
+	for challenge, expiry := range c {
+		if now.After(expiry) {
+			delete(c, challenge)
+		}
+	}
+	if len(c) >= maxChallenges {
+		return nil, errTooManyChallenges
+	}
+	challenge, err := nfc.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	c[string(challenge)] = now.Add(ChallengeTTL)
+	return challenge, nil
+}
+
+// consume returns true if [challenge] was issued and hasn't expired, and
+// forgets it so it can't be answered twice
+func (c challenges) consume(challenge []byte, now time.Time) bool {
+	expiry, ok := c[string(challenge)]
+	delete(c, string(challenge))
+	return ok && !now.After(expiry)
+}
+
+// authenticate returns nil if [ticket] isn't bound to a tag or [response]
+// is its tag's answer to one of [c]. A copy of a bound ticket's payload,
+// shown as a QR code or written to another tag, can't answer.
+func authenticate(ticket ticketvm.Ticket, response *TagResponse, c challenges, now time.Time) error {
+	if response != nil && !c.consume(response.Challenge, now) {
+		return errUnknownChallenge
+	}
+	switch {
+	case len(ticket.TagKey) == 0:
+		return nil
+	case response == nil:
+		return fmt.Errorf("%w: %s", errTagRequired, ticket.ID)
+	case !nfc.VerifyResponse(ticket.TagKey, ticket.ID, response.Challenge, response.Response):
+		return fmt.Errorf("%w: %s", errBadTagResponse, ticket.ID)
+	default:
+		return nil
+	}
+}
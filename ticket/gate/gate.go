@@ -0,0 +1,413 @@
+// Package gate admits ticket holders at an event's gates. It checks scanned
+// codes, keeps a ticket from entering twice and records every admission on
+// the chain. Gates that lose their connection keep admitting tickets from a
+// snapshot and submit what they recorded once they are back online.
+package gate
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils/logging"
+	"ticketsystem/main/vms/ticketvm"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+// RetryInterval is how often journaled scans waiting on a previous scan of
+// the same ticket are retried
+const RetryInterval = time.Second
+
+var (
+	errEmptyCode       = errors.New("empty code")
+	errUnknownCode     = errors.New("unknown kind of code")
+	errMissingGate     = errors.New("missing gate")
+	errOtherOrganizer  = errors.New("event isn't run by this gate's organizer")
+	errWrongEvent      = errors.New("code is for another event")
+	errNoHolder        = errors.New("ticket has no holder")
+	errPreviousHolder  = errors.New("code was issued to a previous holder of the ticket")
+	errDuplicate       = errors.New("ticket was already checked in")
+	errNoReEntry       = errors.New("event doesn't allow re-entry")
+	errNotInside       = errors.New("ticket isn't checked in")
+	errScanPending     = errors.New("ticket's previous scan isn't confirmed yet")
+	errTicketNotActive = errors.New("ticket can't be admitted")
+)
+
+// Chain is where check-ins are issued. It is implemented by the ticket VM in
+// linear mode and the ticket DAG in DAG mode.
+type Chain interface {
+	IssueTx(tx *ticketvm.Tx) error
+	// Pending returns true if [txID] was issued but not yet accepted
+	Pending(txID ids.ID) bool
+}
+
+// Config of a Gate
+type Config struct {
+	// Key is the organizer's key check-ins are signed with. The gate only
+	// admits tickets to the organizer's events.
+	Key ed25519.PrivateKey
+	// Fee paid for each check-in and check-out, in minor units of the
+	// chain's fee currency
+	Fee int64
+	// Keys holds the events' rotation keys, for rotating codes
+	Keys  *qr.KeyCache
+	State *ticketvm.State
+	Chain Chain
+	Log   logging.Logger
+}
+
+// Result of a scan
+type Result struct {
+	// Admitted is true if the ticket was let in or, at an exit, scanned out
+	Admitted bool   `json:"admitted"`
+	TicketID string `json:"ticketID,omitempty"`
+	EventID  string `json:"eventID,omitempty"`
+	// Reason the ticket wasn't admitted
+	Reason string `json:"reason,omitempty"`
+	// FirstEntry is when and at which gate a ticket already checked in
+	// was first admitted
+	FirstEntry *ticketvm.Entry `json:"firstEntry,omitempty"`
+	// TxID is the check-in or check-out issued for an admitted ticket
+	TxID ids.ID `json:"txID"`
+}
+
+// Scan is a ticket's pass through a gate
+type Scan struct {
+	TicketID string `json:"ticketID"`
+	EventID  string `json:"eventID"`
+	ticketvm.Entry
+	// Exit is set if the ticket was scanned on its way out
+	Exit bool `json:"exit,omitempty"`
+}
+
+// pendingScan is a check-in or check-out issued but not yet accepted
+type pendingScan struct {
+	txID ids.ID
+	scan Scan
+}
+
+// Gate checks scanned codes and issues the check-ins of the tickets it admits
+type Gate struct {
+	config    Config
+	organizer string
+	verifier  *qr.Verifier
+	clock     func() time.Time
+
+	lock sync.Mutex
+	// pending scans, by ticket ID. A ticket scanned again before its last
+	// scan is accepted is checked against these rather than the chain, so
+	// two gates can't admit it in the time it takes to decide a block.
+	pending map[string]pendingScan
+	// backlog holds the journaled scans of each ticket waiting for the
+	// ticket's pending scan to be accepted, oldest first
+	backlog map[string][]Scan
+	// submitted are the journaled scans already received, so that a
+	// journal submitted twice doesn't conflict with itself
+	submitted map[Scan]struct{}
+	// conflicts are the journaled admissions the chain didn't allow
+	conflicts []Conflict
+	// challenges issued to tags, awaiting their answer
+	challenges challenges
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// New returns a gate admitting tickets to the events of [config.Key]'s
+// organizer
+func New(config Config) (*Gate, error) {
+	if config.Keys == nil {
+		config.Keys = qr.NewKeyCache()
+	}
+	organizer, err := config.Keys.AddOrganizer(config.Key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	return &Gate{
+		config:     config,
+		organizer:  organizer,
+		verifier:   qr.NewVerifier(config.Keys, qr.DefaultMaxClockSkew),
+		clock:      time.Now,
+		pending:    make(map[string]pendingScan),
+		backlog:    make(map[string][]Scan),
+		submitted:  make(map[Scan]struct{}),
+		challenges: make(challenges),
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// Organizer returns the address of the organizer the gate admits tickets for
+func (g *Gate) Organizer() string { return g.organizer }
+
+// Dispatch retries journaled scans until Close is called
+func (g *Gate) Dispatch() {
+	ticker := time.NewTicker(RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.closed:
+			return
+		case <-ticker.C:
+			g.lock.Lock()
+			g.retry()
+			g.lock.Unlock()
+		}
+	}
+}
+
+// Close stops the gate's retries
+func (g *Gate) Close() {
+	g.closeOnce.Do(func() { close(g.closed) })
+}
+
+// Scan checks the [code] read at [gate] and, if the ticket may pass, issues
+// its check-in, or its check-out if [exit] is set. A ticket that can't pass
+// is reported in the result; the error is only set if the ticket was allowed
+// but its check-in couldn't be issued.
+func (g *Gate) Scan(code, gate string, exit bool) (Result, error) {
+	return g.scan(code, gate, exit, nil)
+}
+
+// Challenge returns a challenge for the tag of the next ticket scanned with
+// ScanTag
+func (g *Gate) Challenge() ([]byte, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.challenges.issue(g.clock())
+}
+
+// ScanTag scans the [code] read from an NFC tag along with the tag's
+// [response] to a challenge from Challenge. Tickets bound to a tag are only
+// admitted this way.
+func (g *Gate) ScanTag(code, gate string, exit bool, response TagResponse) (Result, error) {
+	return g.scan(code, gate, exit, &response)
+}
+
+func (g *Gate) scan(code, gate string, exit bool, response *TagResponse) (Result, error) {
+	if gate == "" {
+		return Result{Reason: errMissingGate.Error()}, nil
+	}
+	ticket, err := verifyCode(code, g.verifier, g.config.State, g.addEvent)
+	if err != nil {
+		g.config.Log.Debug("rejected code at gate %s: %s", gate, err)
+		return Result{Reason: err.Error()}, nil
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if err := authenticate(ticket, response, g.challenges, g.clock()); err != nil {
+		g.config.Log.Info("rejected ticket %s at gate %s: %s", ticket.ID, gate, err)
+		return Result{TicketID: ticket.ID, EventID: ticket.EventID, Reason: err.Error()}, nil
+	}
+	g.prune()
+	return g.pass(Scan{
+		TicketID: ticket.ID,
+		EventID:  ticket.EventID,
+		Entry: ticketvm.Entry{
+			Gate: gate,
+			Time: g.clock().Unix(),
+		},
+		Exit: exit,
+	})
+}
+
+// ScanImage decodes the QR code in the PNG or JPEG read from [r], such as a
+// photo uploaded by a scanner without a decoder of its own, and scans it
+func (g *Gate) ScanImage(r io.Reader, gate string, exit bool) (Result, error) {
+	code, err := qr.DecodeImage(r)
+	if err != nil {
+		return Result{Reason: err.Error()}, nil
+	}
+	return g.Scan(code, gate, exit)
+}
+
+// pass checks [scan] against the chain and the pending scans and, if the
+// ticket may pass, issues its check-in or check-out. The caller holds the
+// lock.
+func (g *Gate) pass(scan Scan) (Result, error) {
+	result := Result{
+		TicketID: scan.TicketID,
+		EventID:  scan.EventID,
+	}
+	if err := g.check(scan, &result); err != nil {
+		g.config.Log.Info("rejected ticket %s at gate %s: %s", scan.TicketID, scan.Gate, err)
+		result.Reason = err.Error()
+		return result, nil
+	}
+
+	var t ticketvm.Transition = &ticketvm.CheckIn{Organizer: g.organizer, TicketID: scan.TicketID, Entry: scan.Entry}
+	if scan.Exit {
+		t = &ticketvm.CheckOut{Organizer: g.organizer, TicketID: scan.TicketID, Entry: scan.Entry}
+	}
+	tx, err := ticketvm.NewTx(t, g.config.Fee, g.clock().Add(ticketvm.DefaultTxLifetime).Unix(), g.config.Key)
+	if err != nil {
+		return result, err
+	}
+	if err := g.config.Chain.IssueTx(tx); err != nil {
+		return result, fmt.Errorf("couldn't issue the scan of %s: %w", scan.TicketID, err)
+	}
+	g.pending[scan.TicketID] = pendingScan{
+		txID: tx.ID(),
+		scan: scan,
+	}
+	g.config.Log.Verbo("ticket %s passed gate %s in tx %s", scan.TicketID, scan.Gate, tx.ID())
+
+	result.Admitted = true
+	result.TxID = tx.ID()
+	return result, nil
+}
+
+// addEvent caches the organizer of [eventID], read from the chain, if it is
+// the gate's organizer
+func (g *Gate) addEvent(eventID string) error {
+	organizer, err := g.config.State.Organizer(eventID)
+	if err != nil {
+		return err
+	}
+	if organizer != g.organizer {
+		return fmt.Errorf("%w: %s", errOtherOrganizer, eventID)
+	}
+	g.config.Keys.AddEvent(eventID, organizer)
+	return nil
+}
+
+// check returns nil if the ticket of [scan] may pass, taking the gate's
+// pending scans into account. The caller holds the lock.
+func (g *Gate) check(scan Scan, result *Result) error {
+	ticket, err := g.config.State.Ticket(scan.TicketID)
+	if err != nil {
+		return err
+	}
+	if pending, ok := g.pending[scan.TicketID]; ok {
+		if scan.Exit || pending.scan.Exit {
+			return errScanPending
+		}
+		firstEntry := ticket.FirstEntry
+		if firstEntry == nil {
+			firstEntry = &pending.scan.Entry
+		}
+		result.FirstEntry = firstEntry
+		return duplicate(firstEntry)
+	}
+
+	event, err := g.config.State.Event(ticket.EventID)
+	if err != nil {
+		return err
+	}
+	return checkPass(ticket, event.ReEntry, scan.Exit, result)
+}
+
+// prune forgets the pending scans that were accepted or dropped. The caller
+// holds the lock.
+func (g *Gate) prune() {
+	for ticketID, pending := range g.pending {
+		if g.config.State.HasTx(pending.txID) || !g.config.Chain.Pending(pending.txID) {
+			delete(g.pending, ticketID)
+		}
+	}
+}
+
+// verifyCode checks the signature or rotating tag of [code] and returns the
+// ticket it is for, as found in [tickets]. [checkEvent] is called with the
+// code's event before the code is verified.
+func verifyCode(code string, verifier *qr.Verifier, tickets qr.Tickets, checkEvent func(eventID string) error) (ticketvm.Ticket, error) {
+	b, err := qr.DecodeBase45(code)
+	if err != nil {
+		return ticketvm.Ticket{}, err
+	}
+	if len(b) == 0 {
+		return ticketvm.Ticket{}, errEmptyCode
+	}
+
+	var (
+		ticketID, eventID string
+		// rotation is the ticket's rotation a static payload was issued
+		// at. Rotating codes are checked against the current holder by
+		// the verifier.
+		rotation *uint64
+	)
+	switch b[0] {
+	case qr.PayloadVersion:
+		p, err := qr.ParsePayload(b)
+		if err != nil {
+			return ticketvm.Ticket{}, err
+		}
+		if err := checkEvent(p.EventID); err != nil {
+			return ticketvm.Ticket{}, err
+		}
+		if err := verifier.VerifyPayload(p); err != nil {
+			return ticketvm.Ticket{}, err
+		}
+		ticketID, eventID, rotation = p.TicketID, p.EventID, &p.Rotation
+	case qr.RotatingVersion:
+		c, err := qr.ParseRotatingCode(b)
+		if err != nil {
+			return ticketvm.Ticket{}, err
+		}
+		if err := checkEvent(c.EventID); err != nil {
+			return ticketvm.Ticket{}, err
+		}
+		if err := verifier.VerifyRotatingCode(c, tickets); err != nil {
+			return ticketvm.Ticket{}, err
+		}
+		ticketID, eventID = c.TicketID, c.EventID
+	default:
+		return ticketvm.Ticket{}, fmt.Errorf("%w: version %d", errUnknownCode, b[0])
+	}
+
+	ticket, err := tickets.Ticket(ticketID)
+	switch {
+	case err != nil:
+		return ticketvm.Ticket{}, err
+	case ticket.EventID != eventID:
+		return ticketvm.Ticket{}, fmt.Errorf("%w: ticket %s is for %s", errWrongEvent, ticketID, ticket.EventID)
+	case ticket.Holder == "":
+		return ticketvm.Ticket{}, fmt.Errorf("%w: %s", errNoHolder, ticketID)
+	case rotation != nil && *rotation != ticket.Rotation:
+		return ticketvm.Ticket{}, fmt.Errorf("%w: %s", errPreviousHolder, ticketID)
+	}
+	return ticket, nil
+}
+
+// checkPass returns nil if [ticket] may enter, or leave if [exit] is set,
+// an event with entry policy [policy]. The first entry of a ticket that is
+// already in is set on [result].
+func checkPass(ticket ticketvm.Ticket, policy ticketvm.EntryPolicy, exit bool, result *Result) error {
+	if exit {
+		switch {
+		case policy != ticketvm.ReEntry:
+			return fmt.Errorf("%w: %s", errNoReEntry, ticket.EventID)
+		case ticket.Status != ticketvm.CheckedIn:
+			return fmt.Errorf("%w: %s is %s", errNotInside, ticket.ID, ticket.Status)
+		default:
+			return nil
+		}
+	}
+
+	switch ticket.Status {
+	case ticketvm.Held:
+		return nil
+	case ticketvm.CheckedIn:
+		result.FirstEntry = ticket.FirstEntry
+		return duplicate(ticket.FirstEntry)
+	case ticketvm.Exited:
+		if policy != ticketvm.ReEntry {
+			return fmt.Errorf("%w: %s", errNoReEntry, ticket.EventID)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %s is %s", errTicketNotActive, ticket.ID, ticket.Status)
+	}
+}
+
+func duplicate(firstEntry *ticketvm.Entry) error {
+	return fmt.Errorf("%w: at gate %s at %s", errDuplicate, firstEntry.Gate, time.Unix(firstEntry.Time, 0).UTC())
+}
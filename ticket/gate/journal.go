@@ -0,0 +1,94 @@
+package gate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Journal is an append only file of the scans an offline gate let through,
+// one JSON object per line. Each scan is synced to disk before the ticket is
+// let in, so a gate that crashes doesn't forget who it admitted.
+type Journal struct {
+	lock  sync.Mutex
+	file  *os.File
+	scans []Scan
+}
+
+// OpenJournal opens the journal at [path], creating it if it doesn't exist,
+// and reads the scans already in it. A last line cut short by a crash is
+// dropped.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{file: file}
+
+	var (
+		reader = bufio.NewReader(file)
+		offset int64
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A line without its newline wasn't fully written
+			break
+		}
+		var scan Scan
+		if err := json.Unmarshal(bytes.TrimSpace(line), &scan); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("corrupt journal %s at byte %d: %w", path, offset, err)
+		}
+		j.scans = append(j.scans, scan)
+		offset += int64(len(line))
+	}
+	if err := file.Truncate(offset); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, 0); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Record appends [scan] to the journal and syncs it to disk
+func (j *Journal) Record(scan Scan) error {
+	b, err := json.Marshal(scan)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if _, err := j.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.scans = append(j.scans, scan)
+	return nil
+}
+
+// Scans returns the scans in the journal, oldest first
+func (j *Journal) Scans() []Scan {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return append([]Scan(nil), j.scans...)
+}
+
+// Close the journal's file
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.file.Close()
+}
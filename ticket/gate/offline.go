@@ -0,0 +1,109 @@
+package gate
+
+import (
+	"sync"
+	"time"
+
+	"ticketsystem/main/vms/ticketvm"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+// Offline admits tickets without reaching the chain, from a snapshot taken
+// while the gate was online. Every ticket it lets through is recorded in its
+// journal, which is submitted to a gate node once the network is back.
+//
+// Gates sharing a snapshot don't see each other's scans, so a ticket may be
+// admitted at two of them. This is found when their journals are submitted
+// and reported to the organizer as a conflict.
+type Offline struct {
+	lock       sync.Mutex
+	snapshot   *Snapshot
+	journal    *Journal
+	verifier   *qr.Verifier
+	challenges challenges
+	clock      func() time.Time
+}
+
+// NewOffline returns a gate admitting tickets from [snapshot]. The scans
+// already in [journal] are applied to the snapshot, so a restarted gate
+// still knows who it let in.
+func NewOffline(snapshot *Snapshot, journal *Journal) *Offline {
+	for _, scan := range journal.Scans() {
+		snapshot.apply(scan)
+	}
+	return &Offline{
+		snapshot:   snapshot,
+		journal:    journal,
+		verifier:   qr.NewVerifier(snapshot.Keys, qr.DefaultMaxClockSkew),
+		challenges: make(challenges),
+		clock:      time.Now,
+	}
+}
+
+// Scan checks the [code] read at [gate] and, if the ticket may pass, records
+// it in the journal. A ticket that can't pass is reported in the result; the
+// error is only set if the scan couldn't be recorded, in which case the
+// ticket mustn't be let in.
+func (o *Offline) Scan(code, gate string, exit bool) (Result, error) {
+	return o.scan(code, gate, exit, nil)
+}
+
+// Challenge returns a challenge for the tag of the next ticket scanned with
+// ScanTag
+func (o *Offline) Challenge() ([]byte, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.challenges.issue(o.clock())
+}
+
+// ScanTag scans the [code] read from an NFC tag along with the tag's
+// [response] to a challenge from Challenge. The tag keys are in the
+// snapshot, so bound tickets are authenticated offline too.
+func (o *Offline) ScanTag(code, gate string, exit bool, response TagResponse) (Result, error) {
+	return o.scan(code, gate, exit, &response)
+}
+
+func (o *Offline) scan(code, gate string, exit bool, response *TagResponse) (Result, error) {
+	if gate == "" {
+		return Result{Reason: errMissingGate.Error()}, nil
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	ticket, err := verifyCode(code, o.verifier, o.snapshot, o.snapshot.checkEvent)
+	if err != nil {
+		return Result{Reason: err.Error()}, nil
+	}
+	result := Result{
+		TicketID: ticket.ID,
+		EventID:  ticket.EventID,
+	}
+	if err := authenticate(ticket, response, o.challenges, o.clock()); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+	event := o.snapshot.Events[ticket.EventID]
+	if err := checkPass(ticket, event.ReEntry, exit, &result); err != nil {
+		result.Reason = err.Error()
+		return result, nil
+	}
+
+	scan := Scan{
+		TicketID: ticket.ID,
+		EventID:  ticket.EventID,
+		Entry: ticketvm.Entry{
+			Gate: gate,
+			Time: o.clock().Unix(),
+		},
+		Exit: exit,
+	}
+	if err := o.journal.Record(scan); err != nil {
+		return result, err
+	}
+	o.snapshot.apply(scan)
+	result.Admitted = true
+	return result, nil
+}
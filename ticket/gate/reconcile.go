@@ -0,0 +1,118 @@
+package gate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"ticketsystem/main/vms/ticketvm"
+)
+
+// Conflict is a scan an offline gate let through that the chain doesn't
+// allow, usually because another gate admitted the same ticket first
+type Conflict struct {
+	Scan
+	Reason string `json:"reason"`
+	// FirstEntry is the admission recorded on the chain, if the ticket was
+	// already in
+	FirstEntry *ticketvm.Entry `json:"firstEntry,omitempty"`
+}
+
+// SubmitJournal issues the check-ins and check-outs an offline gate
+// recorded, oldest first, and returns the conflicts found among them. A scan
+// of a ticket whose previous scan isn't accepted yet waits for it, so its
+// conflicts are only reported once it is retried. Scans that were already
+// submitted are ignored.
+func (g *Gate) SubmitJournal(scans []Scan) []Conflict {
+	scans = append([]Scan(nil), scans...)
+	sort.SliceStable(scans, func(i, j int) bool { return scans[i].Time < scans[j].Time })
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	numConflicts := len(g.conflicts)
+	for _, scan := range scans {
+		if _, ok := g.submitted[scan]; ok {
+			continue
+		}
+		g.submitted[scan] = struct{}{}
+
+		if scan.Gate == "" {
+			g.addConflict(Conflict{Scan: scan, Reason: errMissingGate.Error()})
+			continue
+		}
+		ticket, err := g.config.State.Ticket(scan.TicketID)
+		if err == nil && !scan.Exit && ticket.FirstEntry != nil && *ticket.FirstEntry == scan.Entry {
+			// Submitted before the node restarted
+			continue
+		}
+		if err == nil && ticket.EventID != scan.EventID {
+			err = fmt.Errorf("%w: ticket %s is for %s", errWrongEvent, scan.TicketID, ticket.EventID)
+		}
+		if err == nil {
+			err = g.addEvent(scan.EventID)
+		}
+		if err != nil {
+			g.addConflict(Conflict{Scan: scan, Reason: err.Error()})
+			continue
+		}
+		g.backlog[scan.TicketID] = append(g.backlog[scan.TicketID], scan)
+	}
+	g.retry()
+	return append([]Conflict(nil), g.conflicts[numConflicts:]...)
+}
+
+// Conflicts returns the conflicts found in the journals submitted for
+// [eventID]
+func (g *Gate) Conflicts(eventID string) []Conflict {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	var conflicts []Conflict
+	for _, conflict := range g.conflicts {
+		if conflict.EventID == eventID {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// retry issues the oldest journaled scan of every ticket without a pending
+// scan. A scan whose check-in can't be issued is retried later. The caller
+// holds the lock.
+func (g *Gate) retry() {
+	g.prune()
+	for ticketID, scans := range g.backlog {
+		for len(scans) > 0 {
+			if _, ok := g.pending[ticketID]; ok {
+				break
+			}
+			result, err := g.pass(scans[0])
+			if err != nil {
+				g.config.Log.Debug("couldn't submit journaled scan of %s: %s", ticketID, err)
+				break
+			}
+			if !result.Admitted {
+				g.addConflict(Conflict{
+					Scan:       scans[0],
+					Reason:     result.Reason,
+					FirstEntry: result.FirstEntry,
+				})
+			}
+			scans = scans[1:]
+		}
+		if len(scans) == 0 {
+			delete(g.backlog, ticketID)
+		} else {
+			g.backlog[ticketID] = scans
+		}
+	}
+}
+
+// addConflict records [conflict] for the organizer. The caller holds the
+// lock.
+func (g *Gate) addConflict(conflict Conflict) {
+	g.config.Log.Warn("ticket %s was let through gate %s at %s while offline: %s",
+		conflict.TicketID, conflict.Gate, time.Unix(conflict.Time, 0).UTC(), conflict.Reason)
+	g.conflicts = append(g.conflicts, conflict)
+}
@@ -0,0 +1,34 @@
+package gate
+
+import (
+	"errors"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+var errNotHolder = errors.New("caller isn't the ticket's holder")
+
+// RotationSecret returns the secret [holder] derives the rotating codes of
+// [ticketID] from, and the ticket's rotation it is valid for. The holder
+// fetches it once their purchase or transfer is accepted; the secret changes
+// whenever the ticket changes holder.
+func (g *Gate) RotationSecret(ticketID, holder string) ([]byte, uint64, error) {
+	ticket, err := g.config.State.Ticket(ticketID)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case ticket.Holder == "":
+		return nil, 0, errNoHolder
+	case ticket.Holder != holder:
+		return nil, 0, errNotHolder
+	}
+	if err := g.addEvent(ticket.EventID); err != nil {
+		return nil, 0, err
+	}
+	key, err := g.config.Keys.RotationKey(ticket.EventID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return qr.RotationSecret(key, ticket.ID, ticket.Holder, ticket.Rotation), ticket.Rotation, nil
+}
@@ -0,0 +1,180 @@
+package gate
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+
+	"ticketsystem/main/api/auth"
+	"ticketsystem/main/snow/engine/common"
+)
+
+// Service is the API handheld scanners call, served as the "gate" JSON-RPC
+// service
+type Service struct {
+	gate *Gate
+}
+
+// ScanArgs are the arguments to Scan
+type ScanArgs struct {
+	// Code is the text read from the ticket's QR code
+	Code string `json:"code"`
+	// Gate names where the ticket was scanned. It defaults to the ID of the
+	// scanner calling the API.
+	Gate string `json:"gate"`
+	// Exit is set when the ticket is scanned on the way out
+	Exit bool `json:"exit"`
+}
+
+// Scan checks a scanned code and checks the ticket in, or out at an exit
+func (s *Service) Scan(r *http.Request, args *ScanArgs, reply *Result) error {
+	result, err := s.gate.Scan(args.Code, s.gateName(r, args.Gate), args.Exit)
+	if err != nil {
+		return err
+	}
+	*reply = result
+	return nil
+}
+
+// ScanImageArgs are the arguments to ScanImage
+type ScanImageArgs struct {
+	// Image is a PNG or JPEG of the ticket's QR code, base64 encoded
+	Image []byte `json:"image"`
+	Gate  string `json:"gate"`
+	Exit  bool   `json:"exit"`
+}
+
+// ScanImage reads the QR code in an uploaded image and checks the ticket in,
+// or out at an exit
+func (s *Service) ScanImage(r *http.Request, args *ScanImageArgs, reply *Result) error {
+	result, err := s.gate.ScanImage(bytes.NewReader(args.Image), s.gateName(r, args.Gate), args.Exit)
+	if err != nil {
+		return err
+	}
+	*reply = result
+	return nil
+}
+
+// ChallengeReply is the reply from Challenge
+type ChallengeReply struct {
+	Challenge []byte `json:"challenge"`
+}
+
+// Challenge returns a challenge for the NFC tag of the next ticket scanned
+// with ScanTag. It expires after 30 seconds.
+func (s *Service) Challenge(_ *http.Request, _ *struct{}, reply *ChallengeReply) error {
+	challenge, err := s.gate.Challenge()
+	if err != nil {
+		return err
+	}
+	reply.Challenge = challenge
+	return nil
+}
+
+// ScanTagArgs are the arguments to ScanTag
+type ScanTagArgs struct {
+	// Code is the ticket's payload read from the tag, in base45 like a QR
+	// code
+	Code string `json:"code"`
+	Gate string `json:"gate"`
+	Exit bool   `json:"exit"`
+	// Challenge from Challenge and the tag's response to it, base64 encoded
+	TagResponse
+}
+
+// ScanTag checks a ticket read from an NFC tag and the tag's answer to a
+// challenge, and checks the ticket in, or out at an exit
+func (s *Service) ScanTag(r *http.Request, args *ScanTagArgs, reply *Result) error {
+	result, err := s.gate.ScanTag(args.Code, s.gateName(r, args.Gate), args.Exit, args.TagResponse)
+	if err != nil {
+		return err
+	}
+	*reply = result
+	return nil
+}
+
+// gateName returns [gate], or the ID of the scanner calling the API if it
+// is empty
+func (s *Service) gateName(r *http.Request, gate string) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok && gate == "" {
+		return principal.ID
+	}
+	return gate
+}
+
+// GetRotationSecretArgs are the arguments to GetRotationSecret
+type GetRotationSecretArgs struct {
+	TicketID string `json:"ticketID"`
+}
+
+// GetRotationSecretReply is the reply from GetRotationSecret
+type GetRotationSecretReply struct {
+	Secret   []byte `json:"secret"`
+	Rotation uint64 `json:"rotation"`
+}
+
+// GetRotationSecret returns the secret the caller's app derives the ticket's
+// rotating codes from. The caller must be authenticated as the ticket's
+// current holder, with the holder's address as its ID.
+func (s *Service) GetRotationSecret(r *http.Request, args *GetRotationSecretArgs, reply *GetRotationSecretReply) error {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok || principal == nil {
+		return errNotHolder
+	}
+	secret, rotation, err := s.gate.RotationSecret(args.TicketID, principal.ID)
+	if err != nil {
+		return err
+	}
+	reply.Secret = secret
+	reply.Rotation = rotation
+	return nil
+}
+
+// GetSnapshot returns the snapshot offline gates admit tickets from
+func (s *Service) GetSnapshot(_ *http.Request, _ *struct{}, reply *Snapshot) error {
+	*reply = *s.gate.Snapshot()
+	return nil
+}
+
+// SubmitJournalArgs are the arguments to SubmitJournal
+type SubmitJournalArgs struct {
+	Scans []Scan `json:"scans"`
+}
+
+// ConflictsReply is the reply from SubmitJournal and GetConflicts
+type ConflictsReply struct {
+	Conflicts []Conflict `json:"conflicts"`
+}
+
+// SubmitJournal issues the scans recorded by an offline gate and returns
+// those the chain didn't allow
+func (s *Service) SubmitJournal(_ *http.Request, args *SubmitJournalArgs, reply *ConflictsReply) error {
+	reply.Conflicts = s.gate.SubmitJournal(args.Scans)
+	return nil
+}
+
+// GetConflictsArgs are the arguments to GetConflicts
+type GetConflictsArgs struct {
+	EventID string `json:"eventID"`
+}
+
+// GetConflicts returns the scans offline gates let through for an event
+// that the chain didn't allow
+func (s *Service) GetConflicts(_ *http.Request, args *GetConflictsArgs, reply *ConflictsReply) error {
+	reply.Conflicts = s.gate.Conflicts(args.EventID)
+	return nil
+}
+
+// CreateHandler returns the handler serving the gate's API
+func (g *Gate) CreateHandler() *common.HTTPHandler {
+	server := rpc.NewServer()
+	codec := json2.NewCodec()
+	server.RegisterCodec(codec, "application/json")
+	server.RegisterCodec(codec, "application/json;charset=UTF-8")
+	// Service only has methods of the expected form, so registering can't
+	// fail
+	_ = server.RegisterService(&Service{gate: g}, "gate")
+	return &common.HTTPHandler{LockOptions: common.NoLock, Handler: server}
+}
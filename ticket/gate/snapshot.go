@@ -0,0 +1,118 @@
+package gate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"ticketsystem/main/vms/ticketvm"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+var (
+	errNotInSnapshot = errors.New("ticket isn't in the snapshot")
+	errNoEvent       = errors.New("event isn't in the snapshot")
+)
+
+// Snapshot is what an offline gate admits tickets from: the organizer's
+// events and their tickets as of when it was taken, and the keys their codes
+// are checked with. It isn't safe for concurrent use.
+type Snapshot struct {
+	// Time the snapshot was taken, in Unix seconds
+	Time      int64                      `json:"time"`
+	Organizer string                     `json:"organizer"`
+	Events    map[string]ticketvm.Event  `json:"events"`
+	Tickets   map[string]ticketvm.Ticket `json:"tickets"`
+	// Keys hold the organizer's public key and the events' rotation keys.
+	// Rotation keys are secret, so a snapshot must only be given to the
+	// organizer's own gates.
+	Keys *qr.KeyCache `json:"keys"`
+}
+
+// Snapshot returns the state of the organizer's events. Tickets with a
+// pending scan are shown as if it was accepted.
+func (g *Gate) Snapshot() *Snapshot {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.prune()
+	s := &Snapshot{
+		Time:      g.clock().Unix(),
+		Organizer: g.organizer,
+		Events:    make(map[string]ticketvm.Event),
+		Tickets:   make(map[string]ticketvm.Ticket),
+		Keys:      g.config.Keys,
+	}
+	for _, event := range g.config.State.OrganizerEvents(g.organizer) {
+		s.Events[event.ID] = event
+		g.config.Keys.AddEvent(event.ID, g.organizer)
+		for _, ticket := range g.config.State.EventTickets(event.ID) {
+			s.Tickets[ticket.ID] = ticket
+		}
+	}
+	for _, pending := range g.pending {
+		s.apply(pending.scan)
+	}
+	return s
+}
+
+// LoadSnapshot reads a snapshot saved with Save
+func LoadSnapshot(path string) (*Snapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{Keys: qr.NewKeyCache()}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("couldn't parse snapshot %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the snapshot to [path]. It holds secret keys, so only its
+// owner may read it.
+func (s *Snapshot) Save(path string) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// Ticket implements the qr.Tickets interface
+func (s *Snapshot) Ticket(ticketID string) (ticketvm.Ticket, error) {
+	ticket, ok := s.Tickets[ticketID]
+	if !ok {
+		return ticketvm.Ticket{}, fmt.Errorf("%w: %s", errNotInSnapshot, ticketID)
+	}
+	return ticket, nil
+}
+
+// checkEvent returns nil if [eventID] is in the snapshot
+func (s *Snapshot) checkEvent(eventID string) error {
+	if _, ok := s.Events[eventID]; !ok {
+		return fmt.Errorf("%w: %s", errNoEvent, eventID)
+	}
+	return nil
+}
+
+// apply updates the ticket of [scan] as its check-in or check-out would
+func (s *Snapshot) apply(scan Scan) {
+	ticket, ok := s.Tickets[scan.TicketID]
+	if !ok {
+		return
+	}
+	if scan.Exit {
+		ticket.Status = ticketvm.Exited
+	} else {
+		ticket.Status = ticketvm.CheckedIn
+		if ticket.FirstEntry == nil {
+			entry := scan.Entry
+			ticket.FirstEntry = &entry
+		}
+		ticket.Entries++
+	}
+	s.Tickets[scan.TicketID] = ticket
+}
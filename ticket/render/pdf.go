@@ -0,0 +1,62 @@
+package render
+
+import (
+	"bytes"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+const (
+	// qrImageSize is the width, in pixels, of the QR code embedded in PDFs.
+	// It is printed at pdfQRSize millimetres, which is about 150 dpi.
+	qrImageSize = 512
+	pdfQRSize   = 90
+)
+
+// PDF returns a printable A4 page with the event's details and the ticket's
+// QR code
+func PDF(t *Ticket) ([]byte, error) {
+	png, err := qr.GenerateQRCode(t.Code, qrcode.Medium, qrImageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	// The core fonts are encoded in cp1252, which event names in most
+	// Latin scripts can be translated to
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(t.Event.Name, true)
+	pdf.SetSubject("Ticket "+t.Ticket.ID, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 24)
+	pdf.MultiCell(0, 10, tr(t.Event.Name), "", "L", false)
+	pdf.Ln(4)
+	for _, f := range t.fields() {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(30, 7, tr(f.label), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.CellFormat(0, 7, tr(f.value), "", 1, "L", false, 0, "")
+	}
+
+	options := gofpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("qr", options, bytes.NewReader(png))
+	pageWidth, _ := pdf.GetPageSize()
+	y := pdf.GetY() + 10
+	// The blank page around the code is its quiet zone, which
+	// GenerateQRCode leaves out
+	pdf.ImageOptions("qr", (pageWidth-pdfQRSize)/2, y, pdfQRSize, pdfQRSize, false, options, 0, "")
+	pdf.SetY(y + pdfQRSize + 4)
+	pdf.SetFont("Courier", "", 9)
+	pdf.CellFormat(0, 5, tr(t.Ticket.ID), "", 1, "C", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,185 @@
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+)
+
+var (
+	errMissingIcon   = errors.New("wallet passes need an icon.png image")
+	errMissingSigner = errors.New("wallet passes need a pass type certificate and key")
+	errReservedFile  = errors.New("image name is reserved for the pass bundle")
+)
+
+// PassConfig identifies the organizer's Apple Wallet pass type and signs its
+// passes
+type PassConfig struct {
+	// PassTypeID and TeamID are the pass type identifier and developer team
+	// the certificate was issued for
+	PassTypeID       string
+	TeamID           string
+	OrganizationName string
+	// Cert and Key are the pass type certificate and its private key
+	Cert *x509.Certificate
+	Key  crypto.PrivateKey
+	// WWDR is Apple's intermediate certificate that issued Cert. It is
+	// included in the signature so Wallet can build the chain.
+	WWDR *x509.Certificate
+	// Images are added to the bundle under their file names, e.g.
+	// "icon.png" and "logo.png". Wallet requires icon.png.
+	Images map[string][]byte
+}
+
+type passField struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+type passBarcode struct {
+	Format          string `json:"format"`
+	Message         string `json:"message"`
+	MessageEncoding string `json:"messageEncoding"`
+	AltText         string `json:"altText,omitempty"`
+}
+
+type passStructure struct {
+	PrimaryFields   []passField `json:"primaryFields"`
+	SecondaryFields []passField `json:"secondaryFields"`
+	AuxiliaryFields []passField `json:"auxiliaryFields"`
+}
+
+// pass is the content of pass.json
+type pass struct {
+	FormatVersion      int           `json:"formatVersion"`
+	PassTypeIdentifier string        `json:"passTypeIdentifier"`
+	SerialNumber       string        `json:"serialNumber"`
+	TeamIdentifier     string        `json:"teamIdentifier"`
+	OrganizationName   string        `json:"organizationName"`
+	Description        string        `json:"description"`
+	RelevantDate       string        `json:"relevantDate,omitempty"`
+	Barcodes           []passBarcode `json:"barcodes"`
+	EventTicket        passStructure `json:"eventTicket"`
+}
+
+// PKPass returns an Apple Wallet pass bundle: a zip of pass.json, the images,
+// a manifest of their SHA-1 hashes and a detached PKCS #7 signature of the
+// manifest by the pass type certificate
+func PKPass(t *Ticket, config PassConfig) ([]byte, error) {
+	if config.Cert == nil || config.Key == nil {
+		return nil, errMissingSigner
+	}
+	if _, ok := config.Images["icon.png"]; !ok {
+		return nil, errMissingIcon
+	}
+
+	fields := t.fields()
+	p := pass{
+		FormatVersion:      1,
+		PassTypeIdentifier: config.PassTypeID,
+		SerialNumber:       t.Ticket.ID,
+		TeamIdentifier:     config.TeamID,
+		OrganizationName:   config.OrganizationName,
+		Description:        "Ticket for " + t.Event.Name,
+		Barcodes: []passBarcode{{
+			Format:  "PKBarcodeFormatQR",
+			Message: t.Code,
+			// Base45 is ASCII, so the code is the same in any encoding
+			MessageEncoding: "iso-8859-1",
+			AltText:         t.Ticket.ID,
+		}},
+		EventTicket: passStructure{
+			PrimaryFields:   []passField{{Key: "event", Label: "Event", Value: t.Event.Name}},
+			SecondaryFields: []passField{passFieldOf(fields[0])},
+			AuxiliaryFields: []passField{passFieldOf(fields[1]), passFieldOf(fields[3])},
+		},
+	}
+	if t.Event.StartTime != 0 {
+		p.RelevantDate = t.startTime().Format(time.RFC3339)
+	}
+	passJSON, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{"pass.json": passJSON}
+	for name, b := range config.Images {
+		if name == "pass.json" || name == "manifest.json" || name == "signature" {
+			return nil, errReservedFile
+		}
+		files[name] = b
+	}
+	manifest := make(map[string]string, len(files))
+	for name, b := range files {
+		hash := sha1.Sum(b)
+		manifest[name] = hex.EncodeToString(hash[:])
+	}
+	files["manifest.json"], err = json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	files["signature"], err = signManifest(files["manifest.json"], config)
+	if err != nil {
+		return nil, err
+	}
+	return zipFiles(files)
+}
+
+func passFieldOf(f field) passField {
+	return passField{Key: f.key, Label: f.label, Value: f.value}
+}
+
+// signManifest returns the detached signature of [manifest]
+func signManifest(manifest []byte, config PassConfig) ([]byte, error) {
+	sd, err := pkcs7.NewSignedData(manifest)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if config.WWDR != nil {
+		err = sd.AddSignerChain(config.Cert, config.Key, []*x509.Certificate{config.WWDR}, pkcs7.SignerInfoConfig{})
+	} else {
+		err = sd.AddSigner(config.Cert, config.Key, pkcs7.SignerInfoConfig{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	sd.Detach()
+	return sd.Finish()
+}
+
+// zipFiles returns a zip of [files], in name order so the same files always
+// give the same bundle
+func zipFiles(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,52 @@
+// Package render turns tickets into the files holders receive: a printable
+// PDF, an SVG of the QR code and wallet passes, so the box office can email
+// tickets or offer them for download in the format each fan expects.
+package render
+
+import (
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"ticketsystem/main/vms/ticketvm"
+
+	qr "ticketsystem/main/ticket/Qr"
+)
+
+// dateLayout is how the event's start time is printed
+const dateLayout = "Mon 2 Jan 2006, 15:04 MST"
+
+// Ticket is what a rendered ticket shows
+type Ticket struct {
+	Ticket ticketvm.Ticket
+	Event  ticketvm.Event
+	// Code is the text of the ticket's QR code, such as a signed payload
+	// encoded with qr.Payload.Encode
+	Code string
+}
+
+// field is a labelled detail printed on tickets and passes
+type field struct {
+	key   string
+	label string
+	value string
+}
+
+// fields returns the details every format shows, in order
+func (t *Ticket) fields() []field {
+	return []field{
+		{key: "date", label: "Date", value: t.startTime().Format(dateLayout)},
+		{key: "ticket", label: "Ticket", value: t.Ticket.ID},
+		{key: "holder", label: "Holder", value: t.Ticket.Holder},
+		{key: "price", label: "Price", value: t.Ticket.Price.String()},
+	}
+}
+
+func (t *Ticket) startTime() time.Time {
+	return time.Unix(t.Event.StartTime, 0).UTC()
+}
+
+// SVG returns an SVG of the ticket's QR code
+func SVG(t *Ticket) ([]byte, error) {
+	return qr.GenerateSVG(t.Code, qrcode.Medium)
+}
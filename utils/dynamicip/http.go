@@ -0,0 +1,67 @@
+package dynamicip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxResponseSize = 1024
+	httpTimeout     = 10 * time.Second
+)
+
+var errBadResponse = errors.New("ip service returned an unexpected response")
+
+type httpResolver struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPResolver returns a resolver that GETs [url]. The service may answer
+// with the bare IP or with a JSON object holding it under "ip".
+func NewHTTPResolver(url string) Resolver {
+	return &httpResolver{
+		url:    url,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+func (r *httpResolver) Resolve(ctx context.Context) (net.IP, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", errBadResponse, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(string(body))
+	var reply struct {
+		IP string `json:"ip"`
+	}
+	if err := json.Unmarshal(body, &reply); err == nil && reply.IP != "" {
+		text = reply.IP
+	}
+	ip := net.ParseIP(text)
+	if ip == nil {
+		return nil, fmt.Errorf("%w: %q", errBadResponse, text)
+	}
+	return ip, nil
+}
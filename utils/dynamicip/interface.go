@@ -0,0 +1,82 @@
+package dynamicip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var errNoAddress = errors.New("no usable interface address")
+
+type interfaceResolver struct {
+	name string
+}
+
+// NewInterfaceResolver returns a resolver that picks an address of the
+// interface [name], or of any interface that is up if [name] is empty. Global
+// unicast addresses are preferred over private ones, IPv4 over IPv6, and
+// loopback is only used when nothing else is available.
+func NewInterfaceResolver(name string) Resolver {
+	return &interfaceResolver{name: name}
+}
+
+func (r *interfaceResolver) Resolve(context.Context) (net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		best     net.IP
+		bestRank = -1
+	)
+	for _, iface := range ifaces {
+		if r.name != "" && iface.Name != r.name {
+			continue
+		}
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if rank := rankIP(ipNet.IP); rank > bestRank {
+				best, bestRank = ipNet.IP, rank
+			}
+		}
+	}
+	if bestRank < 0 {
+		if r.name != "" {
+			return nil, fmt.Errorf("%w on %s", errNoAddress, r.name)
+		}
+		return nil, errNoAddress
+	}
+	return best, nil
+}
+
+// rankIP orders addresses by how likely peers are to reach them. Addresses
+// that can't be advertised rank below zero.
+func rankIP(ip net.IP) int {
+	rank := 0
+	switch {
+	case ip.IsLinkLocalUnicast(), ip.IsMulticast(), ip.IsUnspecified():
+		return -1
+	case ip.IsLoopback():
+		rank = 0
+	case ip.IsPrivate():
+		rank = 2
+	case ip.IsGlobalUnicast():
+		rank = 4
+	}
+	if ip.To4() != nil {
+		rank++
+	}
+	return rank
+}
@@ -0,0 +1,51 @@
+package dynamicip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Resolver kinds accepted by NewResolver
+const (
+	// Static always returns the configured IP
+	Static = "static"
+	// Interface picks an address of a local network interface
+	Interface = "interface"
+	// HTTP asks an HTTP service, such as the gateway's UPnP/NAT-PMP bridge or
+	// a local stand-in for one, which address it sees
+	HTTP = "http"
+)
+
+var (
+	errUnknownResolver = errors.New("unknown ip resolver")
+	errMissingArg      = errors.New("ip resolver requires an argument")
+)
+
+// Resolver returns the public IP of this node
+type Resolver interface {
+	Resolve(ctx context.Context) (net.IP, error)
+}
+
+// NewResolver returns the resolver of [kind]. [arg] is the IP for Static, an
+// optional interface name for Interface and the URL for HTTP.
+func NewResolver(kind, arg string) (Resolver, error) {
+	switch strings.ToLower(kind) {
+	case Static:
+		if arg == "" {
+			return nil, fmt.Errorf("%w: %s", errMissingArg, kind)
+		}
+		return NewStaticResolver(arg)
+	case Interface:
+		return NewInterfaceResolver(arg), nil
+	case HTTP:
+		if arg == "" {
+			return nil, fmt.Errorf("%w: %s", errMissingArg, kind)
+		}
+		return NewHTTPResolver(arg), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnknownResolver, kind)
+	}
+}
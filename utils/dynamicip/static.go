@@ -0,0 +1,27 @@
+package dynamicip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var errBadIP = errors.New("bad ip")
+
+type staticResolver struct {
+	ip net.IP
+}
+
+// NewStaticResolver returns a resolver that always returns [ip]
+func NewStaticResolver(ip string) (Resolver, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("%w: %q", errBadIP, ip)
+	}
+	return &staticResolver{ip: parsed}, nil
+}
+
+func (r *staticResolver) Resolve(context.Context) (net.IP, error) {
+	return r.ip, nil
+}
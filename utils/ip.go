@@ -5,14 +5,14 @@ import (
 	"fmt"
 	"net"
 	"strconv"
-	"strings"
 )
 
 var (
-	errBadIP = errors.New("bad ip format")
+	errBadIP       = errors.New("bad ip format")
+	errNoAddresses = errors.New("host resolved to no addresses")
 )
 
-// IPDesc ...
+// IPDesc is the IP and port a node can be reached at
 type IPDesc struct {
 	IP   net.IP
 	Port uint16
@@ -24,29 +24,105 @@ func (ipDesc IPDesc) Equal(otherIPDesc IPDesc) bool {
 		ipDesc.IP.Equal(otherIPDesc.IP)
 }
 
+// IsZero returns true if no IP is set
+func (ipDesc IPDesc) IsZero() bool {
+	return ipDesc.IP == nil
+}
+
 // PortString ...
 func (ipDesc IPDesc) PortString() string {
 	return fmt.Sprintf(":%d", ipDesc.Port)
 }
 
+// String returns the IP and port in the form accepted by ToIPDesc. IPv6
+// addresses are bracketed, e.g. [::1]:9651.
 func (ipDesc IPDesc) String() string {
-	return fmt.Sprintf("%s%s", ipDesc.IP, ipDesc.PortString())
+	return net.JoinHostPort(ipDesc.IP.String(), strconv.FormatUint(uint64(ipDesc.Port), 10))
+}
+
+// MarshalText encodes the IPDesc as its String form. An unset IPDesc is
+// encoded as the empty string.
+func (ipDesc IPDesc) MarshalText() ([]byte, error) {
+	if ipDesc.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(ipDesc.String()), nil
 }
 
-// ToIPDesc ...
-// TODO: this was kinda hacked together, it should be verified.
+// UnmarshalText parses the output of MarshalText. IPDescs are read from peer
+// messages, so hostnames are rejected rather than resolved.
+func (ipDesc *IPDesc) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*ipDesc = IPDesc{}
+		return nil
+	}
+	parsed, err := ParseIPDesc(string(text))
+	if err != nil {
+		return err
+	}
+	*ipDesc = parsed
+	return nil
+}
+
+// ConfigIPDesc is an IPDesc read from the node's configuration, where the host
+// may also be a hostname, which is resolved when it is parsed
+type ConfigIPDesc struct {
+	IPDesc
+}
+
+// UnmarshalText parses the output of MarshalText. Hostnames are resolved.
+func (ipDesc *ConfigIPDesc) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*ipDesc = ConfigIPDesc{}
+		return nil
+	}
+	parsed, err := ToIPDesc(string(text))
+	if err != nil {
+		return err
+	}
+	ipDesc.IPDesc = parsed
+	return nil
+}
+
+// ParseIPDesc parses "host:port" where the host is an IPv4 address or a
+// bracketed IPv6 address such as [::1]:9651
+func ParseIPDesc(str string) (IPDesc, error) {
+	return parseIPDesc(str, false)
+}
+
+// ToIPDesc parses "host:port". The host may be an IPv4 address, a bracketed
+// IPv6 address such as [::1]:9651, or a hostname, which is resolved
+// preferring IPv4 addresses. It must only be used for trusted input, such as
+// the node's configuration.
 func ToIPDesc(str string) (IPDesc, error) {
-	parts := strings.Split(str, ":")
-	if len(parts) != 2 {
-		return IPDesc{}, errBadIP
+	return parseIPDesc(str, true)
+}
+
+func parseIPDesc(str string, resolve bool) (IPDesc, error) {
+	host, portStr, err := net.SplitHostPort(str)
+	if err != nil {
+		return IPDesc{}, fmt.Errorf("%w: %s", errBadIP, err)
 	}
-	port, err := strconv.ParseUint(parts[1], 10 /*=base*/, 16 /*=size*/)
+	port, err := strconv.ParseUint(portStr, 10 /*=base*/, 16 /*=size*/)
 	if err != nil {
-		return IPDesc{}, err
+		return IPDesc{}, fmt.Errorf("%w: port %q", errBadIP, portStr)
 	}
-	ip := net.ParseIP(parts[0])
+	if host == "" {
+		return IPDesc{}, fmt.Errorf("%w: missing host in %q", errBadIP, str)
+	}
+
+	ip := net.ParseIP(host)
 	if ip == nil {
-		return IPDesc{}, errBadIP
+		if !resolve {
+			return IPDesc{}, fmt.Errorf("%w: %q is not an IP address", errBadIP, host)
+		}
+		ip, err = lookupIP(host)
+		if err != nil {
+			return IPDesc{}, err
+		}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
 	}
 	return IPDesc{
 		IP:   ip,
@@ -54,8 +130,19 @@ func ToIPDesc(str string) (IPDesc, error) {
 	}, nil
 }
 
-// MyIP ...
-func MyIP() net.IP {
-	// TODO: Change this to consult a json-returning external service
-	return net.ParseIP("127.0.0.1")
+// lookupIP resolves [host], returning its first IPv4 address if it has one
+func lookupIP(host string) (net.IP, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%w: %s", errNoAddresses, host)
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+	return ips[0], nil
 }
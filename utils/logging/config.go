@@ -0,0 +1,30 @@
+package logging
+
+// Config of the loggers made by a Factory
+type Config struct {
+	// Directory log files are written to. Empty disables log files.
+	Directory string `json:"directory"`
+
+	LogLevel      Level  `json:"logLevel"`
+	DisplayLevel  Level  `json:"displayLevel"`
+	FileFormat    Format `json:"fileFormat"`
+	DisplayFormat Format `json:"displayFormat"`
+
+	// MaxSize is the size in megabytes a log file may reach before it is
+	// rotated. Zero disables rotation.
+	MaxSize int `json:"maxSize"`
+	// MaxFiles is the number of rotated files kept for each logger
+	MaxFiles int `json:"maxFiles"`
+}
+
+// DefaultConfig returns the config used when none is provided
+func DefaultConfig() Config {
+	return Config{
+		LogLevel:      Info,
+		DisplayLevel:  Info,
+		FileFormat:    JSON,
+		DisplayFormat: Console,
+		MaxSize:       8,
+		MaxFiles:      7,
+	}
+}
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var errUnknownLogger = errors.New("unknown logger")
+
+// Factory makes the loggers of the node. Every logger gets its own file and
+// its own levels, which can be changed at runtime by name.
+type Factory interface {
+	// Make returns the logger of a subsystem, e.g. "db" or "network"
+	Make(name string) (Logger, error)
+
+	// MakeChain returns the logger of a subsystem of a chain, e.g. the http
+	// handlers of the ticket chain
+	MakeChain(chainID fmt.Stringer, subdir string) (Logger, error)
+
+	// SetLogLevel sets the file level of the logger named [name]
+	SetLogLevel(name string, level Level) error
+	// SetDisplayLevel sets the display level of the logger named [name]
+	SetDisplayLevel(name string, level Level) error
+	GetLogLevel(name string) (Level, error)
+	GetDisplayLevel(name string) (Level, error)
+
+	// GetLoggerNames returns the names of every logger made so far
+	GetLoggerNames() []string
+
+	// Close stops every logger
+	Close()
+}
+
+type factory struct {
+	config  Config
+	display io.Writer
+
+	lock    sync.RWMutex
+	loggers map[string]Logger
+}
+
+// NewFactory returns a factory whose loggers display to stdout
+func NewFactory(config Config) Factory {
+	return &factory{
+		config:  config,
+		display: os.Stdout,
+		loggers: make(map[string]Logger),
+	}
+}
+
+func (f *factory) Make(name string) (Logger, error) {
+	return f.make(name, name+".log")
+}
+
+func (f *factory) MakeChain(chainID fmt.Stringer, subdir string) (Logger, error) {
+	id := chainID.String()
+	return f.make(fmt.Sprintf("%s.%s", id, subdir), filepath.Join(id, subdir+".log"))
+}
+
+// make returns the logger named [name], creating it with its file at
+// [fileName] relative to the log directory if it doesn't exist yet
+func (f *factory) make(name, fileName string) (Logger, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if l, ok := f.loggers[name]; ok {
+		return l, nil
+	}
+
+	var file io.WriteCloser
+	if f.config.Directory != "" {
+		writer, err := newRotatingWriter(
+			filepath.Join(f.config.Directory, fileName),
+			int64(f.config.MaxSize)*1024*1024,
+			f.config.MaxFiles,
+		)
+		if err != nil {
+			return nil, err
+		}
+		file = writer
+	}
+
+	l := New(name, f.config, file, f.display)
+	f.loggers[name] = l
+	return l, nil
+}
+
+func (f *factory) get(name string) (Logger, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	l, ok := f.loggers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownLogger, name)
+	}
+	return l, nil
+}
+
+func (f *factory) SetLogLevel(name string, level Level) error {
+	l, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	l.SetLogLevel(level)
+	return nil
+}
+
+func (f *factory) SetDisplayLevel(name string, level Level) error {
+	l, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	l.SetDisplayLevel(level)
+	return nil
+}
+
+func (f *factory) GetLogLevel(name string) (Level, error) {
+	l, err := f.get(name)
+	if err != nil {
+		return Off, err
+	}
+	return l.GetLogLevel(), nil
+}
+
+func (f *factory) GetDisplayLevel(name string) (Level, error) {
+	l, err := f.get(name)
+	if err != nil {
+		return Off, err
+	}
+	return l.GetDisplayLevel(), nil
+}
+
+func (f *factory) GetLoggerNames() []string {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	names := make([]string, 0, len(f.loggers))
+	for name := range f.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *factory) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, l := range f.loggers {
+		l.Stop()
+	}
+	f.loggers = make(map[string]Logger)
+}
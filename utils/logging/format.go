@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is how log entries are encoded
+type Format int
+
+const (
+	// Console writes one human readable line per entry
+	Console Format = iota
+	// JSON writes one JSON object per line
+	JSON
+)
+
+// ToFormat parses a format name
+func ToFormat(str string) (Format, error) {
+	switch strings.ToLower(str) {
+	case "console", "plain", "":
+		return Console, nil
+	case "json":
+		return JSON, nil
+	default:
+		return Console, fmt.Errorf("unknown log format: %q", str)
+	}
+}
+
+func (f Format) String() string {
+	switch f {
+	case Console:
+		return "console"
+	case JSON:
+		return "json"
+	default:
+		return fmt.Sprintf("FORMAT(%d)", int(f))
+	}
+}
+
+// Field is a key-value pair attached to every entry of a logger
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+type entry struct {
+	time    time.Time
+	level   Level
+	name    string
+	message string
+	fields  []Field
+}
+
+func (e *entry) encode(format Format) []byte {
+	if format == JSON {
+		return e.encodeJSON()
+	}
+	return e.encodeConsole()
+}
+
+// encodeConsole writes "time [LEVEL] name: message key=value ..."
+func (e *entry) encodeConsole() []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString(e.time.Format("01-02|15:04:05.000"))
+	fmt.Fprintf(&buf, " %-5s ", e.level)
+	if e.name != "" {
+		buf.WriteString(e.name)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(strings.TrimRight(e.message, "\n"))
+	for _, field := range e.fields {
+		fmt.Fprintf(&buf, " %s=%v", field.Key, field.Value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func (e *entry) encodeJSON() []byte {
+	obj := make(map[string]interface{}, len(e.fields)+4)
+	for _, field := range e.fields {
+		value := field.Value
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		obj[field.Key] = value
+	}
+	obj["time"] = e.time.Format(time.RFC3339Nano)
+	obj["level"] = e.level.String()
+	obj["logger"] = e.name
+	obj["msg"] = strings.TrimRight(e.message, "\n")
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		// A field couldn't be marshalled, keep the message rather than
+		// dropping the entry
+		b, _ = json.Marshal(map[string]string{
+			"time":   e.time.Format(time.RFC3339Nano),
+			"level":  e.level.String(),
+			"logger": e.name,
+			"msg":    strings.TrimRight(e.message, "\n"),
+			"error":  err.Error(),
+		})
+	}
+	return append(b, '\n')
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Level of a log message. Messages are written when their level is at most
+// the level of the logger.
+type Level int
+
+const (
+	Off Level = iota
+	Fatal
+	Error
+	Warn
+	Info
+	Trace
+	Debug
+	Verbo
+)
+
+var levelNames = [...]string{
+	Off:   "OFF",
+	Fatal: "FATAL",
+	Error: "ERROR",
+	Warn:  "WARN",
+	Info:  "INFO",
+	Trace: "TRACE",
+	Debug: "DEBUG",
+	Verbo: "VERBO",
+}
+
+// ToLevel parses a level name, case insensitively
+func ToLevel(str string) (Level, error) {
+	upper := strings.ToUpper(str)
+	for level, name := range levelNames {
+		if name == upper {
+			return Level(level), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level: %q", str)
+}
+
+func (l Level) String() string {
+	if l < Off || int(l) >= len(levelNames) {
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// MarshalJSON marshals the level as its name
+func (l Level) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// UnmarshalJSON parses a level name
+func (l *Level) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	level, err := ToLevel(str)
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger writes levelled log messages. Messages are printf style format
+// strings.
+type Logger interface {
+	// Write logs [p] at the Info level so the logger can be handed to
+	// anything expecting an io.Writer, such as HTTP access loggers
+	io.Writer
+
+	Fatal(format string, args ...interface{})
+	Error(format string, args ...interface{})
+	Warn(format string, args ...interface{})
+	Info(format string, args ...interface{})
+	Trace(format string, args ...interface{})
+	Debug(format string, args ...interface{})
+	Verbo(format string, args ...interface{})
+
+	// With returns a logger that attaches [fields] to every message. The
+	// returned logger shares levels and outputs with this one.
+	With(fields ...Field) Logger
+
+	// SetLogLevel sets the level written to the log file
+	SetLogLevel(level Level)
+	GetLogLevel() Level
+	// SetDisplayLevel sets the level written to the display
+	SetDisplayLevel(level Level)
+	GetDisplayLevel() Level
+
+	// Stop flushes and closes the log file
+	Stop()
+}
+
+// output is shared by a logger and every logger derived from it with With
+type output struct {
+	logLevel     int32
+	displayLevel int32
+
+	fileFormat    Format
+	displayFormat Format
+
+	// lock serializes writes so entries from different goroutines don't
+	// interleave on the display
+	lock    sync.Mutex
+	file    io.WriteCloser
+	display io.Writer
+}
+
+type log struct {
+	name   string
+	fields []Field
+	out    *output
+	clock  func() time.Time
+}
+
+// New returns a logger named [name] that writes to [file] and [display].
+// Either may be nil.
+func New(name string, config Config, file io.WriteCloser, display io.Writer) Logger {
+	return &log{
+		name: name,
+		out: &output{
+			logLevel:      int32(config.LogLevel),
+			displayLevel:  int32(config.DisplayLevel),
+			fileFormat:    config.FileFormat,
+			displayFormat: config.DisplayFormat,
+			file:          file,
+			display:       display,
+		},
+		clock: time.Now,
+	}
+}
+
+func (l *log) Write(p []byte) (int, error) {
+	l.log(Info, "%s", p)
+	return len(p), nil
+}
+
+func (l *log) Fatal(format string, args ...interface{}) { l.log(Fatal, format, args...) }
+func (l *log) Error(format string, args ...interface{}) { l.log(Error, format, args...) }
+func (l *log) Warn(format string, args ...interface{})  { l.log(Warn, format, args...) }
+func (l *log) Info(format string, args ...interface{})  { l.log(Info, format, args...) }
+func (l *log) Trace(format string, args ...interface{}) { l.log(Trace, format, args...) }
+func (l *log) Debug(format string, args ...interface{}) { l.log(Debug, format, args...) }
+func (l *log) Verbo(format string, args ...interface{}) { l.log(Verbo, format, args...) }
+
+func (l *log) log(level Level, format string, args ...interface{}) {
+	// The file is closed and cleared under the lock, so it is only checked
+	// once the lock is held
+	toFile := level <= l.GetLogLevel()
+	toDisplay := l.out.display != nil && level <= l.GetDisplayLevel()
+	if !toFile && !toDisplay {
+		return
+	}
+
+	e := entry{
+		time:    l.clock(),
+		level:   level,
+		name:    l.name,
+		message: fmt.Sprintf(format, args...),
+		fields:  l.fields,
+	}
+
+	l.out.lock.Lock()
+	defer l.out.lock.Unlock()
+
+	// Errors writing logs have nowhere to be reported, so they are dropped
+	if toFile && l.out.file != nil {
+		_, _ = l.out.file.Write(e.encode(l.out.fileFormat))
+	}
+	if toDisplay {
+		_, _ = l.out.display.Write(e.encode(l.out.displayFormat))
+	}
+}
+
+func (l *log) With(fields ...Field) Logger {
+	combined := make([]Field, 0, len(l.fields)+len(fields))
+	combined = append(combined, l.fields...)
+	combined = append(combined, fields...)
+	return &log{
+		name:   l.name,
+		fields: combined,
+		out:    l.out,
+		clock:  l.clock,
+	}
+}
+
+func (l *log) SetLogLevel(level Level) {
+	atomic.StoreInt32(&l.out.logLevel, int32(level))
+}
+
+func (l *log) GetLogLevel() Level {
+	return Level(atomic.LoadInt32(&l.out.logLevel))
+}
+
+func (l *log) SetDisplayLevel(level Level) {
+	atomic.StoreInt32(&l.out.displayLevel, int32(level))
+}
+
+func (l *log) GetDisplayLevel() Level {
+	return Level(atomic.LoadInt32(&l.out.displayLevel))
+}
+
+func (l *log) Stop() {
+	l.out.lock.Lock()
+	defer l.out.lock.Unlock()
+
+	if l.out.file != nil {
+		_ = l.out.file.Close()
+		l.out.file = nil
+	}
+}
+
+// NoLog discards every message
+type NoLog struct{}
+
+func (NoLog) Write(p []byte) (int, error)  { return len(p), nil }
+func (NoLog) Fatal(string, ...interface{}) {}
+func (NoLog) Error(string, ...interface{}) {}
+func (NoLog) Warn(string, ...interface{})  {}
+func (NoLog) Info(string, ...interface{})  {}
+func (NoLog) Trace(string, ...interface{}) {}
+func (NoLog) Debug(string, ...interface{}) {}
+func (NoLog) Verbo(string, ...interface{}) {}
+func (n NoLog) With(...Field) Logger       { return n }
+func (NoLog) SetLogLevel(Level)            {}
+func (NoLog) GetLogLevel() Level           { return Off }
+func (NoLog) SetDisplayLevel(Level)        {}
+func (NoLog) GetDisplayLevel() Level       { return Off }
+func (NoLog) Stop()                        {}
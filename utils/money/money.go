@@ -0,0 +1,221 @@
+// Package money represents prices exactly, as whole numbers of a currency's
+// minor unit, so that amounts survive encoding and fee splits add up to the
+// cent.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// BasisPoints is the denominator of rates in basis points: 10000 is 100%
+const BasisPoints = 10_000
+
+var (
+	errUnknownCurrency  = errors.New("unknown currency")
+	errCurrencyMismatch = errors.New("amounts are in different currencies")
+	errNegative         = errors.New("amount is negative")
+	errOverflow         = errors.New("amount overflows")
+	errBadAmount        = errors.New("invalid amount")
+	errTooPrecise       = errors.New("amount is more precise than the currency's minor unit")
+	errBadRate          = errors.New("rate is above 100%")
+)
+
+// exponents are the number of decimals of each supported ISO 4217 currency,
+// i.e. how many minor units make a major one
+var exponents = map[string]int{
+	"AUD": 2,
+	"BHD": 3,
+	"BRL": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"CZK": 2,
+	"DKK": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"INR": 2,
+	"ISK": 0,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"NOK": 2,
+	"NZD": 2,
+	"PLN": 2,
+	"SEK": 2,
+	"SGD": 2,
+	"USD": 2,
+	"ZAR": 2,
+}
+
+// Exponent returns the number of decimals of [currency]
+func Exponent(currency string) (int, error) {
+	exponent, ok := exponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", errUnknownCurrency, currency)
+	}
+	return exponent, nil
+}
+
+// Money is an amount of a currency
+type Money struct {
+	// Amount in the currency's minor unit, e.g. cents of EUR
+	Amount int64 `json:"amount"`
+	// Currency is the ISO 4217 code, e.g. "EUR"
+	Currency string `json:"currency"`
+}
+
+// New returns [amount] minor units of [currency]
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse returns the amount of [currency] written in decimal in [s], e.g.
+// "12.5" EUR is 1250 cents
+func Parse(s, currency string) (Money, error) {
+	exponent, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	negative := strings.HasPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if whole == "" && fraction == "" || strings.ContainsAny(whole+fraction, "+-") {
+		return Money{}, fmt.Errorf("%w: %q", errBadAmount, s)
+	}
+	if len(fraction) > exponent {
+		return Money{}, fmt.Errorf("%w: %q has %d decimals, %s has %d", errTooPrecise, s, len(fraction), currency, exponent)
+	}
+	digits := whole + fraction + strings.Repeat("0", exponent-len(fraction))
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", errBadAmount, s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Verify returns nil if the currency is supported and the amount isn't
+// negative
+func (m Money) Verify() error {
+	if _, err := Exponent(m.Currency); err != nil {
+		return err
+	}
+	if m.Amount < 0 {
+		return fmt.Errorf("%w: %s", errNegative, m)
+	}
+	return nil
+}
+
+// IsZero returns true if the amount is zero
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// Add returns [m] + [o]
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", errCurrencyMismatch, m.Currency, o.Currency)
+	}
+	sum := m.Amount + o.Amount
+	if (sum > m.Amount) != (o.Amount > 0) {
+		return Money{}, fmt.Errorf("%w: %s + %s", errOverflow, m, o)
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns [m] - [o]
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: %s - %s", errOverflow, m, o)
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Cmp returns -1, 0 or 1 if [m] is less than, equal to or more than [o]
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, fmt.Errorf("%w: %s and %s", errCurrencyMismatch, m.Currency, o.Currency)
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// MulRate returns [m] times [rate] basis points, rounded down to the minor
+// unit
+func (m Money) MulRate(rate uint64) (Money, error) {
+	if m.Amount < 0 {
+		return Money{}, fmt.Errorf("%w: %s", errNegative, m)
+	}
+	hi, lo := bits.Mul64(uint64(m.Amount), rate)
+	if hi >= BasisPoints {
+		return Money{}, fmt.Errorf("%w: %s times %d basis points", errOverflow, m, rate)
+	}
+	quotient, _ := bits.Div64(hi, lo, BasisPoints)
+	if quotient > math.MaxInt64 {
+		return Money{}, fmt.Errorf("%w: %s times %d basis points", errOverflow, m, rate)
+	}
+	return Money{Amount: int64(quotient), Currency: m.Currency}, nil
+}
+
+// Split divides [m] into the share of [rate] basis points, rounded down, and
+// the rest. The two always add up to [m].
+func (m Money) Split(rate uint64) (share, rest Money, err error) {
+	if rate > BasisPoints {
+		return Money{}, Money{}, fmt.Errorf("%w: %d basis points", errBadRate, rate)
+	}
+	share, err = m.MulRate(rate)
+	if err != nil {
+		return Money{}, Money{}, err
+	}
+	// The share is at most [m], so this can't overflow
+	rest = Money{Amount: m.Amount - share.Amount, Currency: m.Currency}
+	return share, rest, nil
+}
+
+// Decimal returns the amount in major units, e.g. "12.50" for 1250 cents of
+// EUR. Unknown currencies are written in minor units.
+func (m Money) Decimal() string {
+	exponent := exponents[m.Currency]
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absUint(amount), 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// Float64 returns the amount in major units, for charts and other displays
+// that can't take a Decimal. It is rounded, so never compute with it.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / math.Pow10(exponents[m.Currency])
+}
+
+// String returns the amount and currency, e.g. "12.50 EUR"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func absUint(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
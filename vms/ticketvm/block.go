@@ -0,0 +1,145 @@
+package ticketvm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/engine/snowman"
+)
+
+var (
+	errWrongParent     = errors.New("block's parent is not the last accepted block")
+	errWrongBlkHeight  = errors.New("block height doesn't follow its parent")
+	errTimestampOrder  = errors.New("block timestamp is before its parent's")
+	errFutureTimestamp = errors.New("block timestamp is too far in the future")
+	errEmptyBlock      = errors.New("block has no transactions")
+	errBlockTooBig     = errors.New("block is too big")
+)
+
+// maxClockSkew is how far ahead of the local clock a block's timestamp may be
+const maxClockSkew = 10 * time.Second
+
+// TicketBlock is a block of the ticket chain. Its transactions are applied in
+// order when the block is accepted.
+type TicketBlock struct {
+	ParentID    ids.ID `json:"parentID"`
+	BlockHeight uint64 `json:"height"`
+	// Timestamp is when the block was built, in Unix seconds
+	Timestamp int64 `json:"timestamp"`
+	Txs       []*Tx `json:"txs"`
+	// Genesis is only set in the genesis block
+	Genesis json.RawMessage `json:"genesis,omitempty"`
+
+	vm    *VM
+	id    ids.ID
+	bytes []byte
+}
+
+// newBlock returns the block with the given contents, ready to be verified
+func (vm *VM) newBlock(parentID ids.ID, height uint64, timestamp int64, txs []*Tx) (*TicketBlock, error) {
+	if txs == nil {
+		txs = []*Tx{}
+	}
+	blk := &TicketBlock{
+		ParentID:    parentID,
+		BlockHeight: height,
+		Timestamp:   timestamp,
+		Txs:         txs,
+	}
+	bytes, err := json.Marshal(blk)
+	if err != nil {
+		return nil, err
+	}
+	blk.initialize(vm, bytes)
+	return blk, nil
+}
+
+func (blk *TicketBlock) initialize(vm *VM, bytes []byte) {
+	blk.vm = vm
+	blk.bytes = bytes
+	blk.id = ids.Checksum(bytes)
+}
+
+// ID implements the snowman.Block interface
+func (blk *TicketBlock) ID() ids.ID { return blk.id }
+
+// Parent implements the snowman.Block interface
+func (blk *TicketBlock) Parent() ids.ID { return blk.ParentID }
+
+// Height implements the snowman.Block interface
+func (blk *TicketBlock) Height() uint64 { return blk.BlockHeight }
+
+// Bytes implements the snowman.Block interface
+func (blk *TicketBlock) Bytes() []byte { return blk.bytes }
+
+// Verify implements the snowman.Block interface. The block must extend the
+// last accepted block and every transaction must apply, in order, on top of
+// the state.
+func (blk *TicketBlock) Verify() error {
+	vm := blk.vm
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	parent := vm.lastAccepted
+	switch {
+	case blk.ParentID != parent.ID():
+		return fmt.Errorf("%w: %s", errWrongParent, blk.ParentID)
+	case blk.BlockHeight != parent.BlockHeight+1:
+		return fmt.Errorf("%w: %d after %d", errWrongBlkHeight, blk.BlockHeight, parent.BlockHeight)
+	case blk.Timestamp < parent.Timestamp:
+		return errTimestampOrder
+	case time.Unix(blk.Timestamp, 0).After(vm.clock().Add(maxClockSkew)):
+		return fmt.Errorf("%w: %d", errFutureTimestamp, blk.Timestamp)
+	case len(blk.Txs) == 0:
+		return errEmptyBlock
+	case len(blk.Genesis) > 0:
+		return errUnexpectedGenesis
+	case len(blk.bytes) > vm.config.MaxBlockSize:
+		return fmt.Errorf("%w: %d bytes, limit %d", errBlockTooBig, len(blk.bytes), vm.config.MaxBlockSize)
+	}
+
+	state := vm.state.Copy()
+	for _, tx := range blk.Txs {
+		if err := state.ApplyTx(tx, blk.Timestamp); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.ID(), err)
+		}
+	}
+	vm.blocks[blk.id] = blk
+	return nil
+}
+
+// Accept implements the snowman.Block interface
+func (blk *TicketBlock) Accept() error {
+	vm := blk.vm
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	for _, tx := range blk.Txs {
+		if err := vm.state.ApplyTx(tx, blk.Timestamp); err != nil {
+			return fmt.Errorf("accepted tx %s: %w", tx.ID(), err)
+		}
+	}
+	vm.state.EndBlock()
+	vm.state.SetTimestamp(blk.Timestamp)
+	if err := vm.accept(blk); err != nil {
+		return err
+	}
+	vm.mempool.Remove(blk.Txs...)
+	return nil
+}
+
+// Reject implements the snowman.Block interface. Its transactions stay in the
+// mempool of the node that built it and are included in a later block.
+func (blk *TicketBlock) Reject() error {
+	vm := blk.vm
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	delete(vm.blocks, blk.id)
+	return nil
+}
+
+var _ snowman.Block = &TicketBlock{}
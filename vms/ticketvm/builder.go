@@ -0,0 +1,87 @@
+package ticketvm
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"ticketsystem/main/snow/engine/snowman"
+	"ticketsystem/main/utils/logging"
+)
+
+// DefaultBuildInterval is how often the builder checks for pending
+// transactions by default
+const DefaultBuildInterval = time.Second
+
+// Issuer is the part of the consensus engine blocks are handed to
+type Issuer interface {
+	// Issue adds a block built by this node to consensus
+	Issue(blk snowman.Block) error
+	// NumProcessing returns the number of blocks being decided
+	NumProcessing() int
+}
+
+// Builder periodically assembles the pending transactions into a block and
+// issues it to consensus. Only one height is decided at a time, so nothing
+// is built while a block is being decided.
+type Builder struct {
+	vm       *VM
+	issuer   Issuer
+	interval time.Duration
+	log      logging.Logger
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewBuilder returns a builder that checks for pending transactions every
+// [interval]
+func NewBuilder(vm *VM, issuer Issuer, interval time.Duration, log logging.Logger) *Builder {
+	return &Builder{
+		vm:       vm,
+		issuer:   issuer,
+		interval: interval,
+		log:      log,
+		closed:   make(chan struct{}),
+	}
+}
+
+// Dispatch builds blocks until Close is called
+func (b *Builder) Dispatch() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.closed:
+			return
+		case <-ticker.C:
+			b.build()
+		}
+	}
+}
+
+// Close stops the builder
+func (b *Builder) Close() {
+	b.closeOnce.Do(func() { close(b.closed) })
+}
+
+func (b *Builder) build() {
+	if b.issuer.NumProcessing() > 0 {
+		return
+	}
+	blk, err := b.vm.BuildBlock()
+	switch {
+	case errors.Is(err, errNoPendingTxs):
+		return
+	case err != nil:
+		b.log.Debug("couldn't build block: %s", err)
+		return
+	}
+	if err := b.issuer.Issue(blk); err != nil {
+		// A peer's block at this height may have arrived since the check
+		b.log.Debug("couldn't issue block %s: %s", blk.ID(), err)
+		return
+	}
+	b.log.Verbo("issued block %s with %d txs at height %d", blk.ID(), len(blk.Txs), blk.BlockHeight)
+}
@@ -0,0 +1,739 @@
+package ticketvm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/engine/avalanche"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/utils/logging"
+)
+
+var (
+	errNotInDAG       = errors.New("transaction type isn't supported in DAG mode")
+	errUnknownVertex  = errors.New("unknown vertex")
+	errTooManyParents = errors.New("vertex has too many parents")
+	errNoIssuer       = errors.New("DAG isn't connected to consensus")
+	errVertexOrder    = errors.New("vertex timestamp is before its parent's")
+	errStaleVertex    = errors.New("vertex timestamp is too far behind the chain time")
+	errNotParent      = errors.New("input is consumed from a vertex that isn't a parent")
+	errSpentInput     = errors.New("input version was already consumed")
+	errWrongInputs    = errors.New("vertex inputs don't match its transaction")
+)
+
+const (
+	// maxParents is the most parents a vertex may have
+	maxParents = 16
+	// maxRejectedVertices is the number of rejected vertices kept, so that
+	// peers still polling one are answered with its status
+	maxRejectedVertices = 4096
+)
+
+// Keys of the accepted DAG in the database. Vertices are indexed in the
+// order they were accepted, so they can be replayed in that order.
+var (
+	dagGenesisKey  = []byte("dag:genesis")
+	dagAcceptedKey = []byte("dag:accepted")
+	vertexPrefix   = []byte("dag:vertex:")
+	acceptedPrefix = []byte("dag:index:")
+)
+
+func vertexKey(vtxID ids.ID) []byte {
+	return append(append([]byte(nil), vertexPrefix...), vtxID[:]...)
+}
+
+func acceptedKey(index uint64) []byte {
+	key := make([]byte, len(acceptedPrefix)+8)
+	copy(key, acceptedPrefix)
+	binary.BigEndian.PutUint64(key[len(acceptedPrefix):], index)
+	return key
+}
+
+func issuedInput(eventID string) string { return "issued:" + eventID }
+
+func holderInput(eventID, holder string) string { return "holder:" + eventID + ":" + holder }
+
+// dagInputs returns the inputs [tx] consumes in DAG mode: those of its
+// transition and the balance its fee is paid from.
+func (s *State) dagInputs(tx *Tx) ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	inputs, err := s.transitionInputs(tx.transition)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := s.fee(tx)
+	if err != nil || fee.IsZero() {
+		return inputs, err
+	}
+	feeInput := balanceInput(tx.Sender(), fee.Currency)
+	for _, input := range inputs {
+		if input == feeInput {
+			return inputs, nil
+		}
+	}
+	return append(inputs, feeInput), nil
+}
+
+// transitionInputs returns the inputs [t] consumes in DAG mode. Unlike blocks,
+// vertices are verified against their ancestors only, so every invariant a
+// transition checks must be covered by an input. On top of the tickets it
+// spends, a transition consumes an event's issued count if the event has a
+// capacity, a holder's count for an event if the event caps holdings, and
+// the balance funds are taken from. Transfers out of a holding don't consume
+// it and credits don't consume the balance they add to, as they only make
+// room.
+//
+// Validator changes take effect at block boundaries and aren't supported.
+// The caller holds the lock.
+func (s *State) transitionInputs(t Transition) ([]string, error) {
+	switch t := t.(type) {
+	case *CreateEvent:
+		return t.Inputs(), nil
+	case *Issue:
+		event, err := s.getEvent(t.EventID)
+		if err != nil {
+			return nil, err
+		}
+		inputs := t.Inputs()
+		if event.Capacity > 0 {
+			inputs = append(inputs, issuedInput(t.EventID))
+		}
+		return inputs, nil
+	case *Purchase:
+		inputs, err := s.holderInputs(t.Inputs(), t.TicketID, t.Buyer)
+		if err != nil {
+			return nil, err
+		}
+		if ticket, ok := s.lookupTicket(t.TicketID); ok && !ticket.IssuePrice.IsZero() {
+			inputs = append(inputs, balanceInput(t.Buyer, ticket.IssuePrice.Currency))
+		}
+		return inputs, nil
+	case *Transfer:
+		return s.holderInputs(t.Inputs(), t.TicketID, t.To)
+	case *Refund:
+		inputs := t.Inputs()
+		if ticket, ok := s.lookupTicket(t.TicketID); ok && !ticket.IssuePrice.IsZero() {
+			inputs = append(inputs, balanceInput(t.Organizer, ticket.IssuePrice.Currency))
+		}
+		return inputs, nil
+	case *CheckIn, *CheckOut, *BindTag, *List, *Delist, *Send:
+		return t.Inputs(), nil
+	case *BuyListing:
+		return s.holderInputs(t.Inputs(), t.TicketID, t.Buyer)
+	default:
+		return nil, fmt.Errorf("%w: %T", errNotInDAG, t)
+	}
+}
+
+func (s *State) holderInputs(inputs []string, ticketID, holder string) ([]string, error) {
+	ticket, err := s.getTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	event, err := s.getEvent(ticket.EventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.MaxPerHolder > 0 {
+		inputs = append(inputs, holderInput(ticket.EventID, holder))
+	}
+	return inputs, nil
+}
+
+// TicketVertex is a vertex of the ticket DAG. Each vertex carries one
+// transaction and builds on the vertices that consumed its inputs before it.
+type TicketVertex struct {
+	ParentIDs []ids.ID `json:"parents"`
+	// Inputs maps the inputs the transaction consumes to the parent that
+	// consumed each last, or to the empty ID if none did. Vertices consuming
+	// an input from the same vertex conflict.
+	Inputs map[string]ids.ID `json:"inputs"`
+	// Timestamp is when the vertex was issued, in Unix seconds. The
+	// transaction is verified at this time.
+	Timestamp int64 `json:"timestamp"`
+	Tx        *Tx   `json:"tx"`
+
+	dag    *DAG
+	id     ids.ID
+	bytes  []byte
+	status avalanche.Status
+	// conflicts are the declared inputs with their versions, sorted
+	conflicts []string
+}
+
+func (vtx *TicketVertex) initialize(dag *DAG, bytes []byte) {
+	vtx.dag = dag
+	vtx.bytes = bytes
+	vtx.id = ids.Checksum(bytes)
+	vtx.conflicts = make([]string, 0, len(vtx.Inputs))
+	for input, version := range vtx.Inputs {
+		vtx.conflicts = append(vtx.conflicts, input+"@"+version.String())
+	}
+	sort.Strings(vtx.conflicts)
+}
+
+// ID implements the avalanche.Vertex interface
+func (vtx *TicketVertex) ID() ids.ID { return vtx.id }
+
+// Parents implements the avalanche.Vertex interface
+func (vtx *TicketVertex) Parents() []ids.ID { return vtx.ParentIDs }
+
+// Bytes implements the avalanche.Vertex interface
+func (vtx *TicketVertex) Bytes() []byte { return vtx.bytes }
+
+// Status implements the avalanche.Vertex interface
+func (vtx *TicketVertex) Status() avalanche.Status {
+	vtx.dag.lock.Lock()
+	defer vtx.dag.lock.Unlock()
+
+	return vtx.status
+}
+
+// Conflicts implements the avalanche.Vertex interface
+func (vtx *TicketVertex) Conflicts() []string { return vtx.conflicts }
+
+// Verify implements the avalanche.Vertex interface. The transaction must
+// apply on top of the accepted state and the vertex's processing ancestors,
+// and consume the inputs the vertex declares. Each input must be consumed
+// from a parent that consumed it, or from no vertex if it was never
+// consumed, and not from a version an accepted vertex already replaced.
+//
+// The vertex's timestamp can't be before its parents', nor too far ahead of
+// the local clock. It also can't be more than MaxTxLifetime behind the chain
+// time, so a transaction forgotten since it expired can't be replayed in a
+// vertex without parents.
+func (vtx *TicketVertex) Verify() error {
+	dag := vtx.dag
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	if vtx.status != avalanche.Unknown {
+		return nil
+	}
+	switch {
+	case time.Unix(vtx.Timestamp, 0).After(dag.clock().Add(maxClockSkew)):
+		return fmt.Errorf("%w: %d", errFutureTimestamp, vtx.Timestamp)
+	case vtx.Timestamp < dag.state.Timestamp()-int64(MaxTxLifetime/time.Second):
+		return fmt.Errorf("%w: %d", errStaleVertex, vtx.Timestamp)
+	}
+	parents := make(map[ids.ID]*TicketVertex, len(vtx.ParentIDs))
+	for _, parentID := range vtx.ParentIDs {
+		parent, err := dag.getVertex(parentID)
+		if err != nil {
+			return err
+		}
+		if vtx.Timestamp < parent.Timestamp {
+			return fmt.Errorf("%w: %d before %d", errVertexOrder, vtx.Timestamp, parent.Timestamp)
+		}
+		parents[parentID] = parent
+	}
+	if err := dag.checkVersions(vtx, parents); err != nil {
+		return err
+	}
+	state, err := dag.ancestorState(vtx.ParentIDs)
+	if err != nil {
+		return err
+	}
+	inputs, err := state.dagInputs(vtx.Tx)
+	if err != nil {
+		return err
+	}
+	if !sameInputs(inputs, vtx.Inputs) {
+		return fmt.Errorf("%w: tx consumes %v", errWrongInputs, inputs)
+	}
+	if err := state.ApplyTx(vtx.Tx, vtx.Timestamp); err != nil {
+		return fmt.Errorf("tx %s: %w", vtx.Tx.ID(), err)
+	}
+
+	vtx.status = avalanche.Processing
+	dag.vertices[vtx.id] = vtx
+	dag.pending[vtx.Tx.ID()] = vtx.id
+	for input := range vtx.Inputs {
+		dag.spenders[input] = vtx.id
+	}
+	return nil
+}
+
+// checkVersions returns nil if every input of [vtx] is consumed from one of
+// its [parents] that consumed it, or from no vertex, and that version wasn't
+// replaced by an accepted vertex. The caller holds the lock.
+func (dag *DAG) checkVersions(vtx *TicketVertex, parents map[ids.ID]*TicketVertex) error {
+	for input, version := range vtx.Inputs {
+		if version != ids.Empty {
+			parent, ok := parents[version]
+			if !ok {
+				return fmt.Errorf("%w: %s from %s", errNotParent, input, version)
+			}
+			if _, ok := parent.Inputs[input]; !ok {
+				return fmt.Errorf("%w: %s from %s", errNotParent, input, version)
+			}
+			if parent.status != avalanche.Accepted {
+				continue
+			}
+		}
+		// The version must still be the last accepted one
+		if consumer, ok := dag.consumers[input]; ok && consumer != version || !ok && version != ids.Empty {
+			return fmt.Errorf("%w: %s@%s", errSpentInput, input, version)
+		}
+	}
+	return nil
+}
+
+// sameInputs returns true if [inputs] and [declared] hold the same inputs
+func sameInputs(inputs []string, declared map[string]ids.ID) bool {
+	seen := make(map[string]struct{}, len(inputs))
+	for _, input := range inputs {
+		if _, ok := declared[input]; !ok {
+			return false
+		}
+		seen[input] = struct{}{}
+	}
+	return len(seen) == len(declared)
+}
+
+// Accept implements the avalanche.Vertex interface
+func (vtx *TicketVertex) Accept() error {
+	dag := vtx.dag
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	if err := dag.state.ApplyTx(vtx.Tx, vtx.Timestamp); err != nil {
+		return fmt.Errorf("accepted tx %s: %w", vtx.Tx.ID(), err)
+	}
+	dag.state.SetTimestamp(vtx.Timestamp)
+	if err := dag.persist(vtx); err != nil {
+		return err
+	}
+	dag.consume(vtx)
+	vtx.status = avalanche.Accepted
+	dag.release(vtx)
+	// Accepted vertices are read back from the database when needed
+	delete(dag.vertices, vtx.id)
+	return nil
+}
+
+// Reject implements the avalanche.Vertex interface
+func (vtx *TicketVertex) Reject() error {
+	dag := vtx.dag
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	vtx.status = avalanche.Rejected
+	dag.release(vtx)
+	delete(dag.vertices, vtx.id)
+	dag.rejected[vtx.id] = vtx
+	dag.rejectedOrder = append(dag.rejectedOrder, vtx.id)
+	if len(dag.rejectedOrder) > maxRejectedVertices {
+		delete(dag.rejected, dag.rejectedOrder[0])
+		dag.rejectedOrder = dag.rejectedOrder[1:]
+	}
+	return nil
+}
+
+// VertexIssuer is the part of the DAG consensus engine vertices are handed to
+type VertexIssuer interface {
+	Issue(vtx avalanche.Vertex) error
+}
+
+// DAG is the ticket chain run as a DAG of transactions. Transactions
+// touching unrelated tickets don't conflict, so the avalanche engine decides
+// them in parallel instead of one block at a time.
+type DAG struct {
+	state *State
+	db    common.Database
+	log   logging.Logger
+	clock func() time.Time
+
+	lock   sync.Mutex
+	issuer VertexIssuer
+	// vertices are the processing vertices. Accepted vertices are stored in
+	// the database, and the last rejected ones in rejected.
+	vertices      map[ids.ID]*TicketVertex
+	rejected      map[ids.ID]*TicketVertex
+	rejectedOrder []ids.ID
+	// numAccepted is the number of vertices accepted since genesis
+	numAccepted uint64
+	// spenders maps inputs to the processing vertex that last consumed them,
+	// and consumers to the accepted vertex that did. New vertices consuming
+	// an input build on its last spender or else its last consumer.
+	spenders  map[string]ids.ID
+	consumers map[string]ids.ID
+	// pending maps the transactions of processing vertices to the vertex
+	pending map[ids.ID]ids.ID
+}
+
+// Initialize the DAG on top of [state], which starts out empty and is set to
+// [genesis]. Accepted vertices are stored in [db] and replayed into [state]
+// when the node restarts. A stored DAG that was started from another genesis
+// is refused. Without a database the DAG is kept in memory.
+func (dag *DAG) Initialize(genesis *Genesis, state *State, db common.Database, log logging.Logger) error {
+	if db == nil {
+		db = common.NewMemDatabase()
+	}
+	dag.state = state
+	dag.db = db
+	dag.log = log
+	dag.clock = time.Now
+	dag.vertices = make(map[ids.ID]*TicketVertex)
+	dag.rejected = make(map[ids.ID]*TicketVertex)
+	dag.spenders = make(map[string]ids.ID)
+	dag.consumers = make(map[string]ids.ID)
+	dag.pending = make(map[ids.ID]ids.ID)
+
+	genesisID, err := genesis.BlockID()
+	if err != nil {
+		return err
+	}
+	if err := genesis.apply(state); err != nil {
+		return err
+	}
+	storedGenesisID, err := db.Get(dagGenesisKey)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return db.Put(dagGenesisKey, genesisID[:])
+	case err != nil:
+		return err
+	case string(storedGenesisID) != string(genesisID[:]):
+		return fmt.Errorf("%w: stored %x, genesis file gives %s", errGenesisMismatch, storedGenesisID, genesisID)
+	}
+	return dag.replay()
+}
+
+// replay applies the accepted vertices stored in the database to the state,
+// in the order they were accepted
+func (dag *DAG) replay() error {
+	numAcceptedBytes, err := dag.db.Get(dagAcceptedKey)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case len(numAcceptedBytes) != 8:
+		return fmt.Errorf("%w: accepted vertex count", errCorruptChain)
+	}
+	numAccepted := binary.BigEndian.Uint64(numAcceptedBytes)
+	for index := uint64(0); index < numAccepted; index++ {
+		vtxIDBytes, err := dag.db.Get(acceptedKey(index))
+		if err != nil {
+			return fmt.Errorf("%w: vertex %d: %s", errCorruptChain, index, err)
+		}
+		vtxID, err := ids.ToID(vtxIDBytes)
+		if err != nil {
+			return fmt.Errorf("%w: vertex %d: %s", errCorruptChain, index, err)
+		}
+		vtx, err := dag.loadVertex(vtxID)
+		if err != nil {
+			return err
+		}
+		if err := dag.state.ApplyTx(vtx.Tx, vtx.Timestamp); err != nil {
+			return fmt.Errorf("%w: vertex %s: %s", errCorruptChain, vtxID, err)
+		}
+		dag.state.SetTimestamp(vtx.Timestamp)
+		dag.consume(vtx)
+	}
+	dag.numAccepted = numAccepted
+	dag.log.Info("replayed %d accepted vertices", numAccepted)
+	return nil
+}
+
+// consume records the accepted vertex [vtx] as the last consumer of its
+// inputs. The caller holds the lock.
+func (dag *DAG) consume(vtx *TicketVertex) {
+	for input := range vtx.Inputs {
+		dag.consumers[input] = vtx.id
+	}
+}
+
+// persist stores [vtx] as the next accepted vertex. The caller holds the
+// lock.
+func (dag *DAG) persist(vtx *TicketVertex) error {
+	if err := dag.db.Put(vertexKey(vtx.id), vtx.bytes); err != nil {
+		return err
+	}
+	if err := dag.db.Put(acceptedKey(dag.numAccepted), vtx.id[:]); err != nil {
+		return err
+	}
+	// The count is written last, so a crash leaves the previously accepted
+	// vertices intact
+	numAccepted := make([]byte, 8)
+	binary.BigEndian.PutUint64(numAccepted, dag.numAccepted+1)
+	if err := dag.db.Put(dagAcceptedKey, numAccepted); err != nil {
+		return err
+	}
+	dag.numAccepted++
+	return nil
+}
+
+// loadVertex reads the accepted vertex [vtxID] from the database
+func (dag *DAG) loadVertex(vtxID ids.ID) (*TicketVertex, error) {
+	bytes, err := dag.db.Get(vertexKey(vtxID))
+	if err != nil {
+		return nil, err
+	}
+	vtx := &TicketVertex{}
+	if err := json.Unmarshal(bytes, vtx); err != nil {
+		return nil, fmt.Errorf("%w: vertex %s: %s", errCorruptChain, vtxID, err)
+	}
+	if vtx.Tx == nil {
+		return nil, fmt.Errorf("%w: vertex %s has no tx", errCorruptChain, vtxID)
+	}
+	vtx.initialize(dag, bytes)
+	vtx.status = avalanche.Accepted
+	return vtx, nil
+}
+
+// getVertex returns the processing, accepted or recently rejected vertex
+// [vtxID]. The caller holds the lock.
+func (dag *DAG) getVertex(vtxID ids.ID) (*TicketVertex, error) {
+	if vtx, ok := dag.vertices[vtxID]; ok {
+		return vtx, nil
+	}
+	if vtx, ok := dag.rejected[vtxID]; ok {
+		return vtx, nil
+	}
+	vtx, err := dag.loadVertex(vtxID)
+	if errors.Is(err, common.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", errUnknownVertex, vtxID)
+	}
+	return vtx, err
+}
+
+// SetIssuer sets the engine issued transactions are handed to. The engine is
+// created after the DAG, as it needs the DAG.
+func (dag *DAG) SetIssuer(issuer VertexIssuer) {
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	dag.issuer = issuer
+}
+
+// State returns the accepted state
+func (dag *DAG) State() *State { return dag.state }
+
+// IssueTx wraps [tx] in a vertex and issues it to consensus. The vertex
+// builds on the vertices that last consumed the transaction's inputs, so a
+// ticket can be resold before its previous sale is accepted.
+func (dag *DAG) IssueTx(tx *Tx) error {
+	dag.lock.Lock()
+	issuer := dag.issuer
+	bytes, err := dag.buildVertex(tx)
+	dag.lock.Unlock()
+
+	switch {
+	case err != nil:
+		return err
+	case issuer == nil:
+		return errNoIssuer
+	}
+	vtx, err := dag.ParseVertex(bytes)
+	if err != nil {
+		return err
+	}
+	return issuer.Issue(vtx)
+}
+
+// Pending returns true if [txID] is in a processing vertex
+func (dag *DAG) Pending(txID ids.ID) bool {
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	_, ok := dag.pending[txID]
+	return ok
+}
+
+// buildVertex returns the bytes of a vertex carrying [tx]. The caller holds
+// the lock.
+func (dag *DAG) buildVertex(tx *Tx) ([]byte, error) {
+	// Inputs that depend on a processing ancestor, such as the price of a
+	// ticket issued by one, can't be resolved against the accepted state. The
+	// ticket itself still leads to that ancestor, on top of which the inputs
+	// are resolved again until they lead to no new ancestor.
+	inputs, err := dag.state.dagInputs(tx)
+	if err != nil {
+		inputs = tx.transition.Inputs()
+	}
+	if issue, ok := tx.transition.(*Issue); ok {
+		inputs = append(inputs, eventInput(issue.EventID))
+	}
+	known := make(map[string]struct{}, len(inputs))
+	for _, input := range inputs {
+		known[input] = struct{}{}
+	}
+	for {
+		parents := dag.parents(known)
+		state, err := dag.ancestorState(parents)
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := state.dagInputs(tx)
+		if err != nil {
+			return nil, err
+		}
+		complete := true
+		for _, input := range resolved {
+			if _, ok := known[input]; !ok {
+				known[input] = struct{}{}
+				complete = false
+			}
+		}
+		if !complete {
+			continue
+		}
+
+		vtx := &TicketVertex{
+			ParentIDs: parents,
+			Inputs:    make(map[string]ids.ID, len(resolved)),
+			Timestamp: dag.clock().Unix(),
+			Tx:        tx,
+		}
+		for _, input := range resolved {
+			vtx.Inputs[input] = dag.version(input)
+		}
+		for _, parentID := range parents {
+			parent, err := dag.getVertex(parentID)
+			if err != nil {
+				return nil, err
+			}
+			if parent.Timestamp > vtx.Timestamp {
+				vtx.Timestamp = parent.Timestamp
+			}
+		}
+		return json.Marshal(vtx)
+	}
+}
+
+// version returns the vertex that last consumed [input]: its processing
+// spender, else its accepted consumer, else the empty ID. The caller holds
+// the lock.
+func (dag *DAG) version(input string) ids.ID {
+	if spender, ok := dag.spenders[input]; ok {
+		return spender
+	}
+	return dag.consumers[input]
+}
+
+// parents returns the vertices that last consumed [inputs], sorted. The
+// caller holds the lock.
+func (dag *DAG) parents(inputs map[string]struct{}) []ids.ID {
+	seen := make(map[ids.ID]struct{}, len(inputs))
+	parents := []ids.ID{}
+	for input := range inputs {
+		version := dag.version(input)
+		if version == ids.Empty {
+			continue
+		}
+		if _, ok := seen[version]; ok {
+			continue
+		}
+		seen[version] = struct{}{}
+		parents = append(parents, version)
+	}
+	sort.Slice(parents, func(i, j int) bool {
+		return string(parents[i][:]) < string(parents[j][:])
+	})
+	return parents
+}
+
+// ancestorState returns an overlay of the accepted state with the processing
+// ancestors of a vertex with [parentIDs] applied. The caller holds the lock,
+// so the accepted state doesn't change while the overlay is used.
+func (dag *DAG) ancestorState(parentIDs []ids.ID) (*State, error) {
+	var (
+		ordered []*TicketVertex
+		visited = make(map[ids.ID]struct{})
+		visit   func(vtxID ids.ID) error
+	)
+	// Ancestors are applied parents first
+	visit = func(vtxID ids.ID) error {
+		if _, ok := visited[vtxID]; ok {
+			return nil
+		}
+		visited[vtxID] = struct{}{}
+		vtx, err := dag.getVertex(vtxID)
+		if err != nil {
+			return err
+		}
+		switch vtx.status {
+		case avalanche.Accepted:
+			return nil
+		case avalanche.Processing:
+		default:
+			return fmt.Errorf("%w: %s is %s", errUnknownVertex, vtxID, vtx.status)
+		}
+		for _, parentID := range vtx.ParentIDs {
+			if err := visit(parentID); err != nil {
+				return err
+			}
+		}
+		ordered = append(ordered, vtx)
+		return nil
+	}
+	for _, parentID := range parentIDs {
+		if err := visit(parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	state := dag.state.overlay()
+	for _, vtx := range ordered {
+		if err := state.ApplyTx(vtx.Tx, vtx.Timestamp); err != nil {
+			return nil, fmt.Errorf("ancestor %s: %w", vtx.id, err)
+		}
+	}
+	return state, nil
+}
+
+// release forgets [vtx] as the last spender of its inputs once it is decided.
+// The caller holds the lock.
+func (dag *DAG) release(vtx *TicketVertex) {
+	for input := range vtx.Inputs {
+		if dag.spenders[input] == vtx.id {
+			delete(dag.spenders, input)
+		}
+	}
+	if dag.pending[vtx.Tx.ID()] == vtx.id {
+		delete(dag.pending, vtx.Tx.ID())
+	}
+}
+
+// ParseVertex implements the avalanche.DAGVM interface
+func (dag *DAG) ParseVertex(b []byte) (avalanche.Vertex, error) {
+	vtx := &TicketVertex{}
+	if err := json.Unmarshal(b, vtx); err != nil {
+		return nil, err
+	}
+	switch {
+	case vtx.Tx == nil:
+		return nil, errMissingID
+	case len(vtx.ParentIDs) > maxParents:
+		return nil, fmt.Errorf("%w: %d, limit %d", errTooManyParents, len(vtx.ParentIDs), maxParents)
+	}
+	vtx.initialize(dag, b)
+
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	// Return the known vertex so that its status is shared
+	if known, err := dag.getVertex(vtx.id); err == nil {
+		return known, nil
+	}
+	return vtx, nil
+}
+
+// GetVertex implements the avalanche.DAGVM interface
+func (dag *DAG) GetVertex(vtxID ids.ID) (avalanche.Vertex, error) {
+	dag.lock.Lock()
+	defer dag.lock.Unlock()
+
+	return dag.getVertex(vtxID)
+}
+
+var _ avalanche.DAGVM = &DAG{}
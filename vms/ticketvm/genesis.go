@@ -0,0 +1,241 @@
+package ticketvm
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils/money"
+
+	snowball "ticketsystem/main/snow"
+)
+
+var (
+	errNoChainID          = errors.New("genesis has no chain ID")
+	errNoGenesisTimestamp = errors.New("genesis has no timestamp")
+	errBadOrganizerKey    = errors.New("invalid organizer public key")
+	errUnknownOrganizer   = errors.New("genesis event's organizer isn't a genesis organizer")
+	errGenesisMismatch    = errors.New("stored genesis doesn't match the genesis file")
+	errUnexpectedGenesis  = errors.New("only the genesis block may hold a genesis")
+)
+
+// Genesis is the initial state of the ticket chain. Every node of a chain
+// must start from the same genesis, as the genesis block's ID is derived
+// from it.
+type Genesis struct {
+	// ChainID of the ticket chain. Peers on another chain are dropped.
+	ChainID ids.ID `json:"chainID"`
+	// Timestamp of the genesis block, in Unix seconds
+	Timestamp int64 `json:"timestamp"`
+	// Consensus are the snowball parameters every validator uses
+	Consensus snowball.Parameters `json:"consensus"`
+	StakeCaps StakeCaps           `json:"stakeCaps"`
+	// Validators are the initial validator set. Their operators are
+	// admitted to add further validators.
+	Validators []Validator `json:"validators"`
+	// Operators are the addresses of further operators admitted to add
+	// validators, e.g. venues that join the chain later
+	Operators []string `json:"operators,omitempty"`
+	// Organizers may create events from the start. Their keys are
+	// registered with the API as organizers.
+	Organizers []GenesisOrganizer `json:"organizers"`
+	// Events are created, and their tickets issued, in the genesis block
+	Events []GenesisEvent `json:"events"`
+	// Balances are the initial funds of accounts. They are the only funds on
+	// the chain, e.g. held by a payment provider.
+	Balances []GenesisBalance `json:"balances,omitempty"`
+	// FeeCurrency is the currency transaction fees are paid in. If it is
+	// empty, transactions carry no fee.
+	FeeCurrency string `json:"feeCurrency,omitempty"`
+}
+
+// GenesisBalance is an account's funds in one currency at genesis
+type GenesisBalance struct {
+	Address string      `json:"address"`
+	Balance money.Money `json:"balance"`
+}
+
+// GenesisOrganizer is an organizer known at genesis
+type GenesisOrganizer struct {
+	Name string `json:"name"`
+	// PublicKey is the hex encoded ed25519 key the organizer signs with
+	PublicKey string `json:"publicKey"`
+}
+
+// Key returns the organizer's public key
+func (o GenesisOrganizer) Key() (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(o.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: %s", errBadOrganizerKey, o.Name)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// GenesisEvent is an event created at genesis
+type GenesisEvent struct {
+	Event Event `json:"event"`
+	// TicketIDs are issued for the event at [Price]
+	TicketIDs []string    `json:"ticketIDs"`
+	Price     money.Money `json:"price"`
+}
+
+// LoadGenesis reads and verifies the genesis file at [path]
+func LoadGenesis(path string) (*Genesis, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := ParseGenesis(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis %s: %w", path, err)
+	}
+	return genesis, nil
+}
+
+// ParseGenesis parses and verifies a genesis
+func ParseGenesis(b []byte) (*Genesis, error) {
+	genesis := &Genesis{}
+	if err := json.Unmarshal(b, genesis); err != nil {
+		return nil, err
+	}
+	if err := genesis.Verify(); err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// Verify that the genesis describes a valid initial state
+func (g *Genesis) Verify() error {
+	switch {
+	case g.ChainID.IsZero():
+		return errNoChainID
+	case g.Timestamp <= 0:
+		return errNoGenesisTimestamp
+	}
+	if err := g.Consensus.Verify(); err != nil {
+		return err
+	}
+	_, err := g.State()
+	return err
+}
+
+// Bytes returns the canonical encoding of the genesis, which the genesis
+// block holds
+func (g *Genesis) Bytes() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// State returns the state the chain starts from
+func (g *Genesis) State() (*State, error) {
+	state := NewState()
+	if err := g.apply(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// BlockID returns the ID of the genesis block
+func (g *Genesis) BlockID() (ids.ID, error) {
+	blk, err := (&VM{}).genesisBlock(g)
+	if err != nil {
+		return ids.Empty, err
+	}
+	return blk.ID(), nil
+}
+
+// apply creates the validators, events, tickets and balances of the genesis
+// in [s]
+func (g *Genesis) apply(s *State) error {
+	organizers := make(map[string]struct{}, len(g.Organizers))
+	for _, organizer := range g.Organizers {
+		key, err := organizer.Key()
+		if err != nil {
+			return err
+		}
+		organizers[Address(key)] = struct{}{}
+	}
+
+	if g.FeeCurrency != "" {
+		if _, err := money.Exponent(g.FeeCurrency); err != nil {
+			return fmt.Errorf("fee currency: %w", err)
+		}
+		s.feeCurrency = g.FeeCurrency
+	}
+	s.SetTimestamp(g.Timestamp)
+	s.SetStakeCaps(g.StakeCaps)
+	for _, operator := range g.Operators {
+		s.admitOperator(operator)
+	}
+	for _, vdr := range g.Validators {
+		s.admitOperator(vdr.Operator)
+		if err := s.Apply(&AddValidator{Validator: vdr}); err != nil {
+			return fmt.Errorf("genesis validator %s: %w", vdr.NodeID, err)
+		}
+	}
+	s.EndBlock()
+
+	for _, genesisEvent := range g.Events {
+		event := genesisEvent.Event
+		if _, ok := organizers[event.Organizer]; !ok {
+			return fmt.Errorf("%w: event %s", errUnknownOrganizer, event.ID)
+		}
+		if err := s.Apply(&CreateEvent{Event: event}); err != nil {
+			return fmt.Errorf("genesis event %s: %w", event.ID, err)
+		}
+		if len(genesisEvent.TicketIDs) == 0 {
+			continue
+		}
+		if err := s.Apply(&Issue{
+			Organizer: event.Organizer,
+			EventID:   event.ID,
+			TicketIDs: genesisEvent.TicketIDs,
+			Price:     genesisEvent.Price,
+		}); err != nil {
+			return fmt.Errorf("genesis tickets of %s: %w", event.ID, err)
+		}
+	}
+
+	// Funds are only moved after genesis, so a currency's total supply
+	// fitting in an int64 keeps every balance from overflowing
+	supply := make(map[string]money.Money)
+	for _, balance := range g.Balances {
+		if err := balance.Balance.Verify(); err != nil {
+			return fmt.Errorf("%w: balance of %s: %s", errInvalidAmount, balance.Address, err)
+		}
+		total, ok := supply[balance.Balance.Currency]
+		if !ok {
+			total = money.New(0, balance.Balance.Currency)
+		}
+		total, err := total.Add(balance.Balance)
+		if err != nil {
+			return fmt.Errorf("genesis balance of %s: %w", balance.Address, err)
+		}
+		supply[balance.Balance.Currency] = total
+		s.credit(balance.Address, balance.Balance)
+	}
+	return nil
+}
+
+// genesisBlock returns the block at height 0, which holds [g]. Its ID only
+// depends on the genesis, so every node derives the same one.
+func (vm *VM) genesisBlock(g *Genesis) (*TicketBlock, error) {
+	genesisBytes, err := g.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	blk := &TicketBlock{
+		Timestamp: g.Timestamp,
+		Txs:       []*Tx{},
+		Genesis:   genesisBytes,
+	}
+	bytes, err := json.Marshal(blk)
+	if err != nil {
+		return nil, err
+	}
+	blk.initialize(vm, bytes)
+	return blk, nil
+}
@@ -0,0 +1,205 @@
+package ticketvm
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+
+	"ticketsystem/main/ids"
+)
+
+var (
+	errDuplicateTx   = errors.New("transaction is already pending")
+	errConflictingTx = errors.New("transaction conflicts with a pending transaction")
+	errMempoolFull   = errors.New("mempool is full")
+)
+
+// DefaultMempoolSize is the number of pending transactions kept by default
+const DefaultMempoolSize = 4096
+
+// Mempool holds the transactions waiting to be included in a block. It is
+// safe for concurrent use.
+//
+// Transactions paying the highest fee are included in blocks first, and
+// those paying the same fee in the order they arrived. When the pool is
+// full, a transaction paying more evicts the one paying the least. Two
+// pending transactions never spend the same input: the first one seen wins
+// and the later one is rejected.
+type Mempool struct {
+	lock    sync.Mutex
+	maxSize int
+
+	txs map[ids.ID]*pendingTx
+	// spenders maps each input to the pending transaction consuming it
+	spenders map[string]ids.ID
+	queue    txQueue
+	arrivals uint64
+}
+
+type pendingTx struct {
+	tx      *Tx
+	arrival uint64
+	// index in the queue
+	index int
+}
+
+// NewMempool returns a mempool holding at most [maxSize] transactions
+func NewMempool(maxSize int) *Mempool {
+	return &Mempool{
+		maxSize:  maxSize,
+		txs:      make(map[ids.ID]*pendingTx),
+		spenders: make(map[string]ids.ID),
+	}
+}
+
+// Add [tx] to the pool. The caller is expected to have verified [tx] against
+// the state. If the pool is full, [tx] evicts the last pending transaction to
+// be included if it pays more, and is rejected otherwise.
+func (m *Mempool) Add(tx *Tx) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	txID := tx.ID()
+	if _, ok := m.txs[txID]; ok {
+		return fmt.Errorf("%w: %s", errDuplicateTx, txID)
+	}
+	for _, input := range tx.transition.Inputs() {
+		if spender, ok := m.spenders[input]; ok {
+			return fmt.Errorf("%w: %s already spends %s", errConflictingTx, spender, input)
+		}
+	}
+	ptx := &pendingTx{
+		tx:      tx,
+		arrival: m.arrivals + 1,
+	}
+	if len(m.txs) >= m.maxSize {
+		last := m.last()
+		if last == nil || !less(ptx, last) {
+			return fmt.Errorf("%w: %d transactions pending", errMempoolFull, len(m.txs))
+		}
+		m.remove(last.tx.ID())
+	}
+
+	m.arrivals++
+	m.txs[txID] = ptx
+	for _, input := range tx.transition.Inputs() {
+		m.spenders[input] = txID
+	}
+	heap.Push(&m.queue, ptx)
+	return nil
+}
+
+// Has returns true if [txID] is pending
+func (m *Mempool) Has(txID ids.ID) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, ok := m.txs[txID]
+	return ok
+}
+
+// Len returns the number of pending transactions
+func (m *Mempool) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.txs)
+}
+
+// Ordered returns the pending transactions in the order they are included in
+// blocks
+func (m *Mempool) Ordered() []*Tx {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	queue := make(txQueue, len(m.queue))
+	copy(queue, m.queue)
+	// Popping from the copy must not move the indices of the pool's
+	// entries, so the copy holds its own entries
+	for i, ptx := range queue {
+		queue[i] = &pendingTx{tx: ptx.tx, arrival: ptx.arrival, index: i}
+	}
+	txs := make([]*Tx, 0, len(queue))
+	for queue.Len() > 0 {
+		txs = append(txs, heap.Pop(&queue).(*pendingTx).tx)
+	}
+	return txs
+}
+
+// Remove drops [txs] and every pending transaction spending one of their
+// inputs. It is called with the transactions of accepted blocks.
+func (m *Mempool) Remove(txs ...*Tx) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, tx := range txs {
+		m.remove(tx.ID())
+		for _, input := range tx.transition.Inputs() {
+			if spender, ok := m.spenders[input]; ok {
+				m.remove(spender)
+			}
+		}
+	}
+}
+
+func (m *Mempool) remove(txID ids.ID) {
+	ptx, ok := m.txs[txID]
+	if !ok {
+		return
+	}
+	delete(m.txs, txID)
+	for _, input := range ptx.tx.transition.Inputs() {
+		if m.spenders[input] == txID {
+			delete(m.spenders, input)
+		}
+	}
+	heap.Remove(&m.queue, ptx.index)
+}
+
+// last returns the pending transaction that would be included last. The
+// caller holds the lock.
+func (m *Mempool) last() *pendingTx {
+	var last *pendingTx
+	for _, ptx := range m.queue {
+		if last == nil || less(last, ptx) {
+			last = ptx
+		}
+	}
+	return last
+}
+
+// less returns true if [a] should be included in a block before [b]
+func less(a, b *pendingTx) bool {
+	if a.tx.Fee != b.tx.Fee {
+		return a.tx.Fee > b.tx.Fee
+	}
+	return a.arrival < b.arrival
+}
+
+// txQueue is a heap of pending transactions, highest fee first
+type txQueue []*pendingTx
+
+func (q txQueue) Len() int           { return len(q) }
+func (q txQueue) Less(i, j int) bool { return less(q[i], q[j]) }
+
+func (q txQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *txQueue) Push(x interface{}) {
+	ptx := x.(*pendingTx)
+	ptx.index = len(*q)
+	*q = append(*q, ptx)
+}
+
+func (q *txQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	ptx := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return ptx
+}
@@ -0,0 +1,284 @@
+package ticketvm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"ticketsystem/main/utils/money"
+)
+
+// MaxRoyalty is the largest royalty, in basis points, an organizer may take
+// from resales: all of the price
+const MaxRoyalty = money.BasisPoints
+
+var (
+	errAboveCap          = errors.New("asking price is above the event's resale cap")
+	errNotListed         = errors.New("ticket isn't listed for resale")
+	errNotSeller         = errors.New("caller didn't list the ticket")
+	errPriceChanged      = errors.New("listing's price doesn't match")
+	errOwnListing        = errors.New("seller can't buy their own listing")
+	errInsufficientFunds = errors.New("insufficient balance")
+	errInvalidAmount     = errors.New("invalid amount")
+	errBadRoyalty        = errors.New("royalty is above 100%")
+)
+
+func balanceInput(address, currency string) string { return "balance:" + address + ":" + currency }
+
+// account is an address' funds in one currency
+type account struct {
+	address  string
+	currency string
+}
+
+// Listing is a held ticket offered for resale
+type Listing struct {
+	TicketID string      `json:"ticketID"`
+	EventID  string      `json:"eventID"`
+	Seller   string      `json:"seller"`
+	Price    money.Money `json:"price"`
+}
+
+// ResaleCap returns the highest price a ticket for [e] may be listed at,
+// rounded down to the minor unit
+func (e *Event) ResaleCap() (money.Money, error) {
+	return e.FaceValue.MulRate(money.BasisPoints + uint64(e.MaxMarkup))
+}
+
+// List offers a held ticket for resale at [Price], or changes the price of
+// its listing. The ticket stays with the seller until it is bought, and the
+// listing is dropped if the ticket changes holder or is checked in first.
+type List struct {
+	TicketID string      `json:"ticketID"`
+	Seller   string      `json:"seller"`
+	Price    money.Money `json:"price"`
+}
+
+// Verify implements the Transition interface
+func (t *List) Verify(s *State) error {
+	ticket, err := s.getTicket(t.TicketID)
+	if err != nil {
+		return err
+	}
+	if ticket.Status != Held {
+		return fmt.Errorf("%w: %s is %s", errNotHeld, t.TicketID, ticket.Status)
+	}
+	if ticket.Holder != t.Seller {
+		return errNotHolder
+	}
+	event, err := s.getEvent(ticket.EventID)
+	if err != nil {
+		return err
+	}
+	if err := checkPrice(t.Price, event); err != nil {
+		return err
+	}
+	resaleCap, err := event.ResaleCap()
+	if err != nil {
+		return err
+	}
+	if t.Price.Amount > resaleCap.Amount {
+		return fmt.Errorf("%w: %s over %s", errAboveCap, t.Price, resaleCap)
+	}
+	return nil
+}
+
+// Execute implements the Transition interface
+func (t *List) Execute(s *State) {
+	ticket, _ := s.lookupTicket(t.TicketID)
+	s.listings[t.TicketID] = &Listing{
+		TicketID: t.TicketID,
+		EventID:  ticket.EventID,
+		Seller:   t.Seller,
+		Price:    t.Price,
+	}
+}
+
+// Actor implements the Transition interface
+func (t *List) Actor() string { return t.Seller }
+
+// Inputs implements the Transition interface
+func (t *List) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// Delist withdraws a ticket from resale
+type Delist struct {
+	TicketID string `json:"ticketID"`
+	Seller   string `json:"seller"`
+}
+
+// Verify implements the Transition interface
+func (t *Delist) Verify(s *State) error {
+	listing, ok := s.lookupListing(t.TicketID)
+	if !ok {
+		return fmt.Errorf("%w: %s", errNotListed, t.TicketID)
+	}
+	if listing.Seller != t.Seller {
+		return errNotSeller
+	}
+	return nil
+}
+
+// Execute implements the Transition interface
+func (t *Delist) Execute(s *State) {
+	s.removeListing(t.TicketID)
+}
+
+// Actor implements the Transition interface
+func (t *Delist) Actor() string { return t.Seller }
+
+// Inputs implements the Transition interface
+func (t *Delist) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// BuyListing buys a listed ticket. The price is taken from the buyer's
+// balance and split between the organizer's royalty and the seller, and the
+// ticket moves to the buyer, all in one transaction. [Price] must be the
+// listing's, so a seller can't raise it under a buyer's feet.
+type BuyListing struct {
+	TicketID string      `json:"ticketID"`
+	Buyer    string      `json:"buyer"`
+	Price    money.Money `json:"price"`
+}
+
+// Verify implements the Transition interface
+func (t *BuyListing) Verify(s *State) error {
+	if t.Buyer == "" {
+		return errMissingID
+	}
+	listing, ok := s.lookupListing(t.TicketID)
+	if !ok {
+		return fmt.Errorf("%w: %s", errNotListed, t.TicketID)
+	}
+	switch {
+	case listing.Price != t.Price:
+		return fmt.Errorf("%w: listed at %s", errPriceChanged, listing.Price)
+	case listing.Seller == t.Buyer:
+		return errOwnListing
+	}
+	if err := s.checkFunds(t.Buyer, t.Price); err != nil {
+		return err
+	}
+	return s.checkHolderCap(listing.EventID, t.Buyer, 1)
+}
+
+// Execute implements the Transition interface
+func (t *BuyListing) Execute(s *State) {
+	ticket := s.mutableTicket(t.TicketID)
+	listing, _ := s.lookupListing(t.TicketID)
+	event, _ := s.lookupEvent(ticket.EventID)
+
+	// Royalties are at most 100% and listed prices aren't negative, so the
+	// split can't fail. The royalty is rounded down and the seller gets the
+	// rest, so the two add up to the price.
+	royalty, rest, _ := t.Price.Split(uint64(event.Royalty))
+	s.debit(t.Buyer, t.Price)
+	s.credit(event.Organizer, royalty)
+	s.credit(listing.Seller, rest)
+	ticket.Price = t.Price
+	s.setHolder(ticket, t.Buyer)
+}
+
+// Actor implements the Transition interface
+func (t *BuyListing) Actor() string { return t.Buyer }
+
+// Inputs implements the Transition interface
+func (t *BuyListing) Inputs() []string {
+	return []string{ticketInput(t.TicketID), balanceInput(t.Buyer, t.Price.Currency)}
+}
+
+// Send moves funds between accounts. Funds enter the chain through the
+// genesis, e.g. in the account of a payment provider that sends them to
+// fans paying it.
+type Send struct {
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Amount money.Money `json:"amount"`
+}
+
+// Verify implements the Transition interface
+func (t *Send) Verify(s *State) error {
+	if t.To == "" {
+		return errMissingID
+	}
+	if err := t.Amount.Verify(); err != nil || t.Amount.IsZero() {
+		return fmt.Errorf("%w: %s", errInvalidAmount, t.Amount)
+	}
+	return s.checkFunds(t.From, t.Amount)
+}
+
+// Execute implements the Transition interface
+func (t *Send) Execute(s *State) {
+	s.debit(t.From, t.Amount)
+	s.credit(t.To, t.Amount)
+}
+
+// Actor implements the Transition interface
+func (t *Send) Actor() string { return t.From }
+
+// Inputs implements the Transition interface
+func (t *Send) Inputs() []string { return []string{balanceInput(t.From, t.Amount.Currency)} }
+
+// checkFunds returns nil if [address] holds at least [amount]
+func (s *State) checkFunds(address string, amount money.Money) error {
+	if balance := s.balance(address, amount.Currency); balance < amount.Amount {
+		return fmt.Errorf("%w: %s has %s", errInsufficientFunds, address, money.New(balance, amount.Currency))
+	}
+	return nil
+}
+
+// credit adds [amount] to the balance of [address]. Funds only move between
+// accounts after genesis, whose totals fit in an int64, so no balance can
+// overflow.
+func (s *State) credit(address string, amount money.Money) {
+	if amount.IsZero() {
+		return
+	}
+	s.balances[account{address, amount.Currency}] = s.balance(address, amount.Currency) + amount.Amount
+}
+
+// debit takes [amount], which checkFunds found [address] holds, from the
+// balance of [address]
+func (s *State) debit(address string, amount money.Money) {
+	if amount.IsZero() {
+		return
+	}
+	s.balances[account{address, amount.Currency}] = s.balance(address, amount.Currency) - amount.Amount
+}
+
+// Balances returns the funds of [address] in each currency it holds,
+// ordered by currency
+func (s *State) Balances(address string) []money.Money {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var balances []money.Money
+	for account, balance := range s.balances {
+		if account.address == address && balance != 0 {
+			balances = append(balances, money.New(balance, account.currency))
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Currency < balances[j].Currency
+	})
+	return balances
+}
+
+// Listings returns the tickets listed for resale for [eventID], cheapest
+// first
+func (s *State) Listings(eventID string) []Listing {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var listings []Listing
+	for _, listing := range s.listings {
+		if listing.EventID == eventID {
+			listings = append(listings, *listing)
+		}
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].Price.Amount != listings[j].Price.Amount {
+			return listings[i].Price.Amount < listings[j].Price.Amount
+		}
+		return listings[i].TicketID < listings[j].TicketID
+	})
+	return listings
+}
@@ -0,0 +1,179 @@
+package ticketvm
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/utils/money"
+)
+
+// chain is where the service issues transactions. It is the VM in linear
+// mode and the DAG in DAG mode.
+type chain interface {
+	IssueTx(tx *Tx) error
+	// Pending returns true if [txID] was issued but not yet accepted
+	Pending(txID ids.ID) bool
+}
+
+// Service is the API of the ticket chain, served as the "tickets" JSON-RPC
+// service
+type Service struct {
+	state *State
+	chain chain
+}
+
+// IssueTxArgs are the arguments to IssueTx
+type IssueTxArgs struct {
+	// Tx is a signed transaction, as created by NewTx
+	Tx json.RawMessage `json:"tx"`
+}
+
+// IssueTxReply is the reply from IssueTx
+type IssueTxReply struct {
+	TxID ids.ID `json:"txID"`
+}
+
+// IssueTx issues a signed transaction to consensus. In linear mode it waits
+// in the mempool for a block, in DAG mode it is issued as its own vertex.
+func (s *Service) IssueTx(_ *http.Request, args *IssueTxArgs, reply *IssueTxReply) error {
+	tx, err := ParseTx(args.Tx)
+	if err != nil {
+		return err
+	}
+	if err := s.chain.IssueTx(tx); err != nil {
+		return err
+	}
+	reply.TxID = tx.ID()
+	return nil
+}
+
+// GetTxStatusArgs are the arguments to GetTxStatus
+type GetTxStatusArgs struct {
+	TxID ids.ID `json:"txID"`
+}
+
+// GetTxStatusReply is the reply from GetTxStatus. Status is "accepted",
+// "pending" or "unknown". An accepted transaction is unknown again once it is
+// MaxTxLifetime past its expiry.
+type GetTxStatusReply struct {
+	Status string `json:"status"`
+}
+
+// GetTxStatus returns whether a transaction was accepted
+func (s *Service) GetTxStatus(_ *http.Request, args *GetTxStatusArgs, reply *GetTxStatusReply) error {
+	switch {
+	case s.state.HasTx(args.TxID):
+		reply.Status = "accepted"
+	case s.chain.Pending(args.TxID):
+		reply.Status = "pending"
+	default:
+		reply.Status = "unknown"
+	}
+	return nil
+}
+
+// GetEventArgs are the arguments to GetEvent
+type GetEventArgs struct {
+	EventID string `json:"eventID"`
+}
+
+// GetEvent returns an event as of the last accepted block
+func (s *Service) GetEvent(_ *http.Request, args *GetEventArgs, reply *Event) error {
+	event, err := s.state.Event(args.EventID)
+	if err != nil {
+		return err
+	}
+	*reply = event
+	return nil
+}
+
+// GetTicketArgs are the arguments to GetTicket
+type GetTicketArgs struct {
+	TicketID string `json:"ticketID"`
+}
+
+// GetTicket returns a ticket as of the last accepted block
+func (s *Service) GetTicket(_ *http.Request, args *GetTicketArgs, reply *Ticket) error {
+	ticket, err := s.state.Ticket(args.TicketID)
+	if err != nil {
+		return err
+	}
+	*reply = ticket
+	return nil
+}
+
+// GetListingsArgs are the arguments to GetListings
+type GetListingsArgs struct {
+	EventID string `json:"eventID"`
+}
+
+// GetListingsReply is the reply from GetListings
+type GetListingsReply struct {
+	Listings []Listing `json:"listings"`
+}
+
+// GetListings returns the tickets for an event listed for resale, cheapest
+// first, as of the last accepted block
+func (s *Service) GetListings(_ *http.Request, args *GetListingsArgs, reply *GetListingsReply) error {
+	if _, err := s.state.Event(args.EventID); err != nil {
+		return err
+	}
+	reply.Listings = s.state.Listings(args.EventID)
+	if reply.Listings == nil {
+		reply.Listings = []Listing{}
+	}
+	return nil
+}
+
+// GetBalancesArgs are the arguments to GetBalances
+type GetBalancesArgs struct {
+	Address string `json:"address"`
+}
+
+// GetBalancesReply is the reply from GetBalances
+type GetBalancesReply struct {
+	Balances []money.Money `json:"balances"`
+}
+
+// GetBalances returns the funds of an account in each currency it holds, as
+// of the last accepted block
+func (s *Service) GetBalances(_ *http.Request, args *GetBalancesArgs, reply *GetBalancesReply) error {
+	reply.Balances = s.state.Balances(args.Address)
+	if reply.Balances == nil {
+		reply.Balances = []money.Money{}
+	}
+	return nil
+}
+
+// CreateHandlers implements the common.VM interface
+func (vm *VM) CreateHandlers() map[string]*common.HTTPHandler {
+	return newHandlers(vm.state, vm)
+}
+
+// CreateHandlers implements the common.VM interface
+func (dag *DAG) CreateHandlers() map[string]*common.HTTPHandler {
+	return newHandlers(dag.state, dag)
+}
+
+func newHandlers(state *State, chain chain) map[string]*common.HTTPHandler {
+	server := rpc.NewServer()
+	codec := json2.NewCodec()
+	server.RegisterCodec(codec, "application/json")
+	server.RegisterCodec(codec, "application/json;charset=UTF-8")
+	// Service only has methods of the expected form, so registering can't
+	// fail
+	_ = server.RegisterService(&Service{state: state, chain: chain}, "tickets")
+	return map[string]*common.HTTPHandler{
+		"": {LockOptions: common.NoLock, Handler: server},
+	}
+}
+
+var (
+	_ common.VM = &VM{}
+	_ common.VM = &DAG{}
+)
@@ -0,0 +1,476 @@
+package ticketvm
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils/money"
+)
+
+var (
+	errUnknownEvent  = errors.New("unknown event")
+	errUnknownTicket = errors.New("unknown ticket")
+)
+
+// Status is the lifecycle stage of a ticket
+type Status uint8
+
+const (
+	// Available tickets were issued by the organizer and not yet sold
+	Available Status = iota
+	// Held tickets are owned by a holder
+	Held
+	// CheckedIn tickets were used to enter the event
+	CheckedIn
+	// Exited tickets were checked in and scanned out. They may enter again
+	// if the event allows re-entry.
+	Exited
+)
+
+func (s Status) String() string {
+	switch s {
+	case Available:
+		return "available"
+	case Held:
+		return "held"
+	case CheckedIn:
+		return "checkedIn"
+	case Exited:
+		return "exited"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
+// EntryPolicy is whether an event's tickets may enter more than once
+type EntryPolicy uint8
+
+const (
+	// SingleEntry tickets are admitted once
+	SingleEntry EntryPolicy = iota
+	// ReEntry tickets may be scanned out at an exit and admitted again
+	ReEntry
+)
+
+func (p EntryPolicy) String() string {
+	switch p {
+	case SingleEntry:
+		return "singleEntry"
+	case ReEntry:
+		return "reEntry"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(p))
+	}
+}
+
+// Entry is a ticket's admission through a gate
+type Entry struct {
+	Gate string `json:"gate"`
+	// Time is when the ticket was scanned, in Unix seconds
+	Time int64 `json:"time"`
+}
+
+// Event is an event tickets are issued for
+type Event struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Organizer string `json:"organizer"`
+	StartTime int64  `json:"startTime"`
+	// FaceValue is the ticket's price as printed. Its currency is the
+	// event's: tickets are issued and resold in it.
+	FaceValue money.Money `json:"faceValue"`
+	Capacity  int         `json:"capacity"`
+	// MaxPerHolder is the most tickets for this event a single holder may
+	// own. Zero means no limit.
+	MaxPerHolder int `json:"maxPerHolder"`
+	// ReEntry is the event's entry policy
+	ReEntry EntryPolicy `json:"reEntry"`
+	// MaxMarkup is how far above face value tickets may be resold, and
+	// Royalty the organizer's cut of each resale, both in basis points
+	MaxMarkup uint32 `json:"maxMarkup,omitempty"`
+	Royalty   uint32 `json:"royalty,omitempty"`
+	// ResaleOnly disables transfers, so tickets only change hands through
+	// listings and the resale cap can't be dodged by paying off chain
+	ResaleOnly bool `json:"resaleOnly,omitempty"`
+}
+
+// Ticket is the on-chain record of a ticket
+type Ticket struct {
+	ID      string `json:"id"`
+	EventID string `json:"eventID"`
+	Holder  string `json:"holder"`
+	// IssuePrice is the price the organizer sells the ticket at. Purchases
+	// pay it and refunds return it, whatever the ticket was resold at.
+	IssuePrice money.Money `json:"issuePrice"`
+	// Price the ticket was last sold at
+	Price  money.Money `json:"price"`
+	Status Status      `json:"status"`
+	// Rotation counts the ticket's changes of holder. Rotating QR secrets
+	// are derived from it, so a previous holder's codes stop working.
+	Rotation uint64 `json:"rotation"`
+	// FirstEntry is the ticket's first admission, and Entries how many
+	// times it was admitted
+	FirstEntry *Entry `json:"firstEntry,omitempty"`
+	Entries    int    `json:"entries,omitempty"`
+	// TagKey is the public key of the NFC tag or phone the holder bound the
+	// ticket to. Gates only admit a bound ticket on a signature of their
+	// challenge by this key.
+	TagKey ed25519.PublicKey `json:"tagKey,omitempty"`
+}
+
+// State is the ticket chain state that transitions are applied to
+type State struct {
+	lock sync.RWMutex
+	// parent is set if the state is an overlay of it. Reads fall through to
+	// the parent and writes are kept in the overlay, so the parent isn't
+	// changed and needn't be copied.
+	parent *State
+
+	events  map[string]*Event
+	tickets map[string]*Ticket
+	// event ID -> holder -> number of tickets held
+	holdings map[string]map[string]int
+	// event ID -> number of tickets issued
+	issued map[string]int
+	// listings for resale, by ticket ID
+	listings map[string]*Listing
+	// funds of each account, in minor units
+	balances map[account]int64
+	// feeCurrency is the currency transaction fees are paid in. Without one,
+	// transactions carry no fee.
+	feeCurrency string
+
+	// validators in effect for the block being decided
+	validators map[ids.ShortID]*Validator
+	// pendingValidators are changes made by the current block. A nil
+	// validator is removed at the end of the block.
+	pendingValidators map[ids.ShortID]*Validator
+	stakeCaps         StakeCaps
+	// operators admitted to add validators
+	operators map[string]struct{}
+
+	// timestamp is the chain time, in Unix seconds
+	timestamp int64
+	// txs maps the IDs of the transactions applied to their expiry, so none
+	// is replayed before it expires
+	txs map[ids.ID]int64
+}
+
+// NewState returns an empty state
+func NewState() *State {
+	return &State{
+		events:   make(map[string]*Event),
+		tickets:  make(map[string]*Ticket),
+		holdings: make(map[string]map[string]int),
+		issued:   make(map[string]int),
+		listings: make(map[string]*Listing),
+		balances: make(map[account]int64),
+
+		validators:        make(map[ids.ShortID]*Validator),
+		pendingValidators: make(map[ids.ShortID]*Validator),
+		operators:         make(map[string]struct{}),
+
+		txs: make(map[ids.ID]int64),
+	}
+}
+
+// Copy returns a deep copy of the state. Blocks are verified against a copy
+// so that a block which turns out to be invalid leaves the state untouched.
+func (s *State) Copy() *State {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	c := NewState()
+	for eventID, event := range s.events {
+		event := *event
+		c.events[eventID] = &event
+	}
+	for ticketID, ticket := range s.tickets {
+		ticket := *ticket
+		c.tickets[ticketID] = &ticket
+	}
+	for eventID, counts := range s.holdings {
+		countsCopy := make(map[string]int, len(counts))
+		for holder, count := range counts {
+			countsCopy[holder] = count
+		}
+		c.holdings[eventID] = countsCopy
+	}
+	for eventID, issued := range s.issued {
+		c.issued[eventID] = issued
+	}
+	for ticketID, listing := range s.listings {
+		listing := *listing
+		c.listings[ticketID] = &listing
+	}
+	for account, balance := range s.balances {
+		c.balances[account] = balance
+	}
+	c.feeCurrency = s.feeCurrency
+	for nodeID, vdr := range s.validators {
+		vdr := *vdr
+		c.validators[nodeID] = &vdr
+	}
+	for nodeID, vdr := range s.pendingValidators {
+		if vdr != nil {
+			vdrCopy := *vdr
+			vdr = &vdrCopy
+		}
+		c.pendingValidators[nodeID] = vdr
+	}
+	c.stakeCaps = s.stakeCaps
+	for operator := range s.operators {
+		c.operators[operator] = struct{}{}
+	}
+	c.timestamp = s.timestamp
+	for txID, expiry := range s.txs {
+		c.txs[txID] = expiry
+	}
+	return c
+}
+
+// overlay returns a state that reads through to [s] and keeps its own
+// writes. Transactions are applied to an overlay to verify them without
+// copying the state, so [s] must not change while the overlay is used.
+// Overlays can't be iterated, e.g. to list an event's tickets.
+func (s *State) overlay() *State {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	o := NewState()
+	o.parent = s
+	o.feeCurrency = s.feeCurrency
+	o.timestamp = s.timestamp
+	return o
+}
+
+// Apply verifies [t] against the current state and, if it is valid,
+// executes it
+func (s *State) Apply(t Transition) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := t.Verify(s); err != nil {
+		return err
+	}
+	t.Execute(s)
+	return nil
+}
+
+// Verify returns nil if [t] could be applied to the current state
+func (s *State) Verify(t Transition) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return t.Verify(s)
+}
+
+// Event returns a copy of the event with ID [eventID]
+func (s *State) Event(eventID string) (Event, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	event, err := s.getEvent(eventID)
+	if err != nil {
+		return Event{}, err
+	}
+	return *event, nil
+}
+
+// Ticket returns a copy of the ticket with ID [ticketID]
+func (s *State) Ticket(ticketID string) (Ticket, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ticket, err := s.getTicket(ticketID)
+	if err != nil {
+		return Ticket{}, err
+	}
+	return *ticket, nil
+}
+
+// OrganizerEvents returns copies of the events owned by [organizer]
+func (s *State) OrganizerEvents(organizer string) []Event {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var events []Event
+	for _, event := range s.events {
+		if event.Organizer == organizer {
+			events = append(events, *event)
+		}
+	}
+	return events
+}
+
+// EventTickets returns copies of the tickets issued for [eventID]
+func (s *State) EventTickets(eventID string) []Ticket {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var tickets []Ticket
+	for _, ticket := range s.tickets {
+		if ticket.EventID == eventID {
+			tickets = append(tickets, *ticket)
+		}
+	}
+	return tickets
+}
+
+// Organizer returns the organizer of [eventID]. This lets the state resolve
+// event ownership for the API's authorization policy.
+func (s *State) Organizer(eventID string) (string, error) {
+	event, err := s.Event(eventID)
+	if err != nil {
+		return "", err
+	}
+	return event.Organizer, nil
+}
+
+func (s *State) getEvent(eventID string) (*Event, error) {
+	event, ok := s.lookupEvent(eventID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownEvent, eventID)
+	}
+	return event, nil
+}
+
+// getTicket returns the ticket [ticketID] to be read. Tickets are changed
+// through mutableTicket.
+func (s *State) getTicket(ticketID string) (*Ticket, error) {
+	ticket, ok := s.lookupTicket(ticketID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownTicket, ticketID)
+	}
+	return ticket, nil
+}
+
+// The lookups below read through an overlay to its parent. The parent's
+// lock isn't taken, as the parent doesn't change while the overlay is used.
+
+func (s *State) lookupEvent(eventID string) (*Event, bool) {
+	event, ok := s.events[eventID]
+	if !ok && s.parent != nil {
+		return s.parent.lookupEvent(eventID)
+	}
+	return event, ok
+}
+
+func (s *State) lookupTicket(ticketID string) (*Ticket, bool) {
+	ticket, ok := s.tickets[ticketID]
+	if !ok && s.parent != nil {
+		return s.parent.lookupTicket(ticketID)
+	}
+	return ticket, ok
+}
+
+// lookupListing treats a nil listing as removed, so that an overlay can
+// remove its parent's listings
+func (s *State) lookupListing(ticketID string) (*Listing, bool) {
+	listing, ok := s.listings[ticketID]
+	if !ok && s.parent != nil {
+		return s.parent.lookupListing(ticketID)
+	}
+	return listing, listing != nil
+}
+
+func (s *State) lookupTx(txID ids.ID) bool {
+	_, ok := s.txs[txID]
+	if !ok && s.parent != nil {
+		return s.parent.lookupTx(txID)
+	}
+	return ok
+}
+
+func (s *State) issuedCount(eventID string) int {
+	issued, ok := s.issued[eventID]
+	if !ok && s.parent != nil {
+		return s.parent.issuedCount(eventID)
+	}
+	return issued
+}
+
+func (s *State) held(eventID, holder string) int {
+	held, ok := s.holdings[eventID][holder]
+	if !ok && s.parent != nil {
+		return s.parent.held(eventID, holder)
+	}
+	return held
+}
+
+func (s *State) balance(address, currency string) int64 {
+	balance, ok := s.balances[account{address, currency}]
+	if !ok && s.parent != nil {
+		return s.parent.balance(address, currency)
+	}
+	return balance
+}
+
+// mutableTicket returns the ticket [ticketID], which must exist, to be
+// changed. An overlay copies its parent's ticket first.
+func (s *State) mutableTicket(ticketID string) *Ticket {
+	if ticket, ok := s.tickets[ticketID]; ok {
+		return ticket
+	}
+	ticket, _ := s.parent.lookupTicket(ticketID)
+	ticketCopy := *ticket
+	s.tickets[ticketID] = &ticketCopy
+	return &ticketCopy
+}
+
+func (s *State) setHeld(eventID, holder string, held int) {
+	counts, ok := s.holdings[eventID]
+	if !ok {
+		counts = make(map[string]int)
+		s.holdings[eventID] = counts
+	}
+	if held == 0 && s.parent == nil {
+		delete(counts, holder)
+		return
+	}
+	counts[holder] = held
+}
+
+func (s *State) removeListing(ticketID string) {
+	if s.parent == nil {
+		delete(s.listings, ticketID)
+		return
+	}
+	s.listings[ticketID] = nil
+}
+
+func (s *State) checkHolderCap(eventID, holder string, quantity int) error {
+	event, err := s.getEvent(eventID)
+	if err != nil {
+		return err
+	}
+	if event.MaxPerHolder == 0 {
+		return nil
+	}
+	if held := s.held(eventID, holder); held+quantity > event.MaxPerHolder {
+		return fmt.Errorf("%w: %s holds %d of %d tickets for %s",
+			errHolderCapExceeded, holder, held, event.MaxPerHolder, eventID)
+	}
+	return nil
+}
+
+// setHolder moves [ticket], as returned by mutableTicket, to [holder],
+// keeping the per holder counts up to date, rotating the ticket's QR secret,
+// unbinding its tag and dropping its resale listing. An empty holder means
+// the ticket returns to the organizer.
+func (s *State) setHolder(ticket *Ticket, holder string) {
+	if ticket.Holder != "" {
+		s.setHeld(ticket.EventID, ticket.Holder, s.held(ticket.EventID, ticket.Holder)-1)
+	}
+	ticket.Holder = holder
+	ticket.Rotation++
+	ticket.TagKey = nil
+	s.removeListing(ticket.ID)
+	if holder == "" {
+		return
+	}
+	s.setHeld(ticket.EventID, holder, s.held(ticket.EventID, holder)+1)
+}
@@ -0,0 +1,437 @@
+package ticketvm
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"ticketsystem/main/utils/money"
+)
+
+var (
+	errEventExists       = errors.New("event already exists")
+	errTicketExists      = errors.New("ticket already exists")
+	errMissingID         = errors.New("missing ID")
+	errNotOrganizer      = errors.New("caller is not the event organizer")
+	errNotHolder         = errors.New("caller does not hold the ticket")
+	errNotAvailable      = errors.New("ticket is not available")
+	errNotHeld           = errors.New("ticket is not held")
+	errCapacityExceeded  = errors.New("event capacity exceeded")
+	errHolderCapExceeded = errors.New("per holder ticket cap exceeded")
+	errInvalidPrice      = errors.New("invalid price")
+	errWrongCurrency     = errors.New("price isn't in the event's currency")
+	errBadEntryPolicy    = errors.New("unknown entry policy")
+	errAlreadyCheckedIn  = errors.New("ticket was already checked in")
+	errNoReEntry         = errors.New("event doesn't allow re-entry")
+	errNotCheckedIn      = errors.New("ticket isn't checked in")
+	errBadTagKey         = errors.New("tag key isn't an ed25519 public key")
+	errResaleOnly        = errors.New("event's tickets only change hands through resale listings")
+)
+
+// Transition is a change to the ticket chain state
+type Transition interface {
+	// Verify returns nil if the transition can be applied to [s]. The caller
+	// holds the state lock.
+	Verify(s *State) error
+
+	// Execute applies the transition to [s]. Verify must have returned nil
+	// and the caller holds the state lock.
+	Execute(s *State)
+
+	// Actor is the address that must sign the transition
+	Actor() string
+	// Inputs are the keys of the state the transition consumes. Two
+	// pending transitions with a common input conflict, e.g. two purchases
+	// of the same ticket.
+	Inputs() []string
+}
+
+func eventInput(eventID string) string   { return "event:" + eventID }
+func ticketInput(ticketID string) string { return "ticket:" + ticketID }
+
+// CreateEvent registers a new event owned by its organizer
+type CreateEvent struct {
+	Event Event `json:"event"`
+}
+
+// Verify implements the Transition interface
+func (t *CreateEvent) Verify(s *State) error {
+	switch {
+	case t.Event.ID == "" || t.Event.Organizer == "":
+		return errMissingID
+	case t.Event.ReEntry > ReEntry:
+		return fmt.Errorf("%w: %s", errBadEntryPolicy, t.Event.ReEntry)
+	case t.Event.Royalty > MaxRoyalty:
+		return fmt.Errorf("%w: %d basis points", errBadRoyalty, t.Event.Royalty)
+	}
+	if err := t.Event.FaceValue.Verify(); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidPrice, err)
+	}
+	if _, ok := s.lookupEvent(t.Event.ID); ok {
+		return fmt.Errorf("%w: %s", errEventExists, t.Event.ID)
+	}
+	return nil
+}
+
+// Execute implements the Transition interface
+func (t *CreateEvent) Execute(s *State) {
+	event := t.Event
+	s.events[event.ID] = &event
+}
+
+// Actor implements the Transition interface
+func (t *CreateEvent) Actor() string { return t.Event.Organizer }
+
+// Inputs implements the Transition interface
+func (t *CreateEvent) Inputs() []string { return []string{eventInput(t.Event.ID)} }
+
+// Issue mints tickets for an event. Issued tickets are available for purchase
+// at [Price].
+type Issue struct {
+	Organizer string      `json:"organizer"`
+	EventID   string      `json:"eventID"`
+	TicketIDs []string    `json:"ticketIDs"`
+	Price     money.Money `json:"price"`
+}
+
+// Verify implements the Transition interface
+func (t *Issue) Verify(s *State) error {
+	event, err := s.getEvent(t.EventID)
+	if err != nil {
+		return err
+	}
+	if event.Organizer != t.Organizer {
+		return errNotOrganizer
+	}
+	if err := checkPrice(t.Price, event); err != nil {
+		return err
+	}
+	if issued := s.issuedCount(t.EventID); event.Capacity > 0 && issued+len(t.TicketIDs) > event.Capacity {
+		return fmt.Errorf("%w: %d issued, capacity %d", errCapacityExceeded, issued, event.Capacity)
+	}
+	seen := make(map[string]struct{}, len(t.TicketIDs))
+	for _, ticketID := range t.TicketIDs {
+		if ticketID == "" {
+			return errMissingID
+		}
+		if _, ok := seen[ticketID]; ok {
+			return fmt.Errorf("%w: %s", errTicketExists, ticketID)
+		}
+		seen[ticketID] = struct{}{}
+		if _, ok := s.lookupTicket(ticketID); ok {
+			return fmt.Errorf("%w: %s", errTicketExists, ticketID)
+		}
+	}
+	return nil
+}
+
+// Execute implements the Transition interface
+func (t *Issue) Execute(s *State) {
+	for _, ticketID := range t.TicketIDs {
+		s.tickets[ticketID] = &Ticket{
+			ID:         ticketID,
+			EventID:    t.EventID,
+			IssuePrice: t.Price,
+			Price:      t.Price,
+			Status:     Available,
+		}
+	}
+	s.issued[t.EventID] = s.issuedCount(t.EventID) + len(t.TicketIDs)
+}
+
+// Actor implements the Transition interface
+func (t *Issue) Actor() string { return t.Organizer }
+
+// Inputs implements the Transition interface
+func (t *Issue) Inputs() []string {
+	inputs := make([]string, len(t.TicketIDs))
+	for i, ticketID := range t.TicketIDs {
+		inputs[i] = ticketInput(ticketID)
+	}
+	return inputs
+}
+
+// Purchase moves an available ticket to [Buyer]. The ticket's issue price is
+// taken from the buyer's balance and credited to the event's organizer.
+type Purchase struct {
+	TicketID string `json:"ticketID"`
+	Buyer    string `json:"buyer"`
+}
+
+// Verify implements the Transition interface
+func (t *Purchase) Verify(s *State) error {
+	if t.Buyer == "" {
+		return errMissingID
+	}
+	ticket, err := s.getTicket(t.TicketID)
+	if err != nil {
+		return err
+	}
+	if ticket.Status != Available {
+		return fmt.Errorf("%w: %s is %s", errNotAvailable, t.TicketID, ticket.Status)
+	}
+	if err := s.checkFunds(t.Buyer, ticket.IssuePrice); err != nil {
+		return err
+	}
+	return s.checkHolderCap(ticket.EventID, t.Buyer, 1)
+}
+
+// Execute implements the Transition interface
+func (t *Purchase) Execute(s *State) {
+	ticket := s.mutableTicket(t.TicketID)
+	event, _ := s.lookupEvent(ticket.EventID)
+	s.debit(t.Buyer, ticket.IssuePrice)
+	s.credit(event.Organizer, ticket.IssuePrice)
+	ticket.Price = ticket.IssuePrice
+	ticket.Status = Held
+	s.setHolder(ticket, t.Buyer)
+}
+
+// Actor implements the Transition interface
+func (t *Purchase) Actor() string { return t.Buyer }
+
+// Inputs implements the Transition interface
+func (t *Purchase) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// Transfer moves a held ticket from [From] to [To]
+type Transfer struct {
+	TicketID string `json:"ticketID"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// Verify implements the Transition interface
+func (t *Transfer) Verify(s *State) error {
+	if t.To == "" {
+		return errMissingID
+	}
+	ticket, err := s.getTicket(t.TicketID)
+	if err != nil {
+		return err
+	}
+	if ticket.Status != Held {
+		return fmt.Errorf("%w: %s is %s", errNotHeld, t.TicketID, ticket.Status)
+	}
+	if ticket.Holder != t.From {
+		return errNotHolder
+	}
+	event, err := s.getEvent(ticket.EventID)
+	if err != nil {
+		return err
+	}
+	if event.ResaleOnly {
+		return fmt.Errorf("%w: %s", errResaleOnly, ticket.EventID)
+	}
+	// The cap applies to transfers too, otherwise it could be dodged by
+	// buying with several accounts and consolidating afterwards.
+	return s.checkHolderCap(ticket.EventID, t.To, 1)
+}
+
+// Execute implements the Transition interface
+func (t *Transfer) Execute(s *State) {
+	s.setHolder(s.mutableTicket(t.TicketID), t.To)
+}
+
+// Actor implements the Transition interface
+func (t *Transfer) Actor() string { return t.From }
+
+// Inputs implements the Transition interface
+func (t *Transfer) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// Refund returns a held ticket to the organizer. The ticket's issue price is
+// taken from the organizer's balance and credited to the holder, and the
+// ticket is available for purchase again.
+type Refund struct {
+	Organizer string `json:"organizer"`
+	TicketID  string `json:"ticketID"`
+}
+
+// Verify implements the Transition interface
+func (t *Refund) Verify(s *State) error {
+	ticket, err := s.getTicket(t.TicketID)
+	if err != nil {
+		return err
+	}
+	event, err := s.getEvent(ticket.EventID)
+	if err != nil {
+		return err
+	}
+	if event.Organizer != t.Organizer {
+		return errNotOrganizer
+	}
+	// Checked in tickets were used and aren't refunded
+	if ticket.Status != Held {
+		return fmt.Errorf("%w: %s is %s", errNotHeld, t.TicketID, ticket.Status)
+	}
+	return s.checkFunds(t.Organizer, ticket.IssuePrice)
+}
+
+// Execute implements the Transition interface
+func (t *Refund) Execute(s *State) {
+	ticket := s.mutableTicket(t.TicketID)
+	s.debit(t.Organizer, ticket.IssuePrice)
+	s.credit(ticket.Holder, ticket.IssuePrice)
+	ticket.Status = Available
+	s.setHolder(ticket, "")
+}
+
+// Actor implements the Transition interface
+func (t *Refund) Actor() string { return t.Organizer }
+
+// Inputs implements the Transition interface
+func (t *Refund) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// CheckIn admits a ticket to its event. It is signed by the event's organizer,
+// on behalf of the gate that scanned the ticket.
+type CheckIn struct {
+	Organizer string `json:"organizer"`
+	TicketID  string `json:"ticketID"`
+	Entry
+}
+
+// Verify implements the Transition interface
+func (t *CheckIn) Verify(s *State) error {
+	ticket, event, err := s.gateTicket(t.Organizer, t.TicketID, t.Gate)
+	if err != nil {
+		return err
+	}
+	switch ticket.Status {
+	case Held:
+		return nil
+	case CheckedIn:
+		return fmt.Errorf("%w: %s at gate %s at %d", errAlreadyCheckedIn, t.TicketID, ticket.FirstEntry.Gate, ticket.FirstEntry.Time)
+	case Exited:
+		if event.ReEntry != ReEntry {
+			return fmt.Errorf("%w: %s", errNoReEntry, event.ID)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %s is %s", errNotHeld, t.TicketID, ticket.Status)
+	}
+}
+
+// Execute implements the Transition interface
+func (t *CheckIn) Execute(s *State) {
+	ticket := s.mutableTicket(t.TicketID)
+	ticket.Status = CheckedIn
+	// A used ticket can't be resold
+	s.removeListing(t.TicketID)
+	if ticket.FirstEntry == nil {
+		entry := t.Entry
+		ticket.FirstEntry = &entry
+	}
+	ticket.Entries++
+}
+
+// Actor implements the Transition interface
+func (t *CheckIn) Actor() string { return t.Organizer }
+
+// Inputs implements the Transition interface
+func (t *CheckIn) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// CheckOut scans a checked in ticket out at an exit, so that it may enter
+// again. Only events that allow re-entry check tickets out.
+type CheckOut struct {
+	Organizer string `json:"organizer"`
+	TicketID  string `json:"ticketID"`
+	Entry
+}
+
+// Verify implements the Transition interface
+func (t *CheckOut) Verify(s *State) error {
+	ticket, event, err := s.gateTicket(t.Organizer, t.TicketID, t.Gate)
+	if err != nil {
+		return err
+	}
+	if event.ReEntry != ReEntry {
+		return fmt.Errorf("%w: %s", errNoReEntry, event.ID)
+	}
+	if ticket.Status != CheckedIn {
+		return fmt.Errorf("%w: %s is %s", errNotCheckedIn, t.TicketID, ticket.Status)
+	}
+	return nil
+}
+
+// Execute implements the Transition interface
+func (t *CheckOut) Execute(s *State) {
+	s.mutableTicket(t.TicketID).Status = Exited
+}
+
+// Actor implements the Transition interface
+func (t *CheckOut) Actor() string { return t.Organizer }
+
+// Inputs implements the Transition interface
+func (t *CheckOut) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// BindTag binds a held ticket to the key of an NFC tag or of the holder's
+// phone, or unbinds it if [Key] is empty. The binding is dropped when the
+// ticket changes holder.
+type BindTag struct {
+	TicketID string            `json:"ticketID"`
+	Holder   string            `json:"holder"`
+	Key      ed25519.PublicKey `json:"key"`
+}
+
+// Verify implements the Transition interface
+func (t *BindTag) Verify(s *State) error {
+	if len(t.Key) != 0 && len(t.Key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: %d bytes", errBadTagKey, len(t.Key))
+	}
+	ticket, err := s.getTicket(t.TicketID)
+	if err != nil {
+		return err
+	}
+	if ticket.Status != Held {
+		return fmt.Errorf("%w: %s is %s", errNotHeld, t.TicketID, ticket.Status)
+	}
+	if ticket.Holder != t.Holder {
+		return errNotHolder
+	}
+	return nil
+}
+
+// Execute implements the Transition interface
+func (t *BindTag) Execute(s *State) {
+	var key ed25519.PublicKey
+	if len(t.Key) != 0 {
+		key = append(ed25519.PublicKey(nil), t.Key...)
+	}
+	s.mutableTicket(t.TicketID).TagKey = key
+}
+
+// Actor implements the Transition interface
+func (t *BindTag) Actor() string { return t.Holder }
+
+// Inputs implements the Transition interface
+func (t *BindTag) Inputs() []string { return []string{ticketInput(t.TicketID)} }
+
+// checkPrice returns nil if [price] is a valid price for tickets of [event]
+func checkPrice(price money.Money, event *Event) error {
+	if err := price.Verify(); err != nil {
+		return fmt.Errorf("%w: %s", errInvalidPrice, err)
+	}
+	if price.Currency != event.FaceValue.Currency {
+		return fmt.Errorf("%w: %s, not %s", errWrongCurrency, price, event.FaceValue.Currency)
+	}
+	return nil
+}
+
+// gateTicket returns the ticket scanned at [gate] and its event, if
+// [organizer] runs the event
+func (s *State) gateTicket(organizer, ticketID, gate string) (*Ticket, *Event, error) {
+	if gate == "" {
+		return nil, nil, errMissingID
+	}
+	ticket, err := s.getTicket(ticketID)
+	if err != nil {
+		return nil, nil, err
+	}
+	event, err := s.getEvent(ticket.EventID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if event.Organizer != organizer {
+		return nil, nil, errNotOrganizer
+	}
+	return ticket, event, nil
+}
@@ -0,0 +1,375 @@
+package ticketvm
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils/money"
+)
+
+var (
+	errUnknownTxType = errors.New("unknown transaction type")
+	errBadSigner     = errors.New("signer key has the wrong length")
+	errBadSignature  = errors.New("invalid signature")
+	errWrongSigner   = errors.New("signer is not the transition's actor")
+	errTxAccepted    = errors.New("transaction was already accepted")
+	errBadKeyFile    = errors.New("key file doesn't hold a hex encoded ed25519 seed")
+	errNegativeFee   = errors.New("fee is negative")
+	errNoFees        = errors.New("chain has no fee currency, so fees must be zero")
+	errTxExpired     = errors.New("transaction expired")
+	errExpiryTooLate = errors.New("transaction expires too far in the future")
+)
+
+// MaxTxLifetime is how far past the chain time a transaction may expire.
+// Accepted transactions are remembered, so that they can't be replayed,
+// until they are MaxTxLifetime past their expiry.
+const MaxTxLifetime = 10 * time.Minute
+
+// DefaultTxLifetime is how long after it is signed the node's own
+// transactions expire, such as the gate's check-ins. It leaves room for a
+// local clock running ahead of the chain's.
+const DefaultTxLifetime = 5 * time.Minute
+
+// Transaction types, as they appear in the "type" field of a Tx
+const (
+	CreateEventType     = "createEvent"
+	IssueType           = "issue"
+	PurchaseType        = "purchase"
+	TransferType        = "transfer"
+	RefundType          = "refund"
+	CheckInType         = "checkIn"
+	CheckOutType        = "checkOut"
+	BindTagType         = "bindTag"
+	ListType            = "list"
+	DelistType          = "delist"
+	BuyListingType      = "buyListing"
+	SendType            = "send"
+	AddValidatorType    = "addValidator"
+	RemoveValidatorType = "removeValidator"
+)
+
+var txTypes = map[string]func() Transition{
+	CreateEventType:     func() Transition { return &CreateEvent{} },
+	IssueType:           func() Transition { return &Issue{} },
+	PurchaseType:        func() Transition { return &Purchase{} },
+	TransferType:        func() Transition { return &Transfer{} },
+	RefundType:          func() Transition { return &Refund{} },
+	CheckInType:         func() Transition { return &CheckIn{} },
+	CheckOutType:        func() Transition { return &CheckOut{} },
+	BindTagType:         func() Transition { return &BindTag{} },
+	ListType:            func() Transition { return &List{} },
+	DelistType:          func() Transition { return &Delist{} },
+	BuyListingType:      func() Transition { return &BuyListing{} },
+	SendType:            func() Transition { return &Send{} },
+	AddValidatorType:    func() Transition { return &AddValidator{} },
+	RemoveValidatorType: func() Transition { return &RemoveValidator{} },
+}
+
+// Address returns the account controlled by [key]. Transitions name their
+// actors by address, e.g. an event's organizer or a ticket's holder.
+func Address(key ed25519.PublicKey) string {
+	hash := sha256.Sum256(key)
+	var addr ids.ShortID
+	copy(addr[:], hash[:ids.ShortIDLen])
+	return addr.String()
+}
+
+// LoadKey reads a hex encoded ed25519 seed, as written by "ticketnode keygen
+// --type organizer"
+func LoadKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w: %s", errBadKeyFile, path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// unsignedTx is the part of a Tx covered by its signature
+type unsignedTx struct {
+	Type       string          `json:"type"`
+	Transition json.RawMessage `json:"transition"`
+	// Fee is paid by the signer in minor units of the chain's fee currency
+	// and burned. Pending transactions paying more are included first.
+	Fee int64 `json:"fee,omitempty"`
+	// Expiry is the last chain time, in Unix seconds, the transaction may be
+	// accepted at. It may be at most MaxTxLifetime past the chain time.
+	Expiry int64             `json:"expiry"`
+	Signer ed25519.PublicKey `json:"signer"`
+}
+
+// signedTx is the encoding of a Tx
+type signedTx struct {
+	unsignedTx
+	Signature []byte `json:"signature"`
+}
+
+// Tx is a transition signed by its actor
+type Tx struct {
+	signedTx
+
+	transition Transition
+	id         ids.ID
+	bytes      []byte
+}
+
+// NewTx signs [t], paying [fee] and expiring at [expiry], with [key]. The
+// key's address must be the actor of [t].
+func NewTx(t Transition, fee, expiry int64, key ed25519.PrivateKey) (*Tx, error) {
+	txType, err := transitionType(t)
+	if err != nil {
+		return nil, err
+	}
+	transitionBytes, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	utx := unsignedTx{
+		Type:       txType,
+		Transition: transitionBytes,
+		Fee:        fee,
+		Expiry:     expiry,
+		Signer:     key.Public().(ed25519.PublicKey),
+	}
+	unsignedBytes, err := json.Marshal(&utx)
+	if err != nil {
+		return nil, err
+	}
+	return newTx(utx, t, ed25519.Sign(key, unsignedBytes))
+}
+
+// ParseTx parses and verifies the signature of a transaction received from a
+// client or peer. Transactions are re-encoded, so two encodings of the same
+// transaction have the same ID.
+func ParseTx(b []byte) (*Tx, error) {
+	var tx signedTx
+	if err := json.Unmarshal(b, &tx); err != nil {
+		return nil, err
+	}
+	newTransition, ok := txTypes[tx.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errUnknownTxType, tx.Type)
+	}
+	t := newTransition()
+	if err := json.Unmarshal(tx.Transition, t); err != nil {
+		return nil, fmt.Errorf("couldn't parse %s transition: %w", tx.Type, err)
+	}
+	transitionBytes, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	tx.unsignedTx.Transition = transitionBytes
+	return newTx(tx.unsignedTx, t, tx.Signature)
+}
+
+func newTx(utx unsignedTx, t Transition, sig []byte) (*Tx, error) {
+	if len(utx.Signer) != ed25519.PublicKeySize {
+		return nil, errBadSigner
+	}
+	unsignedBytes, err := json.Marshal(&utx)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(utx.Signer, unsignedBytes, sig) {
+		return nil, errBadSignature
+	}
+	if signer := Address(utx.Signer); signer != t.Actor() {
+		return nil, fmt.Errorf("%w: signed by %s, actor is %q", errWrongSigner, signer, t.Actor())
+	}
+
+	tx := &Tx{
+		signedTx: signedTx{
+			unsignedTx: utx,
+			Signature:  sig,
+		},
+		transition: t,
+	}
+	tx.bytes, err = json.Marshal(&tx.signedTx)
+	if err != nil {
+		return nil, err
+	}
+	tx.id = ids.Checksum(tx.bytes)
+	return tx, nil
+}
+
+func transitionType(t Transition) (string, error) {
+	switch t.(type) {
+	case *CreateEvent:
+		return CreateEventType, nil
+	case *Issue:
+		return IssueType, nil
+	case *Purchase:
+		return PurchaseType, nil
+	case *Transfer:
+		return TransferType, nil
+	case *Refund:
+		return RefundType, nil
+	case *CheckIn:
+		return CheckInType, nil
+	case *CheckOut:
+		return CheckOutType, nil
+	case *BindTag:
+		return BindTagType, nil
+	case *List:
+		return ListType, nil
+	case *Delist:
+		return DelistType, nil
+	case *BuyListing:
+		return BuyListingType, nil
+	case *Send:
+		return SendType, nil
+	case *AddValidator:
+		return AddValidatorType, nil
+	case *RemoveValidator:
+		return RemoveValidatorType, nil
+	default:
+		return "", fmt.Errorf("%w: %T", errUnknownTxType, t)
+	}
+}
+
+// ID is the hash of the transaction's bytes
+func (tx *Tx) ID() ids.ID { return tx.id }
+
+// Bytes returns the encoding of the transaction sent to peers
+func (tx *Tx) Bytes() []byte { return tx.bytes }
+
+// SignedTransition returns the signed transition
+func (tx *Tx) SignedTransition() Transition { return tx.transition }
+
+// Sender returns the address that signed the transaction
+func (tx *Tx) Sender() string { return Address(tx.Signer) }
+
+// MarshalJSON encodes the transaction as its bytes
+func (tx *Tx) MarshalJSON() ([]byte, error) { return tx.bytes, nil }
+
+// UnmarshalJSON parses and verifies the transaction
+func (tx *Tx) UnmarshalJSON(b []byte) error {
+	parsed, err := ParseTx(b)
+	if err != nil {
+		return err
+	}
+	*tx = *parsed
+	return nil
+}
+
+// ApplyTx verifies [tx] against the current state at chain time [timestamp]
+// and, if it is valid, charges its fee and executes it. A transaction can
+// only be applied once.
+func (s *State) ApplyTx(tx *Tx, timestamp int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.applyTx(tx, timestamp)
+}
+
+// VerifyTx returns nil if [tx] could be applied to the current state at chain
+// time [timestamp]
+func (s *State) VerifyTx(tx *Tx, timestamp int64) error {
+	// The fee is charged while the transition is verified, so the state is
+	// written to
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.verifyTx(tx, timestamp)
+}
+
+// HasTx returns true if [txID] was applied to the state and hasn't been
+// forgotten since it expired
+func (s *State) HasTx(txID ids.ID) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.lookupTx(txID)
+}
+
+// verifyTx returns nil if [tx] could be applied to the state. The fee is
+// charged while the transition is verified, as both may draw on the same
+// balance, and given back before returning. The caller holds the lock.
+func (s *State) verifyTx(tx *Tx, timestamp int64) error {
+	switch {
+	case tx.Expiry < timestamp:
+		return fmt.Errorf("%w: at %d, verified at %d", errTxExpired, tx.Expiry, timestamp)
+	case tx.Expiry > timestamp+int64(MaxTxLifetime/time.Second):
+		return fmt.Errorf("%w: at %d, verified at %d", errExpiryTooLate, tx.Expiry, timestamp)
+	}
+	if s.lookupTx(tx.id) {
+		return fmt.Errorf("%w: %s", errTxAccepted, tx.id)
+	}
+	fee, err := s.fee(tx)
+	if err != nil {
+		return err
+	}
+	if !fee.IsZero() {
+		if err := s.checkFunds(tx.Sender(), fee); err != nil {
+			return fmt.Errorf("fee: %w", err)
+		}
+		s.debit(tx.Sender(), fee)
+		defer s.credit(tx.Sender(), fee)
+	}
+	return tx.transition.Verify(s)
+}
+
+// applyTx verifies [tx] and, if it is valid, charges its fee and executes
+// it. The caller holds the lock.
+func (s *State) applyTx(tx *Tx, timestamp int64) error {
+	if err := s.verifyTx(tx, timestamp); err != nil {
+		return err
+	}
+	fee, _ := s.fee(tx)
+	s.debit(tx.Sender(), fee)
+	tx.transition.Execute(s)
+	s.txs[tx.id] = tx.Expiry
+	return nil
+}
+
+// Timestamp returns the chain time, in Unix seconds: the time of the last
+// block or vertex applied
+func (s *State) Timestamp() int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.timestamp
+}
+
+// SetTimestamp advances the chain time to [timestamp] and forgets the
+// accepted transactions that expired more than MaxTxLifetime before it. A
+// transaction can't be replayed once forgotten, as it can only be accepted
+// at a time up to its expiry, and blocks and vertices aren't accepted that
+// far behind the chain time.
+func (s *State) SetTimestamp(timestamp int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if timestamp <= s.timestamp {
+		return
+	}
+	s.timestamp = timestamp
+	forgetBefore := timestamp - int64(MaxTxLifetime/time.Second)
+	for txID, expiry := range s.txs {
+		if expiry < forgetBefore {
+			delete(s.txs, txID)
+		}
+	}
+}
+
+// fee returns the fee [tx] pays in the chain's fee currency
+func (s *State) fee(tx *Tx) (money.Money, error) {
+	switch {
+	case tx.Fee < 0:
+		return money.Money{}, fmt.Errorf("%w: %d", errNegativeFee, tx.Fee)
+	case tx.Fee > 0 && s.feeCurrency == "":
+		return money.Money{}, errNoFees
+	}
+	return money.New(tx.Fee, s.feeCurrency), nil
+}
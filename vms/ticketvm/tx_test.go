@@ -0,0 +1,76 @@
+package ticketvm
+
+import (
+	"crypto/ed25519"
+	"reflect"
+	"testing"
+
+	"ticketsystem/main/utils/money"
+)
+
+func TestRefundTx(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	org := Address(pub)
+	eur := func(cents int64) money.Money { return money.New(cents, "EUR") }
+	s, err := (&Genesis{
+		Timestamp: 1000,
+		Balances: []GenesisBalance{
+			{Address: "alice", Balance: eur(10000)},
+			{Address: "bob", Balance: eur(20000)},
+		},
+	}).State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transitions := []Transition{
+		&CreateEvent{Event: Event{ID: "ev", Organizer: org, FaceValue: eur(10000), MaxMarkup: 2000}},
+		&Issue{Organizer: org, EventID: "ev", TicketIDs: []string{"t1"}, Price: eur(10000)},
+		&Purchase{TicketID: "t1", Buyer: "alice"},
+		&List{TicketID: "t1", Seller: "alice", Price: eur(12000)},
+		&BuyListing{TicketID: "t1", Buyer: "bob", Price: eur(12000)},
+	}
+	for _, transition := range transitions {
+		if err := s.Apply(transition); err != nil {
+			t.Fatalf("%T: %s", transition, err)
+		}
+	}
+
+	tx, err := NewTx(&Refund{Organizer: org, TicketID: "t1"}, 0, 1100, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseTx(tx.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID() != tx.ID() {
+		t.Fatalf("parsed tx %s, built %s", parsed.ID(), tx.ID())
+	}
+	if _, ok := parsed.SignedTransition().(*Refund); !ok {
+		t.Fatalf("parsed %T, expected *Refund", parsed.SignedTransition())
+	}
+	if err := s.ApplyTx(parsed, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	ticket, err := s.Ticket("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticket.Status != Available || ticket.Holder != "" {
+		t.Fatalf("refunded ticket is %s, held by %q", ticket.Status, ticket.Holder)
+	}
+	// The organizer returns the issue price, not the resale price
+	if balances := s.Balances("bob"); !reflect.DeepEqual(balances, []money.Money{eur(18000)}) {
+		t.Fatalf("holder has %v after the refund", balances)
+	}
+	if err := s.Apply(&Purchase{TicketID: "t1", Buyer: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if balances := s.Balances("bob"); !reflect.DeepEqual(balances, []money.Money{eur(8000)}) {
+		t.Fatalf("buyer has %v after buying at the issue price", balances)
+	}
+}
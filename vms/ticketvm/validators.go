@@ -0,0 +1,269 @@
+package ticketvm
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/utils/money"
+)
+
+var (
+	errValidatorExists  = errors.New("node is already a validator")
+	errUnknownValidator = errors.New("node is not a validator")
+	errZeroWeight       = errors.New("validator weight must be positive")
+	errStakeCapExceeded = errors.New("operator stake cap exceeded")
+	errNotOperator      = errors.New("caller is not the validator's operator")
+	errNotAdmitted      = errors.New("operator isn't admitted to run validators")
+	errWeightOverflow   = errors.New("total validator weight overflows")
+)
+
+// StakeCaps limit how much of the validator weight a single operator may
+// hold, so that no venue or organizer can reach the share needed to stall or
+// reverse decisions on its own
+type StakeCaps struct {
+	// MaxValidatorWeight is the most weight a single validator may hold.
+	// Zero means no limit.
+	MaxValidatorWeight uint64 `json:"maxValidatorWeight,omitempty"`
+	// MaxOperatorWeight is the most weight the validators of one operator
+	// may hold. Zero means no limit.
+	MaxOperatorWeight uint64 `json:"maxOperatorWeight"`
+	// MaxOperatorShareBps is the largest share of the total weight, in
+	// basis points, one operator may hold. It only applies once another
+	// operator validates, so the first operator can bootstrap the chain.
+	// Zero means no limit.
+	MaxOperatorShareBps uint64 `json:"maxOperatorShareBps"`
+}
+
+// Validator is a node that takes part in consensus. [Operator] is the
+// venue or organizer running the node.
+type Validator struct {
+	NodeID   ids.ShortID `json:"nodeID"`
+	Operator string      `json:"operator"`
+	Weight   uint64      `json:"weight"`
+}
+
+// AddValidator adds a node to the validator set. The node starts voting once
+// the block holding the transition is accepted. Only operators admitted in
+// the genesis may add validators.
+type AddValidator struct {
+	Validator Validator `json:"validator"`
+}
+
+// Verify implements the Transition interface
+func (t *AddValidator) Verify(s *State) error {
+	switch {
+	case t.Validator.NodeID.IsZero() || t.Validator.Operator == "":
+		return errMissingID
+	case t.Validator.Weight == 0:
+		return errZeroWeight
+	}
+	if _, ok := s.operators[t.Validator.Operator]; !ok {
+		return fmt.Errorf("%w: %s", errNotAdmitted, t.Validator.Operator)
+	}
+	if _, ok := s.getValidator(t.Validator.NodeID); ok {
+		return fmt.Errorf("%w: %s", errValidatorExists, t.Validator.NodeID)
+	}
+	return s.checkStakeCaps(t.Validator.NodeID, &t.Validator)
+}
+
+// Execute implements the Transition interface
+func (t *AddValidator) Execute(s *State) {
+	vdr := t.Validator
+	s.pendingValidators[vdr.NodeID] = &vdr
+}
+
+// Actor implements the Transition interface
+func (t *AddValidator) Actor() string { return t.Validator.Operator }
+
+// Inputs implements the Transition interface
+func (t *AddValidator) Inputs() []string { return []string{validatorInput(t.Validator.NodeID)} }
+
+func validatorInput(nodeID ids.ShortID) string { return "validator:" + nodeID.String() }
+
+// RemoveValidator removes a node from the validator set once the block
+// holding the transition is accepted. Only the node's operator may remove it,
+// and only if no other operator ends up over the stake caps.
+type RemoveValidator struct {
+	NodeID   ids.ShortID `json:"nodeID"`
+	Operator string      `json:"operator"`
+}
+
+// Verify implements the Transition interface
+func (t *RemoveValidator) Verify(s *State) error {
+	vdr, ok := s.getValidator(t.NodeID)
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownValidator, t.NodeID)
+	}
+	if vdr.Operator != t.Operator {
+		return errNotOperator
+	}
+	return s.checkStakeCaps(t.NodeID, nil)
+}
+
+// Execute implements the Transition interface
+func (t *RemoveValidator) Execute(s *State) {
+	s.pendingValidators[t.NodeID] = nil
+}
+
+// Actor implements the Transition interface
+func (t *RemoveValidator) Actor() string { return t.Operator }
+
+// Inputs implements the Transition interface
+func (t *RemoveValidator) Inputs() []string { return []string{validatorInput(t.NodeID)} }
+
+// SetStakeCaps sets the caps checked when validators are added or removed.
+// Validators already in the set are not removed if they exceed new caps.
+func (s *State) SetStakeCaps(caps StakeCaps) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stakeCaps = caps
+}
+
+// admitOperator allows [operator] to add validators
+func (s *State) admitOperator(operator string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.operators[operator] = struct{}{}
+}
+
+// operatorWeights sums the weight of a validator set by operator
+type operatorWeights struct {
+	weights  map[string]uint64
+	total    uint64
+	overflow bool
+}
+
+func (w *operatorWeights) add(vdr *Validator) {
+	var carry uint64
+	w.total, carry = bits.Add64(w.total, vdr.Weight, 0)
+	w.overflow = w.overflow || carry != 0
+	w.weights[vdr.Operator] += vdr.Weight
+}
+
+// checkStakeCaps returns an error if setting validator [nodeID] to [vdr], or
+// removing it if [vdr] is nil, would put an operator over the stake caps or
+// the total weight over what a uint64 holds. Every operator is checked, as
+// removing one operator's validator raises the share of all the others. An
+// operator already over a cap, e.g. because the first operator was alone, is
+// only rejected if the change raises its weight or share further.
+func (s *State) checkStakeCaps(nodeID ids.ShortID, vdr *Validator) error {
+	caps := s.stakeCaps
+	if vdr != nil && caps.MaxValidatorWeight > 0 && vdr.Weight > caps.MaxValidatorWeight {
+		return fmt.Errorf("%w: validator weight %d, cap is %d",
+			errStakeCapExceeded, vdr.Weight, caps.MaxValidatorWeight)
+	}
+
+	before := operatorWeights{weights: make(map[string]uint64)}
+	after := operatorWeights{weights: make(map[string]uint64)}
+	s.forEachValidator(func(other *Validator) {
+		before.add(other)
+		if other.NodeID != nodeID {
+			after.add(other)
+		}
+	})
+	if vdr != nil {
+		after.add(vdr)
+	}
+	if after.overflow {
+		return errWeightOverflow
+	}
+
+	operators := make([]string, 0, len(after.weights))
+	for operator := range after.weights {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	for _, operator := range operators {
+		weight, previous := after.weights[operator], before.weights[operator]
+		if caps.MaxOperatorWeight > 0 && weight > caps.MaxOperatorWeight && weight > previous {
+			return fmt.Errorf("%w: %s would hold %d, cap is %d",
+				errStakeCapExceeded, operator, weight, caps.MaxOperatorWeight)
+		}
+		// The share cap only applies once another operator validates, so
+		// the first operator can bootstrap the chain
+		if caps.MaxOperatorShareBps == 0 || len(after.weights) < 2 {
+			continue
+		}
+		overCap := mulGreater(weight, money.BasisPoints, after.total, caps.MaxOperatorShareBps)
+		// weight / after.total > previous / before.total
+		grew := mulGreater(weight, before.total, previous, after.total)
+		if overCap && grew {
+			return fmt.Errorf("%w: %s would hold %d of %d total weight, cap is %d bps",
+				errStakeCapExceeded, operator, weight, after.total, caps.MaxOperatorShareBps)
+		}
+	}
+	return nil
+}
+
+// mulGreater returns a1 * b1 > a2 * b2, compared in 128 bits so large
+// weights can't overflow
+func mulGreater(a1, b1, a2, b2 uint64) bool {
+	hi1, lo1 := bits.Mul64(a1, b1)
+	hi2, lo2 := bits.Mul64(a2, b2)
+	return hi1 > hi2 || (hi1 == hi2 && lo1 > lo2)
+}
+
+// forEachValidator calls [f] with every validator as it will be once the
+// pending changes take effect
+func (s *State) forEachValidator(f func(*Validator)) {
+	for nodeID, vdr := range s.validators {
+		if _, ok := s.pendingValidators[nodeID]; !ok {
+			f(vdr)
+		}
+	}
+	for _, vdr := range s.pendingValidators {
+		if vdr != nil {
+			f(vdr)
+		}
+	}
+}
+
+// EndBlock is called once every transition of a block has been applied.
+// Validator changes made by the block take effect here, so that every node
+// samples the same validator set for the next block. It returns true if the
+// validator set changed.
+func (s *State) EndBlock() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	changed := len(s.pendingValidators) > 0
+	for nodeID, vdr := range s.pendingValidators {
+		if vdr == nil {
+			delete(s.validators, nodeID)
+		} else {
+			s.validators[nodeID] = vdr
+		}
+	}
+	s.pendingValidators = make(map[ids.ShortID]*Validator)
+	return changed
+}
+
+// Validators returns the validator set in effect, ordered by node ID
+func (s *State) Validators() []Validator {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	vdrs := make([]Validator, 0, len(s.validators))
+	for _, vdr := range s.validators {
+		vdrs = append(vdrs, *vdr)
+	}
+	sort.Slice(vdrs, func(i, j int) bool {
+		return string(vdrs[i].NodeID[:]) < string(vdrs[j].NodeID[:])
+	})
+	return vdrs
+}
+
+// getValidator returns the validator [nodeID] as it will be once the pending
+// changes take effect
+func (s *State) getValidator(nodeID ids.ShortID) (*Validator, bool) {
+	if vdr, ok := s.pendingValidators[nodeID]; ok {
+		return vdr, vdr != nil
+	}
+	vdr, ok := s.validators[nodeID]
+	return vdr, ok
+}
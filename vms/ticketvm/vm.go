@@ -0,0 +1,433 @@
+package ticketvm
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ticketsystem/main/ids"
+	"ticketsystem/main/network"
+	"ticketsystem/main/snow/engine/common"
+	"ticketsystem/main/snow/engine/snowman"
+	"ticketsystem/main/snow/validators"
+	"ticketsystem/main/utils/logging"
+)
+
+var (
+	errUnknownBlock    = errors.New("unknown block")
+	errUnknownHeight   = errors.New("no block accepted at height")
+	errNoPendingTxs    = errors.New("no pending transactions")
+	errNoTxsFit        = errors.New("no pending transaction fits in a block")
+	errBadMaxBlockSize = errors.New("max block size must be positive")
+	errCorruptChain    = errors.New("stored chain is corrupt")
+)
+
+// Keys of the accepted chain in the database
+var (
+	lastAcceptedKey = []byte("lastAccepted")
+	blockPrefix     = []byte("block:")
+	heightPrefix    = []byte("height:")
+)
+
+func blockKey(blkID ids.ID) []byte {
+	return append(append([]byte(nil), blockPrefix...), blkID[:]...)
+}
+
+func heightKey(height uint64) []byte {
+	key := make([]byte, len(heightPrefix)+8)
+	copy(key, heightPrefix)
+	binary.BigEndian.PutUint64(key[len(heightPrefix):], height)
+	return key
+}
+
+// Gossiper sends messages to a sample of connected peers
+type Gossiper interface {
+	Gossip(msg *network.Message) []ids.ShortID
+}
+
+// Config of the VM
+type Config struct {
+	// MaxBlockSize is the largest encoded block, in bytes
+	MaxBlockSize int `json:"maxBlockSize"`
+	// MaxBlockTxs is the most transactions in a block. Zero means no limit.
+	MaxBlockTxs int `json:"maxBlockTxs"`
+	// MempoolSize is the most transactions kept pending
+	MempoolSize int `json:"mempoolSize"`
+}
+
+// DefaultConfig ...
+var DefaultConfig = Config{
+	MaxBlockSize: 1 << 20, // 1 MiB, well under the network's message limit
+	MaxBlockTxs:  1024,
+	MempoolSize:  DefaultMempoolSize,
+}
+
+// VM is the ticket chain. It builds, verifies and accepts the blocks decided
+// by the snowman engine and holds the transactions waiting to be included.
+type VM struct {
+	config   Config
+	state    *State
+	db       common.Database
+	mempool  *Mempool
+	gossiper Gossiper
+	log      logging.Logger
+	clock    func() time.Time
+
+	lock sync.Mutex
+	// blocks are the verified blocks that are accepted or being decided
+	blocks       map[ids.ID]*TicketBlock
+	heights      map[uint64]ids.ID
+	lastAccepted *TicketBlock
+}
+
+// Initialize the VM on top of [state], which starts out empty. The chain
+// starts from [genesis]. Accepted blocks are stored in [db] and replayed into
+// [state] when the node restarts. A stored chain that was started from
+// another genesis is refused. Without a database the
+// chain is kept in memory. Pending transactions are gossiped through
+// [gossiper], which may be nil if the node runs alone.
+func (vm *VM) Initialize(config Config, genesis *Genesis, state *State, db common.Database, gossiper Gossiper, log logging.Logger) error {
+	if config.MaxBlockSize <= 0 {
+		return errBadMaxBlockSize
+	}
+	if db == nil {
+		db = common.NewMemDatabase()
+	}
+	vm.config = config
+	vm.state = state
+	vm.db = db
+	vm.mempool = NewMempool(config.MempoolSize)
+	vm.gossiper = gossiper
+	vm.log = log
+	vm.clock = time.Now
+	vm.blocks = make(map[ids.ID]*TicketBlock)
+	vm.heights = make(map[uint64]ids.ID)
+
+	genesisBlk, err := vm.genesisBlock(genesis)
+	if err != nil {
+		return err
+	}
+	lastAcceptedBytes, err := db.Get(lastAcceptedKey)
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		if err := genesis.apply(state); err != nil {
+			return err
+		}
+		return vm.accept(genesisBlk)
+	case err != nil:
+		return err
+	}
+	lastAcceptedID, err := ids.ToID(lastAcceptedBytes)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errCorruptChain, err)
+	}
+	storedGenesisID, err := db.Get(heightKey(0))
+	if err != nil {
+		return fmt.Errorf("%w: genesis: %s", errCorruptChain, err)
+	}
+	if string(storedGenesisID) != string(genesisBlk.id[:]) {
+		return fmt.Errorf("%w: stored %x, genesis file gives %s", errGenesisMismatch, storedGenesisID, genesisBlk.id)
+	}
+	return vm.replay(genesis, lastAcceptedID)
+}
+
+// replay loads the accepted chain up to [lastAcceptedID] from the database
+// and applies [genesis] and then the blocks' transactions to the state
+func (vm *VM) replay(genesis *Genesis, lastAcceptedID ids.ID) error {
+	last, err := vm.loadBlock(lastAcceptedID)
+	if err != nil {
+		return err
+	}
+	var parent *TicketBlock
+	for height := uint64(0); height <= last.BlockHeight; height++ {
+		blkIDBytes, err := vm.db.Get(heightKey(height))
+		if err != nil {
+			return fmt.Errorf("%w: height %d: %s", errCorruptChain, height, err)
+		}
+		blkID, err := ids.ToID(blkIDBytes)
+		if err != nil {
+			return fmt.Errorf("%w: height %d: %s", errCorruptChain, height, err)
+		}
+		blk, err := vm.loadBlock(blkID)
+		if err != nil {
+			return err
+		}
+		if parent != nil && blk.ParentID != parent.id {
+			return fmt.Errorf("%w: block %s at height %d doesn't build on %s", errCorruptChain, blkID, height, parent.id)
+		}
+		if height == 0 {
+			if err := genesis.apply(vm.state); err != nil {
+				return err
+			}
+		} else {
+			for _, tx := range blk.Txs {
+				if err := vm.state.ApplyTx(tx, blk.Timestamp); err != nil {
+					return fmt.Errorf("%w: tx %s: %s", errCorruptChain, tx.ID(), err)
+				}
+			}
+			vm.state.EndBlock()
+			vm.state.SetTimestamp(blk.Timestamp)
+		}
+		vm.blocks[blk.id] = blk
+		vm.heights[height] = blk.id
+		parent = blk
+	}
+	vm.lastAccepted = parent
+	vm.log.Info("replayed %d accepted blocks", last.BlockHeight)
+	return nil
+}
+
+func (vm *VM) loadBlock(blkID ids.ID) (*TicketBlock, error) {
+	bytes, err := vm.db.Get(blockKey(blkID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: block %s: %s", errCorruptChain, blkID, err)
+	}
+	blk := &TicketBlock{}
+	if err := json.Unmarshal(bytes, blk); err != nil {
+		return nil, fmt.Errorf("%w: block %s: %s", errCorruptChain, blkID, err)
+	}
+	blk.initialize(vm, bytes)
+	return blk, nil
+}
+
+// StoredLastAccepted returns the last accepted block stored in [db], without
+// initializing a VM. The block can only be inspected.
+func StoredLastAccepted(db common.Database) (*TicketBlock, error) {
+	blkID, err := db.Get(lastAcceptedKey)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ids.ToID(blkID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errCorruptChain, err)
+	}
+	return (&VM{db: db}).loadBlock(id)
+}
+
+// StoredBlock returns the block accepted at [height] stored in [db], without
+// initializing a VM. The block can only be inspected.
+func StoredBlock(db common.Database, height uint64) (*TicketBlock, error) {
+	blkID, err := db.Get(heightKey(height))
+	if errors.Is(err, common.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %d", errUnknownHeight, height)
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := ids.ToID(blkID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errCorruptChain, err)
+	}
+	return (&VM{db: db}).loadBlock(id)
+}
+
+// SetGossiper sets where pending transactions are gossiped. The network is
+// created after the VM, as its handler needs the VM.
+func (vm *VM) SetGossiper(gossiper Gossiper) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	vm.gossiper = gossiper
+}
+
+// State returns the state of the last accepted block
+func (vm *VM) State() *State { return vm.state }
+
+// Mempool returns the pending transactions
+func (vm *VM) Mempool() *Mempool { return vm.mempool }
+
+// accept records [blk] as the last accepted block. The caller holds the lock.
+func (vm *VM) accept(blk *TicketBlock) error {
+	if err := vm.db.Put(blockKey(blk.id), blk.bytes); err != nil {
+		return err
+	}
+	if err := vm.db.Put(heightKey(blk.BlockHeight), blk.id[:]); err != nil {
+		return err
+	}
+	// The last accepted block is written last, so a crash leaves the
+	// previous chain intact
+	if err := vm.db.Put(lastAcceptedKey, blk.id[:]); err != nil {
+		return err
+	}
+	vm.blocks[blk.id] = blk
+	vm.heights[blk.BlockHeight] = blk.id
+	vm.lastAccepted = blk
+	return nil
+}
+
+// ParseBlock implements the snowman.VM interface
+func (vm *VM) ParseBlock(b []byte) (snowman.Block, error) {
+	blk := &TicketBlock{}
+	if err := json.Unmarshal(b, blk); err != nil {
+		return nil, err
+	}
+	blk.initialize(vm, b)
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	// Return the known block so that its status is shared
+	if known, ok := vm.blocks[blk.id]; ok {
+		return known, nil
+	}
+	return blk, nil
+}
+
+// GetBlock implements the snowman.VM interface
+func (vm *VM) GetBlock(blkID ids.ID) (snowman.Block, error) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	blk, ok := vm.blocks[blkID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownBlock, blkID)
+	}
+	return blk, nil
+}
+
+// LastAccepted implements the snowman.VM interface
+func (vm *VM) LastAccepted() ids.ID {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	return vm.lastAccepted.id
+}
+
+// GetBlockIDAtHeight implements the snowman.VM interface
+func (vm *VM) GetBlockIDAtHeight(height uint64) (ids.ID, error) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	blkID, ok := vm.heights[height]
+	if !ok {
+		return ids.ID{}, fmt.Errorf("%w: %d", errUnknownHeight, height)
+	}
+	return blkID, nil
+}
+
+// Validators implements the snowman.ValidatorVM interface
+func (vm *VM) Validators() ([]validators.Validator, error) {
+	vdrs := vm.state.Validators()
+	converted := make([]validators.Validator, len(vdrs))
+	for i, vdr := range vdrs {
+		converted[i] = validators.Validator{
+			NodeID:   vdr.NodeID,
+			Operator: vdr.Operator,
+			Weight:   vdr.Weight,
+		}
+	}
+	return converted, nil
+}
+
+// IssueTx verifies [tx] against the last accepted state at the current time,
+// adds it to the mempool and gossips it to peers
+func (vm *VM) IssueTx(tx *Tx) error {
+	if err := vm.state.VerifyTx(tx, vm.clock().Unix()); err != nil {
+		return err
+	}
+	if err := vm.mempool.Add(tx); err != nil {
+		return err
+	}
+
+	vm.lock.Lock()
+	gossiper := vm.gossiper
+	vm.lock.Unlock()
+
+	if gossiper != nil {
+		gossiper.Gossip(&network.Message{
+			Op:      network.AppGossip,
+			Payload: tx.Bytes(),
+		})
+	}
+	return nil
+}
+
+// Pending returns true if [txID] is in the mempool
+func (vm *VM) Pending(txID ids.ID) bool { return vm.mempool.Has(txID) }
+
+// AppGossip implements the snowman.GossipVM interface. Transactions new to
+// the mempool are gossiped on, so they reach every node that may build the
+// next block.
+func (vm *VM) AppGossip(nodeID ids.ShortID, msg []byte) error {
+	tx, err := ParseTx(msg)
+	if err != nil {
+		return err
+	}
+	if vm.mempool.Has(tx.ID()) || vm.state.HasTx(tx.ID()) {
+		return nil
+	}
+	if err := vm.IssueTx(tx); err != nil {
+		return err
+	}
+	vm.log.Verbo("added tx %s gossiped by %s", tx.ID(), nodeID)
+	return nil
+}
+
+// BuildBlock assembles a block on top of the last accepted block from the
+// pending transactions paying the highest fees. Transactions that no longer
+// apply, such as expired ones, are dropped from the mempool. Those that would
+// push the block past its limits are left for a later block.
+func (vm *VM) BuildBlock() (*TicketBlock, error) {
+	pending := vm.mempool.Ordered()
+	if len(pending) == 0 {
+		return nil, errNoPendingTxs
+	}
+
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+
+	parent := vm.lastAccepted
+	timestamp := vm.clock().Unix()
+	if timestamp < parent.Timestamp {
+		timestamp = parent.Timestamp
+	}
+	empty, err := vm.newBlock(parent.id, parent.BlockHeight+1, timestamp, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		state   = vm.state.Copy()
+		size    = len(empty.bytes)
+		txs     []*Tx
+		invalid []*Tx
+	)
+	for _, tx := range pending {
+		if vm.config.MaxBlockTxs > 0 && len(txs) == vm.config.MaxBlockTxs {
+			break
+		}
+		// Every transaction after the first is preceded by a comma
+		txSize := len(tx.Bytes())
+		if len(txs) > 0 {
+			txSize++
+		}
+		if size+txSize > vm.config.MaxBlockSize {
+			continue
+		}
+		if err := state.ApplyTx(tx, timestamp); err != nil {
+			// A transaction that still applies on its own only conflicts
+			// with one included before it, e.g. both reach an event's
+			// capacity, so it is kept for a later block
+			if err := vm.state.VerifyTx(tx, timestamp); err != nil {
+				vm.log.Debug("dropping tx %s: %s", tx.ID(), err)
+				invalid = append(invalid, tx)
+			}
+			continue
+		}
+		txs = append(txs, tx)
+		size += txSize
+	}
+	vm.mempool.Remove(invalid...)
+	if len(txs) == 0 {
+		return nil, errNoTxsFit
+	}
+	return vm.newBlock(parent.id, parent.BlockHeight+1, timestamp, txs)
+}
+
+var (
+	_ snowman.ValidatorVM = &VM{}
+	_ snowman.GossipVM    = &VM{}
+)